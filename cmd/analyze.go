@@ -12,20 +12,35 @@ import (
 
 	"runcomfy/pkg/analyzer"
 	"runcomfy/pkg/scanner"
-	"runcomfy/pkg/workflow"
 )
 
 var analyzeCmd = &cobra.Command{
 	Use:   "analyze <workflow.json>",
 	Short: "Analyze a ComfyUI workflow for missing dependencies",
-	Long: `Analyze a ComfyUI workflow file to identify missing custom nodes and models.
+	Long: `Analyze a ComfyUI workflow file to identify missing custom nodes, models
+and input files (images, masks, audio and video loaded from ComfyUI's input
+directory).
+
+Both UI workflows ("Save") and API prompts ("Save (API Format)") are
+accepted; the format is detected automatically. PNG, WebP, MP4 and WebM
+files generated by ComfyUI can be passed directly to read the workflow
+embedded in their metadata.
 
 This command scans your local ComfyUI installation and compares it with
-the requirements from the workflow file to show what's missing.`,
+the requirements from the workflow file to show what's missing.
+
+With --only-reachable, nodes that are not upstream of an output node are
+left out, as "runcomfy prune" would remove them, so dead branches do not
+ask for models and custom nodes.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runAnalyze,
 }
 
+var (
+	includeInactive bool
+	onlyReachable   bool
+)
+
 func runAnalyze(cmd *cobra.Command, args []string) error {
 	workflowPath := args[0]
 	comfyUIPath := viper.GetString("comfyui-path")
@@ -41,9 +56,14 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("workflow file not found: %s", workflowPath)
 	}
 
-	w, err := workflow.ParseWorkflow(workflowPath)
+	registry, err := loadRegistry()
 	if err != nil {
-		return fmt.Errorf("failed to parse workflow: %w", err)
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
 	}
 
 	installation := scanner.NewComfyUIInstallation(comfyUIPath)
@@ -53,6 +73,13 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 	}
 
 	a := analyzer.New(installation)
+	a.IncludeInactive = includeInactive
+	a.OnlyReachable = onlyReachable
+	a.Outputs = viper.GetStringSlice("prune.outputs")
+	a.Registry = registry
+	if a.Migrator, err = loadMigrator(); err != nil {
+		return err
+	}
 	result, err := a.AnalyzeWorkflow(w)
 	if err != nil {
 		return fmt.Errorf("analysis failed: %w", err)
@@ -78,6 +105,9 @@ func outputJSON(result *analyzer.AnalysisResult) error {
 
 func outputTable(result *analyzer.AnalysisResult, verbose bool) error {
 	fmt.Printf("📁 Workflow: %s\n", filepath.Base(result.WorkflowPath))
+	if result.Format != "" {
+		fmt.Printf("🧩 Format: %s\n", result.Format)
+	}
 	fmt.Printf("📊 Summary: %s\n\n", result.Summary)
 
 	fmt.Printf("Statistics:\n")
@@ -92,6 +122,16 @@ func outputTable(result *analyzer.AnalysisResult, verbose bool) error {
 		fmt.Println()
 	}
 
+	if len(result.OptionalNodes) > 0 {
+		fmt.Printf("🟡 Optional Custom Nodes, used only by muted or bypassed nodes (%d):\n", len(result.OptionalNodes))
+		for _, node := range result.OptionalNodes {
+			fmt.Printf("  - %s\n", node)
+		}
+		fmt.Println()
+	}
+
+	printMigrations(result, verbose)
+
 	if len(result.MissingModels) > 0 {
 		fmt.Printf("🔴 Missing Models (%d):\n", len(result.MissingModels))
 		
@@ -103,17 +143,39 @@ func outputTable(result *analyzer.AnalysisResult, verbose bool) error {
 		for category, models := range categories {
 			fmt.Printf("  %s:\n", strings.Title(category))
 			for _, model := range models {
+				suffix := ""
+				if !model.Required {
+					suffix = " [optional]"
+				}
 				if verbose {
-					fmt.Printf("    - %s (path: %s)\n", model.Name, model.Path)
+					fmt.Printf("    - %s (path: %s)%s\n", model.Name, model.Target, suffix)
 				} else {
-					fmt.Printf("    - %s\n", model.Name)
+					fmt.Printf("    - %s%s\n", model.Name, suffix)
 				}
 			}
 		}
 		fmt.Println()
 	}
 
-	if len(result.MissingNodes) == 0 && len(result.MissingModels) == 0 {
+	if len(result.MissingInputs) > 0 {
+		fmt.Printf("🔴 Missing Input Files (%d):\n", len(result.MissingInputs))
+		for _, input := range result.MissingInputs {
+			suffix := ""
+			if !input.Required {
+				suffix = " [optional]"
+			}
+			if verbose {
+				fmt.Printf("  - %s (path: %s)%s\n", input.Name, input.Path, suffix)
+			} else {
+				fmt.Printf("  - %s%s\n", input.Name, suffix)
+			}
+		}
+		fmt.Println()
+	}
+
+	printWarnings(result.Warnings)
+
+	if len(result.MissingNodes) == 0 && len(result.MissingModels) == 0 && len(result.MissingInputs) == 0 {
 		fmt.Println("✅ All dependencies are satisfied! You can run this workflow.")
 	} else {
 		fmt.Println("💡 Tip: Use 'runcomfy install <workflow.json>' to download missing dependencies.")
@@ -122,6 +184,35 @@ func outputTable(result *analyzer.AnalysisResult, verbose bool) error {
 	return nil
 }
 
+func printWarnings(warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+	fmt.Printf("🟡 Warnings (%d):\n", len(warnings))
+	for _, warning := range warnings {
+		fmt.Printf("  - %s\n", warning)
+	}
+	fmt.Println()
+}
+
+func printMigrations(result *analyzer.AnalysisResult, verbose bool) {
+	if len(result.Migrations) == 0 {
+		return
+	}
+	fmt.Printf("🔁 Available Migrations (%d):\n", len(result.Migrations))
+	for _, migration := range result.Migrations {
+		fmt.Printf("  - %s → %s\n", migration.Node, migration.Replacement)
+		if verbose && migration.Note != "" {
+			fmt.Printf("    %s\n", migration.Note)
+		}
+	}
+	fmt.Printf("  Run 'runcomfy migrate %s' to replace them.\n\n", result.WorkflowPath)
+}
+
 func init() {
+	analyzeCmd.Flags().BoolVar(&includeInactive, "include-inactive", false, "also report dependencies of muted and bypassed nodes as optional")
+	analyzeCmd.Flags().BoolVar(&onlyReachable, "only-reachable", false, "leave out nodes that are not upstream of an output node")
+	addOverrideFlags(analyzeCmd)
+
 	rootCmd.AddCommand(analyzeCmd)
 }
\ No newline at end of file
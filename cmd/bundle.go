@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/analyzer"
+	"runcomfy/pkg/bundle"
+	"runcomfy/pkg/scanner"
+	"runcomfy/pkg/workflow"
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Ship a workflow with everything it needs as a single archive",
+	Long: `Package a workflow together with a manifest of its dependencies, so that
+another team can run it without asking which node packs and model files it
+needs:
+
+  runcomfy bundle create workflow.json
+  runcomfy bundle install workflow.bundle.zip -p /workspace/ComfyUI`,
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create <workflow> [bundle.zip]",
+	Short: "Create a bundle from the local ComfyUI installation",
+	Long: `Create a zip archive holding the workflow and a manifest of what it needs,
+captured from the ComfyUI installation given with --comfyui-path:
+
+  - the custom node packs providing its nodes, with the git repository and
+    commit each is installed at, or its Comfy registry id and version
+  - the model files it loads, with their location, size, SHA-256 and the
+    download URL the workflow records, if any
+  - the input files it loads, which --include-inputs stores in the bundle
+
+Packs and models that are not installed are recorded from what the workflow
+tells about them and reported. Custom nodes are recognized by their
+definitions, see --object-info, or by what the editor recorded in the
+workflow. The bundle is written to bundle.zip, or to <workflow>.bundle.zip
+in the current directory when it is omitted.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runBundleCreate,
+}
+
+var bundleInstallCmd = &cobra.Command{
+	Use:   "install <bundle.zip>",
+	Short: "Install a bundle onto a ComfyUI installation",
+	Long: `Restore a bundle onto the ComfyUI installation given with --comfyui-path:
+
+  - missing custom node packs are cloned with git at the recorded commit;
+    packs only known from the Comfy registry are left to ComfyUI Manager
+  - missing models are downloaded from their URL and checked against the
+    recorded size and SHA-256; set HF_TOKEN for gated Hugging Face models
+  - bundled input files are extracted into the input directory
+  - the workflow is saved to user/default/workflows, where the editor lists
+    it
+
+Anything already installed is checked against the manifest and reported
+when it differs, such as a pack at another commit, but is never changed;
+--force overwrites input files and the workflow. The command fails when a
+required item could not be installed.
+
+Bundles are checked before anything is installed: models must go below
+models/, input files below input/, and packs are only cloned from https://
+or git@ repositories at a commit id.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBundleInstall,
+}
+
+var (
+	bundleIncludeInputs   bool
+	bundleIncludeInactive bool
+	bundleDryRun          bool
+	bundleVerify          bool
+	bundleForce           bool
+)
+
+func runBundleCreate(cmd *cobra.Command, args []string) error {
+	workflowPath := args[0]
+	verbose := viper.GetBool("verbose")
+
+	if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
+		return fmt.Errorf("workflow file not found: %s", workflowPath)
+	}
+
+	installation := scanner.NewComfyUIInstallation(viper.GetString("comfyui-path"))
+	if _, err := os.Stat(installation.BasePath); os.IsNotExist(err) {
+		return fmt.Errorf("ComfyUI installation not found at: %s", installation.BasePath)
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	data, err := workflow.ReadWorkflow(workflowPath)
+	if err != nil {
+		return err
+	}
+	w, err := workflow.Parse(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse workflow: %w", err)
+	}
+
+	a := analyzer.New(installation)
+	a.IncludeInactive = bundleIncludeInactive
+	a.Registry = registry
+	result, err := a.AnalyzeWorkflow(w)
+	if err != nil {
+		return fmt.Errorf("analysis failed: %w", err)
+	}
+	scanResult, err := installation.ScanInstallation()
+	if err != nil {
+		return fmt.Errorf("scan failed: %w", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(workflowPath), filepath.Ext(workflowPath))
+	outputPath := name + ".bundle.zip"
+	if len(args) > 1 {
+		outputPath = args[1]
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+	opts := bundle.CreateOptions{IncludeInactive: bundleIncludeInactive, Inputs: bundleIncludeInputs}
+	if verbose {
+		opts.Progress = func(path string) {
+			fmt.Fprintf(os.Stderr, "Hashing %s\n", path)
+		}
+	}
+	manifest, err := bundle.Create(out, bundle.Source{
+		Name:         name + ".json",
+		Data:         data,
+		Workflow:     w,
+		Analysis:     result,
+		Scan:         scanResult,
+		Installation: installation,
+		Registry:     registry,
+	}, opts)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		cmd.SilenceUsage = true
+		return err
+	}
+
+	if viper.GetString("output") == "json" {
+		return writeJSON(manifest)
+	}
+	outputBundleManifest(outputPath, manifest, result.Warnings)
+	return nil
+}
+
+func outputBundleManifest(outputPath string, m *bundle.Manifest, warnings []string) {
+	fmt.Printf("📦 Bundle: %s\n", outputPath)
+	fmt.Printf("📁 Workflow: %s\n\n", m.Workflow)
+
+	printWarnings(warnings)
+
+	optional := func(required bool) string {
+		if required {
+			return ""
+		}
+		return " (optional)"
+	}
+
+	if len(m.CustomNodes) > 0 {
+		fmt.Printf("🔌 Custom Nodes (%d):\n", len(m.CustomNodes))
+		for _, pack := range m.CustomNodes {
+			switch {
+			case pack.Name == "":
+				fmt.Printf("  - ⚠️  %s%s: not installed", strings.Join(pack.NodeTypes, ", "), optional(pack.Required))
+				if pack.Repository != "" {
+					fmt.Printf("; recorded as %s", pack.Repository)
+				} else if pack.RegistryID != "" {
+					fmt.Printf("; recorded as %s", pack.RegistryID)
+				}
+				fmt.Println()
+			case pack.Repository != "":
+				fmt.Printf("  - %s%s: %s", pack.Name, optional(pack.Required), pack.Repository)
+				if pack.Commit != "" {
+					fmt.Printf(" @ %.10s", pack.Commit)
+				}
+				fmt.Println()
+			case pack.RegistryID != "":
+				fmt.Printf("  - %s%s: registry %s %s\n", pack.Name, optional(pack.Required), pack.RegistryID, pack.Version)
+			default:
+				fmt.Printf("  - ⚠️  %s%s: not a git checkout; its origin is unknown\n", pack.Name, optional(pack.Required))
+			}
+		}
+		fmt.Println()
+	}
+
+	if len(m.Models) > 0 {
+		fmt.Printf("🎨 Models (%d):\n", len(m.Models))
+		for _, model := range m.Models {
+			if model.SHA256 == "" {
+				fmt.Printf("  - ⚠️  %s%s: not installed; recorded without hash\n", model.Target, optional(model.Required))
+				continue
+			}
+			fmt.Printf("  - %s%s (%.2f MB, sha256 %.12s)\n", model.Target, optional(model.Required), float64(model.Size)/(1024*1024), model.SHA256)
+			if model.URL == "" {
+				fmt.Printf("    No download URL; it will have to be copied by hand\n")
+			}
+		}
+		fmt.Println()
+	}
+
+	if len(m.Inputs) > 0 {
+		fmt.Printf("🖼️  Input Files (%d):\n", len(m.Inputs))
+		for _, input := range m.Inputs {
+			switch {
+			case input.Bundled:
+				fmt.Printf("  - %s%s (bundled)\n", input.Path, optional(input.Required))
+			case input.SHA256 == "":
+				fmt.Printf("  - ⚠️  %s%s: not installed\n", input.Path, optional(input.Required))
+			default:
+				fmt.Printf("  - %s%s\n", input.Path, optional(input.Required))
+			}
+		}
+		fmt.Println()
+	}
+}
+
+func runBundleInstall(cmd *cobra.Command, args []string) error {
+	verbose := viper.GetBool("verbose")
+
+	installation := scanner.NewComfyUIInstallation(viper.GetString("comfyui-path"))
+	if _, err := os.Stat(installation.BasePath); os.IsNotExist(err) {
+		return fmt.Errorf("ComfyUI installation not found at: %s", installation.BasePath)
+	}
+
+	b, err := bundle.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	opts := bundle.InstallOptions{DryRun: bundleDryRun, Verify: bundleVerify, Force: bundleForce}
+	if verbose {
+		opts.Progress = func(message string) {
+			fmt.Fprintln(os.Stderr, message)
+		}
+	}
+	steps, err := b.Install(installation, opts)
+	if err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+
+	failed := 0
+	for _, step := range steps {
+		if step.Required && step.Status == bundle.StatusFailed {
+			failed++
+		}
+	}
+
+	if viper.GetString("output") == "json" {
+		if err := writeJSON(steps); err != nil {
+			return err
+		}
+	} else {
+		outputBundleSteps(args[0], b.Manifest, steps)
+	}
+
+	if failed > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d required item(s) could not be installed", failed)
+	}
+	return nil
+}
+
+var bundleStatusIcons = map[bundle.Status]string{
+	bundle.StatusPresent:   "✅",
+	bundle.StatusInstalled: "📥",
+	bundle.StatusPlanned:   "🔍",
+	bundle.StatusMismatch:  "🟡",
+	bundle.StatusManual:    "✋",
+	bundle.StatusFailed:    "❌",
+}
+
+func outputBundleSteps(bundlePath string, m *bundle.Manifest, steps []bundle.Step) {
+	fmt.Printf("📦 Bundle: %s\n", filepath.Base(bundlePath))
+	fmt.Printf("📁 Workflow: %s (created %s)\n", m.Workflow, m.CreatedAt.Format("2006-01-02 15:04:05"))
+	if bundleDryRun {
+		fmt.Println("🔍 Dry run mode - showing what would be installed")
+	}
+
+	headers := map[string]string{
+		"custom-node": "🔌 Custom Nodes",
+		"model":       "🎨 Models",
+		"input":       "🖼️  Input Files",
+		"workflow":    "📝 Workflow",
+	}
+	kind := ""
+	counts := make(map[bundle.Status]int)
+	for _, step := range steps {
+		if step.Kind != kind {
+			kind = step.Kind
+			fmt.Printf("\n%s:\n", headers[kind])
+		}
+		counts[step.Status]++
+
+		line := fmt.Sprintf("  %s %s", bundleStatusIcons[step.Status], step.Name)
+		if step.Target != "" && step.Target != step.Name {
+			line += " → " + step.Target
+		}
+		if !step.Required {
+			line += " (optional)"
+		}
+		fmt.Println(line)
+		if step.Detail != "" {
+			fmt.Printf("     %s\n", step.Detail)
+		}
+	}
+
+	var summary []string
+	for _, status := range []bundle.Status{bundle.StatusInstalled, bundle.StatusPlanned, bundle.StatusPresent, bundle.StatusMismatch, bundle.StatusManual, bundle.StatusFailed} {
+		if counts[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	fmt.Printf("\n📊 Summary: %s\n", strings.Join(summary, ", "))
+}
+
+func init() {
+	bundleCreateCmd.Flags().BoolVar(&bundleIncludeInputs, "include-inputs", false, "store the input files the workflow loads in the bundle")
+	bundleCreateCmd.Flags().BoolVar(&bundleIncludeInactive, "include-inactive", false, "also record dependencies of muted/bypassed nodes, as optional")
+
+	bundleInstallCmd.Flags().BoolVar(&bundleDryRun, "dry-run", false, "show what would be installed without installing anything")
+	bundleInstallCmd.Flags().BoolVar(&bundleVerify, "verify", false, "hash installed models to check they match the bundle")
+	bundleInstallCmd.Flags().BoolVar(&bundleForce, "force", false, "overwrite input files and the workflow where they differ from the bundle")
+
+	bundleCmd.AddCommand(bundleCreateCmd)
+	bundleCmd.AddCommand(bundleInstallCmd)
+	rootCmd.AddCommand(bundleCmd)
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/workflow"
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert <workflow> [output.json]",
+	Short: "Convert a ComfyUI workflow between UI and API formats",
+	Long: `Convert a ComfyUI workflow to another format.
+
+With --to api, a UI workflow is turned into the prompt accepted by the
+/prompt endpoint, exactly as "Save (API Format)" would: group nodes and
+subgraphs are flattened, muted nodes are dropped, bypassed nodes and
+reroutes are spliced out, and primitive node values are inlined.
+
+Widget values are named using the bundled core node definitions. Workflows
+that use custom nodes need --object-info pointing to a dump of your server's
+/object_info endpoint.
+
+With --to ui, an API prompt (or the prompt embedded in a ComfyUI output)
+is turned back into an editor workflow. Slots, links and widget values are
+restored from the node definitions and nodes are laid out left to right in
+execution order.
+
+Widget values can be changed on the way with --set and --overrides, as
+with the patch command.
+
+The result is written to output.json, or to stdout when it is omitted.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runConvert,
+}
+
+var convertTo string
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	workflowPath := args[0]
+	verbose := viper.GetBool("verbose")
+
+	if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
+		return fmt.Errorf("workflow file not found: %s", workflowPath)
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
+	}
+
+	var result interface{}
+	switch workflow.Format(convertTo) {
+	case workflow.FormatAPI:
+		if w.Format == workflow.FormatAPI {
+			return fmt.Errorf("workflow is already in API format")
+		}
+		prompt, err := w.ToPrompt(registry)
+		if err != nil {
+			return err
+		}
+		result = prompt
+	case workflow.FormatUI:
+		if w.Format == workflow.FormatUI {
+			return fmt.Errorf("workflow is already in UI format")
+		}
+		data, err := readWorkflow(workflowPath, registry)
+		if err != nil {
+			return err
+		}
+		prompt, err := workflow.ParsePrompt(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse prompt: %w", err)
+		}
+		ui, err := prompt.ToWorkflow(registry)
+		if err != nil {
+			return err
+		}
+		result = ui
+	default:
+		return fmt.Errorf("unsupported target format: %s (expected api or ui)", convertTo)
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode workflow: %w", err)
+	}
+	data = append(data, '\n')
+
+	if len(args) < 2 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[1], data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", args[1], err)
+	}
+	if verbose {
+		fmt.Printf("Wrote %s workflow to %s\n", convertTo, args[1])
+	}
+	return nil
+}
+
+func init() {
+	convertCmd.Flags().StringVar(&convertTo, "to", "", "target format (api, ui)")
+	convertCmd.MarkFlagRequired("to")
+	addOverrideFlags(convertCmd)
+
+	rootCmd.AddCommand(convertCmd)
+}
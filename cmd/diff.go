@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/workflow"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <a.json> <b.json>",
+	Short: "Show the semantic differences between two workflows",
+	Long: `Compare two ComfyUI workflows by what they do rather than how they
+were saved.
+
+Positions, sizes, link ids and ordering are ignored. The comparison reports
+added and removed nodes, changed widget values, modes and titles, rewired
+inputs (looking through reroutes), and changes to the models and custom node
+packs the workflows require. Group nodes and subgraphs are compared by their
+inner nodes.
+
+JSON output lists the changes as JSON Patch-like operations.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+type diffReport struct {
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Changes []workflow.Change `json:"changes"`
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	outputFormat := viper.GetString("output")
+
+	var workflows [2]*workflow.Workflow
+	for i, file := range args {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			return fmt.Errorf("workflow file not found: %s", file)
+		}
+		w, err := workflow.ParseWorkflow(file)
+		if err != nil {
+			return fmt.Errorf("failed to parse workflow %s: %w", file, err)
+		}
+		workflows[i] = w
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	report := &diffReport{
+		From:    args[0],
+		To:      args[1],
+		Changes: workflow.Diff(workflows[0], workflows[1], registry),
+	}
+	if report.Changes == nil {
+		report.Changes = []workflow.Change{}
+	}
+
+	switch outputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "table":
+		return outputDiffTable(report)
+	default:
+		return fmt.Errorf("unsupported output format: %s", outputFormat)
+	}
+}
+
+func outputDiffTable(report *diffReport) error {
+	fmt.Printf("📁 %s → %s\n", filepath.Base(report.From), filepath.Base(report.To))
+	fmt.Printf("📊 Summary: %d change(s)\n\n", len(report.Changes))
+
+	if len(report.Changes) == 0 {
+		fmt.Println("✅ The workflows are equivalent.")
+		return nil
+	}
+
+	var nodeID workflow.NodeID
+	var models, packs []workflow.Change
+	for _, change := range report.Changes {
+		switch change.Kind {
+		case workflow.ChangeModel:
+			models = append(models, change)
+			continue
+		case workflow.ChangeCustomNode:
+			packs = append(packs, change)
+			continue
+		case workflow.ChangeNode:
+			fmt.Printf("%s node %s (%s)\n", diffMarker(change.Op), change.NodeID, change.NodeType)
+			nodeID = ""
+			continue
+		}
+
+		if change.NodeID != nodeID {
+			fmt.Printf("~ node %s (%s)\n", change.NodeID, change.NodeType)
+			nodeID = change.NodeID
+		}
+		fmt.Printf("    %s %s: %s\n", diffMarker(change.Op), diffLabel(change), diffValues(change))
+	}
+	fmt.Println()
+
+	for _, section := range []struct {
+		title   string
+		changes []workflow.Change
+	}{
+		{"📦 Models", models},
+		{"🧩 Custom Node Packs", packs},
+	} {
+		if len(section.changes) == 0 {
+			continue
+		}
+		fmt.Printf("%s (%d):\n", section.title, len(section.changes))
+		for _, change := range section.changes {
+			value := change.Value
+			if change.Op == workflow.OpRemove {
+				value = change.From
+			}
+			fmt.Printf("  %s %v\n", diffMarker(change.Op), value)
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+func diffMarker(op string) string {
+	switch op {
+	case workflow.OpAdd:
+		return "+"
+	case workflow.OpRemove:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+func diffLabel(change workflow.Change) string {
+	name := strings.NewReplacer("~1", "/", "~0", "~").Replace(path.Base(change.Path))
+	switch change.Kind {
+	case workflow.ChangeLink:
+		return "input " + name
+	case workflow.ChangeWidget:
+		return name
+	default:
+		return change.Kind
+	}
+}
+
+func diffValues(change workflow.Change) string {
+	format := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+	switch change.Op {
+	case workflow.OpAdd:
+		return format(change.Value)
+	case workflow.OpRemove:
+		return format(change.From)
+	default:
+		return format(change.From) + " → " + format(change.Value)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/export"
+	"runcomfy/pkg/workflow"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <workflow> [output]",
+	Short: "Generate a Python or Go program that queues a workflow",
+	Long: `Generate a standalone program that builds the workflow's API prompt in
+code and queues it on a ComfyUI server, so the workflow can live in a
+service's source tree rather than as a JSON file.
+
+Each node becomes one statement adding it with its inputs by name; inputs
+connected to other nodes refer to the variables holding those nodes. UI
+workflows are converted to API format first, as the convert command does.
+
+  --lang python  a Python 3 script using only the standard library
+  --lang go      a Go program built on the runcomfy/pkg/comfyclient package
+
+The generated programs queue the prompt on the server given with --server,
+wait for it to finish and print the addresses of the files it wrote. They
+also accept --print to only print the prompt.
+
+The program is written to output, or to stdout when it is omitted.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runExport,
+}
+
+var (
+	exportLang   string
+	exportServer string
+)
+
+func runExport(cmd *cobra.Command, args []string) error {
+	workflowPath := args[0]
+	verbose := viper.GetBool("verbose")
+
+	if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
+		return fmt.Errorf("workflow file not found: %s", workflowPath)
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
+	}
+
+	var prompt workflow.Prompt
+	if w.Format == workflow.FormatAPI {
+		prompt, err = w.Prompt()
+	} else {
+		prompt, err = w.ToPrompt(registry)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := export.Generate(strings.ToLower(exportLang), prompt, export.Options{
+		Source:   filepath.Base(workflowPath),
+		Server:   exportServer,
+		Registry: registry,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	mode := os.FileMode(0644)
+	if exportLang == "python" {
+		mode = 0755
+	}
+	if err := os.WriteFile(args[1], data, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", args[1], err)
+	}
+	if verbose {
+		fmt.Printf("Wrote %s program to %s\n", exportLang, args[1])
+	}
+	return nil
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportLang, "lang", "", "language of the program ("+strings.Join(export.Languages, ", ")+")")
+	exportCmd.Flags().StringVar(&exportServer, "server", export.DefaultServer, "default ComfyUI server URL of the program")
+	exportCmd.MarkFlagRequired("lang")
+	addOverrideFlags(exportCmd)
+
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/workflow"
+)
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt <workflow.json>...",
+	Short: "Rewrite workflows in a canonical form for version control",
+	Long: `Format ComfyUI workflow and API prompt files canonically.
+
+Every save from the editor reorders keys and rewrites floats, which makes
+diffs of committed workflows unreadable. The canonical form sorts object
+keys, orders nodes and links by id, writes floats in their shortest exact
+form and keeps short arrays such as links and positions on one line. The
+workflow itself is left unchanged.
+
+Editor state can optionally be dropped as well: node positions and sizes
+(--strip-layout), node and group colors (--strip-colors) and the canvas
+offset and zoom (--strip-view). --strip removes all three.
+
+By default the formatted workflow is written to stdout. With --write the
+files are rewritten in place, and with --check nothing is written: the files
+that are not canonical are listed and the command exits with an error.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runFmt,
+}
+
+var (
+	fmtWrite       bool
+	fmtCheck       bool
+	fmtStrip       bool
+	fmtStripLayout bool
+	fmtStripColors bool
+	fmtStripView   bool
+)
+
+func runFmt(cmd *cobra.Command, args []string) error {
+	verbose := viper.GetBool("verbose")
+	if fmtWrite && fmtCheck {
+		return fmt.Errorf("--write and --check cannot be used together")
+	}
+
+	opts := workflow.CanonicalOptions{
+		StripLayout:    fmtStrip || fmtStripLayout,
+		StripColors:    fmtStrip || fmtStripColors,
+		StripViewState: fmtStrip || fmtStripView,
+	}
+
+	var unformatted []string
+	for _, file := range args {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("workflow file not found: %s", file)
+			}
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		formatted, err := workflow.Canonicalize(data, opts)
+		if err != nil {
+			return fmt.Errorf("failed to format %s: %w", file, err)
+		}
+
+		switch {
+		case fmtCheck:
+			if !bytes.Equal(data, formatted) {
+				unformatted = append(unformatted, file)
+				fmt.Println(file)
+			}
+		case fmtWrite:
+			if bytes.Equal(data, formatted) {
+				continue
+			}
+			if err := os.WriteFile(file, formatted, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", file, err)
+			}
+			if verbose {
+				fmt.Printf("Formatted %s\n", file)
+			}
+		default:
+			if _, err := os.Stdout.Write(formatted); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(unformatted) > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d file(s) not canonically formatted", len(unformatted))
+	}
+	return nil
+}
+
+func init() {
+	fmtCmd.Flags().BoolVarP(&fmtWrite, "write", "w", false, "rewrite files in place")
+	fmtCmd.Flags().BoolVar(&fmtCheck, "check", false, "list files that are not canonical and exit with an error")
+	fmtCmd.Flags().BoolVar(&fmtStrip, "strip", false, "strip layout, colors and view state")
+	fmtCmd.Flags().BoolVar(&fmtStripLayout, "strip-layout", false, "strip node positions and sizes")
+	fmtCmd.Flags().BoolVar(&fmtStripColors, "strip-colors", false, "strip node and group colors")
+	fmtCmd.Flags().BoolVar(&fmtStripView, "strip-view", false, "strip the canvas offset and zoom (extra.ds)")
+
+	rootCmd.AddCommand(fmtCmd)
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/analyzer"
+	"runcomfy/pkg/render"
+	"runcomfy/pkg/scanner"
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph <workflow> [output]",
+	Short: "Render a workflow's graph as DOT, Mermaid or SVG",
+	Long: `Render a workflow's graph for review without the ComfyUI editor, for
+example in a pull request.
+
+  --format dot      Graphviz source, for "dot -Tpng" and similar tools
+  --format mermaid  a Mermaid flowchart, which GitHub and GitLab render in
+                    Markdown
+  --format svg      a standalone image drawn by runcomfy itself, using the
+                    node positions from the editor
+
+Nodes are filled with the color of the custom node pack they come from, as
+told by the node definitions (see --object-info) or by the pack the editor
+recorded in the workflow. When the ComfyUI installation given with
+--comfyui-path exists, the workflow is analyzed against it and nodes whose
+custom node pack is missing are outlined in red. Links are labeled with the
+type they carry, and groups are drawn as clusters. Muted and bypassed nodes
+have a dashed outline.
+
+The graph is written to output, or to stdout when it is omitted. Without
+--format the format is chosen by the output's extension (.dot, .gv, .mmd,
+.mermaid or .svg), and is dot otherwise.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runGraph,
+}
+
+var graphFormat string
+
+// graphExtensions maps output file extensions to formats.
+var graphExtensions = map[string]string{
+	".dot":     "dot",
+	".gv":      "dot",
+	".mmd":     "mermaid",
+	".mermaid": "mermaid",
+	".svg":     "svg",
+}
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	workflowPath := args[0]
+	verbose := viper.GetBool("verbose")
+
+	if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
+		return fmt.Errorf("workflow file not found: %s", workflowPath)
+	}
+
+	format := strings.ToLower(graphFormat)
+	if format == "" && len(args) > 1 {
+		format = graphExtensions[strings.ToLower(filepath.Ext(args[1]))]
+	}
+	if format == "" {
+		format = "dot"
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
+	}
+
+	opts := render.Options{Title: filepath.Base(workflowPath), Registry: registry}
+	installation := scanner.NewComfyUIInstallation(viper.GetString("comfyui-path"))
+	if _, err := os.Stat(installation.BasePath); err == nil {
+		a := analyzer.New(installation)
+		a.IncludeInactive = true
+		a.Registry = registry
+		result, err := a.AnalyzeWorkflow(w)
+		if err != nil {
+			return fmt.Errorf("analysis failed: %w", err)
+		}
+		opts.Checked = true
+		opts.Missing = append(result.MissingNodes, result.OptionalNodes...)
+	} else if verbose {
+		fmt.Fprintf(os.Stderr, "ComfyUI installation not found at %s; node status is not shown\n", installation.BasePath)
+	}
+
+	data, err := render.Render(format, w, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[1], data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", args[1], err)
+	}
+	if verbose {
+		fmt.Printf("Wrote %s graph to %s\n", format, args[1])
+	}
+	return nil
+}
+
+func init() {
+	graphCmd.Flags().StringVar(&graphFormat, "format", "", "graph format ("+strings.Join(render.Formats, ", ")+")")
+	addOverrideFlags(graphCmd)
+
+	rootCmd.AddCommand(graphCmd)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/catalog"
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index <dir>...",
+	Short: "Index the workflows in directories for searching",
+	Long: `Parse every workflow under the given directories, JSON files as well as
+PNG, WebP, MP4 and WebM outputs with embedded workflows, into a local index
+that "runcomfy search" queries.
+
+The index records each workflow's node types, custom node packs, model and
+input files, widget values and group titles. Indexing again only parses the
+files that are new or changed since, and forgets those that were deleted;
+use --rebuild to parse everything again, for instance after changing
+--object-info, which names widget values and identifies custom node packs.
+Hidden files and directories are skipped.
+
+The index is kept in runcomfy's cache directory unless --index or index.path
+in the config file says otherwise:
+
+  index:
+    path: /mnt/shared/runcomfy-index.json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runIndex,
+}
+
+var (
+	indexPath    string
+	indexRebuild bool
+)
+
+// catalogPath returns where the workflow index is kept.
+func catalogPath() (string, error) {
+	if indexPath != "" {
+		return indexPath, nil
+	}
+	if path := viper.GetString("index.path"); path != "" {
+		return path, nil
+	}
+	return catalog.DefaultPath()
+}
+
+func runIndex(cmd *cobra.Command, args []string) error {
+	verbose := viper.GetBool("verbose")
+
+	path, err := catalogPath()
+	if err != nil {
+		return err
+	}
+	c, err := catalog.Load(path)
+	if err != nil {
+		return err
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	opts := catalog.UpdateOptions{Registry: registry, Rebuild: indexRebuild}
+	if verbose {
+		opts.Progress = func(path string) {
+			fmt.Fprintf(os.Stderr, "Indexing %s\n", path)
+		}
+	}
+
+	results := []*catalog.UpdateResult{}
+	for _, dir := range args {
+		result, err := c.Update(dir, opts)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	if err := c.Save(path); err != nil {
+		return err
+	}
+
+	if viper.GetString("output") == "json" {
+		return writeJSON(struct {
+			Index     string                  `json:"index"`
+			Workflows int                     `json:"workflows"`
+			Dirs      []*catalog.UpdateResult `json:"dirs"`
+		}{path, len(c.Workflows()), results})
+	}
+
+	for _, result := range results {
+		fmt.Printf("📁 %s\n", result.Dir)
+		fmt.Printf("  Added: %d, updated: %d, unchanged: %d, removed: %d\n",
+			result.Added, result.Updated, result.Unchanged, result.Removed)
+		if result.Skipped > 0 {
+			fmt.Printf("  Not workflows: %d file(s)\n", result.Skipped)
+		}
+	}
+	fmt.Printf("\n📚 %d workflow(s) indexed in %s\n", len(c.Workflows()), path)
+	return nil
+}
+
+func init() {
+	indexCmd.Flags().StringVar(&indexPath, "index", "", "index file (default is index.json in runcomfy's cache directory)")
+	indexCmd.Flags().BoolVar(&indexRebuild, "rebuild", false, "parse every file again, even unchanged ones")
+
+	rootCmd.AddCommand(indexCmd)
+}
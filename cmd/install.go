@@ -10,7 +10,6 @@ import (
 
 	"runcomfy/pkg/analyzer"
 	"runcomfy/pkg/scanner"
-	"runcomfy/pkg/workflow"
 )
 
 var installCmd = &cobra.Command{
@@ -43,9 +42,14 @@ func runInstall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("workflow file not found: %s", workflowPath)
 	}
 
-	w, err := workflow.ParseWorkflow(workflowPath)
+	registry, err := loadRegistry()
 	if err != nil {
-		return fmt.Errorf("failed to parse workflow: %w", err)
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
 	}
 
 	installation := scanner.NewComfyUIInstallation(comfyUIPath)
@@ -55,12 +59,20 @@ func runInstall(cmd *cobra.Command, args []string) error {
 	}
 
 	a := analyzer.New(installation)
+	a.Registry = registry
+	if a.Migrator, err = loadMigrator(); err != nil {
+		return err
+	}
 	result, err := a.AnalyzeWorkflow(w)
 	if err != nil {
 		return fmt.Errorf("analysis failed: %w", err)
 	}
 
-	if len(result.MissingNodes) == 0 && len(result.MissingModels) == 0 {
+	result.WorkflowPath = workflowPath
+
+	printWarnings(result.Warnings)
+
+	if len(result.MissingNodes) == 0 && len(result.MissingModels) == 0 && len(result.MissingInputs) == 0 {
 		fmt.Println("✅ All dependencies are already satisfied!")
 		return nil
 	}
@@ -83,6 +95,8 @@ func runInstall(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 	}
 
+	printMigrations(result, verbose)
+
 	if len(result.MissingModels) > 0 {
 		fmt.Printf("🎨 Models to Download (%d):\n", len(result.MissingModels))
 		
@@ -95,8 +109,17 @@ func runInstall(cmd *cobra.Command, args []string) error {
 			fmt.Printf("  %s:\n", strings.Title(category))
 			for _, model := range models {
 				fmt.Printf("    - %s\n", model.Name)
-				if model.Path != "" {
-					fmt.Printf("      Target: %s/%s\n", comfyUIPath, model.Path)
+				if model.Target != "" {
+					fmt.Printf("      Target: %s/%s\n", comfyUIPath, model.Target)
+				}
+				if model.Subfolder != "" {
+					fmt.Printf("      Subfolder: %s (create it inside %s)\n", model.Subfolder, model.Category)
+				}
+				if model.DownloadURL != "" {
+					fmt.Printf("      Download: %s\n", model.DownloadURL)
+				}
+				if len(model.Candidates) > 0 {
+					fmt.Printf("      Found: %s (pick it in the workflow or move it to the target)\n", strings.Join(model.Candidates, ", "))
 				}
 			}
 		}
@@ -110,6 +133,16 @@ func runInstall(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 	}
 
+	if len(result.MissingInputs) > 0 {
+		fmt.Printf("🖼️  Input Files to Provide (%d):\n", len(result.MissingInputs))
+		for _, input := range result.MissingInputs {
+			fmt.Printf("  - %s\n", input.Name)
+			fmt.Printf("    Target: %s/%s\n", comfyUIPath, input.Path)
+		}
+		fmt.Println("\n💡 Input files are supplied by you; copy them into the input directory.")
+		fmt.Println()
+	}
+
 	if !dryRun {
 		fmt.Println("⚠️  Automatic installation not yet implemented.")
 		fmt.Println("   Please install dependencies manually using the guidance above.")
@@ -122,6 +155,7 @@ func runInstall(cmd *cobra.Command, args []string) error {
 func init() {
 	installCmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would be installed without actually installing")
 	installCmd.Flags().BoolVar(&autoYes, "yes", false, "automatically answer yes to all prompts")
+	addOverrideFlags(installCmd)
 	
 	rootCmd.AddCommand(installCmd)
 }
\ No newline at end of file
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/lint"
+	"runcomfy/pkg/workflow"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <workflow>...",
+	Short: "Check workflows against team conventions",
+	Long: `Check workflows against conventions that go beyond validity, such as
+fixed seeds, relative paths and safetensors-only models.
+
+Rules are enabled, given a severity and configured under lint.rules in
+.runcomfy.yaml, which is looked up in the current directory before your home
+directory:
+
+  lint:
+    rules:
+      no-randomized-seed: error
+      no-preview-only-output: off
+      safetensors-only:
+        extensions: [.safetensors, .gguf]
+
+Each rule maps to off, error or warning, or to an object with a severity and
+the rule's options. Use --list-rules to see the available rules.
+
+Findings are printed as a table, or with --output json or --output sarif
+in a form tools can read; SARIF findings point at the line of their node so
+they show up in code review. The command fails when any finding is an error.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if lintListRules {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: runLint,
+}
+
+var (
+	lintListRules bool
+	lintDisable   []string
+)
+
+type lintFileReport struct {
+	WorkflowPath string           `json:"workflowPath"`
+	Issues       []workflow.Issue `json:"issues"`
+}
+
+type lintReport struct {
+	Files    []lintFileReport `json:"files"`
+	Errors   int              `json:"errors"`
+	Warnings int              `json:"warnings"`
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	outputFormat := viper.GetString("output")
+
+	cfg, err := lint.ParseConfig(viper.GetStringMap("lint.rules"))
+	if err != nil {
+		return fmt.Errorf("invalid lint configuration: %w", err)
+	}
+	for _, id := range lintDisable {
+		rc := cfg[id]
+		rc.Disabled = true
+		cfg[id] = rc
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	linter, err := lint.New(cfg, registry)
+	if err != nil {
+		return err
+	}
+
+	if lintListRules {
+		return outputLintRules(linter)
+	}
+
+	report := lintReport{Files: []lintFileReport{}}
+	var files []lint.FileResult
+	for _, path := range args {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("workflow file not found: %s", path)
+			}
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		w, err := loadWorkflow(path, registry)
+		if err != nil {
+			return err
+		}
+
+		issues := linter.Lint(w)
+		if issues == nil {
+			issues = []workflow.Issue{}
+		}
+		for _, issue := range issues {
+			if issue.Severity == workflow.SeverityError {
+				report.Errors++
+			} else {
+				report.Warnings++
+			}
+		}
+		report.Files = append(report.Files, lintFileReport{WorkflowPath: path, Issues: issues})
+		files = append(files, lint.FileResult{Path: filepath.ToSlash(path), Data: data, Issues: issues})
+	}
+
+	switch outputFormat {
+	case "json":
+		err = writeJSON(report)
+	case "sarif":
+		err = writeJSON(linter.SARIF(rootCmd.Version, files))
+	case "table":
+		outputLintTable(report)
+	default:
+		return fmt.Errorf("unsupported output format: %s (expected table, json or sarif)", outputFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Errors > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d lint error(s) found", report.Errors)
+	}
+	return nil
+}
+
+func writeJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func outputLintTable(report lintReport) {
+	for _, file := range report.Files {
+		fmt.Printf("📁 Workflow: %s\n", file.WorkflowPath)
+		if len(file.Issues) == 0 {
+			fmt.Printf("✅ No findings.\n\n")
+			continue
+		}
+		for _, issue := range file.Issues {
+			marker := "🔴"
+			if issue.Severity == workflow.SeverityWarning {
+				marker = "🟡"
+			}
+			location := "workflow"
+			if issue.NodeID != "" {
+				location = fmt.Sprintf("node %s", issue.NodeID)
+				if issue.NodeType != "" {
+					location += fmt.Sprintf(" (%s)", issue.NodeType)
+				}
+			}
+			fmt.Printf("  %s [%s] %s: %s\n", marker, issue.Code, location, issue.Message)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("📊 Summary: %d error(s), %d warning(s)\n", report.Errors, report.Warnings)
+}
+
+func outputLintRules(linter *lint.Linter) error {
+	enabled := make(map[string]bool)
+	for _, rule := range linter.Rules() {
+		enabled[rule.ID()] = true
+	}
+
+	if viper.GetString("output") == "json" {
+		type ruleInfo struct {
+			ID          string            `json:"id"`
+			Description string            `json:"description"`
+			Enabled     bool              `json:"enabled"`
+			Severity    workflow.Severity `json:"severity,omitempty"`
+		}
+		var rules []ruleInfo
+		for _, rule := range lint.Available() {
+			rules = append(rules, ruleInfo{rule.ID(), rule.Description(), enabled[rule.ID()], linter.RuleSeverity(rule.ID())})
+		}
+		return writeJSON(rules)
+	}
+
+	for _, rule := range lint.Available() {
+		level := "off"
+		if enabled[rule.ID()] {
+			level = string(linter.RuleSeverity(rule.ID()))
+		}
+		fmt.Printf("  %-24s %-8s %s\n", rule.ID(), level, rule.Description())
+	}
+	return nil
+}
+
+func init() {
+	lintCmd.Flags().BoolVar(&lintListRules, "list-rules", false, "list the available rules and their configured severity")
+	lintCmd.Flags().StringSliceVar(&lintDisable, "disable", nil, "rules to turn off for this run")
+	addOverrideFlags(lintCmd)
+
+	rootCmd.AddCommand(lintCmd)
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/migrate"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate <workflow> [output.json]",
+	Short: "Replace deprecated and renamed nodes in a workflow",
+	Long: `Rewrite a workflow that uses deprecated or renamed node types so that it
+uses their replacements, renaming inputs and rearranging widget values as the
+new nodes expect. Nodes inside subgraphs and group nodes are migrated too.
+
+Migrations for deprecated ComfyUI core nodes and for the apply nodes that
+ComfyUI_IPAdapter_plus v2 removed are built in. Other custom node packs that
+rename their nodes can be covered with YAML files of rules, given with
+--rules or in the config file:
+
+  migrate:
+    rules: [migrations.yaml]
+
+Each rule names the old and new node type and, optionally, the inputs to
+rename and the widget values of both nodes:
+
+  - from: OldSampler
+    to: NewSampler
+    inputs: {latent: latent_image}
+    from_widgets: [seed, control_after_generate, steps]
+    to_widgets: [seed, control_after_generate, steps, denoise]
+    defaults: {denoise: 1.0}
+
+Rules from files replace built-in rules for the same node type. Use
+--list-rules to see the rules in effect.
+
+The migrated workflow is written in canonical form to output.json, or to
+stdout when it is omitted, and the changes are reported. Nodes that cannot
+be migrated, for example because a connected input has no counterpart, are
+reported and left unchanged. With --check nothing is written and the
+command exits with an error if any node would be migrated.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if migrateListRules {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.RangeArgs(1, 2)(cmd, args)
+	},
+	RunE: runMigrate,
+}
+
+var (
+	migrateCheck     bool
+	migrateListRules bool
+)
+
+type migrateReport struct {
+	WorkflowPath string           `json:"workflowPath"`
+	Changes      []migrate.Change `json:"changes"`
+}
+
+// loadMigrator builds a migrator from the built-in rules and the rule files
+// set with --rules or in the config file.
+func loadMigrator() (*migrate.Migrator, error) {
+	rules := migrate.Builtin()
+	for _, path := range viper.GetStringSlice("migrate.rules") {
+		fromFile, err := migrate.LoadRules(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fromFile...)
+	}
+	migrator, err := migrate.New(rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid migration rules: %w", err)
+	}
+	return migrator, nil
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	verbose := viper.GetBool("verbose")
+
+	migrator, err := loadMigrator()
+	if err != nil {
+		return err
+	}
+	if migrateListRules {
+		return outputMigrationRules(migrator)
+	}
+
+	workflowPath := args[0]
+	if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
+		return fmt.Errorf("workflow file not found: %s", workflowPath)
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
+	}
+
+	changes, err := migrator.Migrate(w)
+	if err != nil {
+		return err
+	}
+
+	// Keep stdout for the workflow when it is written there.
+	report := io.Writer(os.Stdout)
+	if len(args) < 2 && !migrateCheck {
+		report = os.Stderr
+	}
+	if err := outputMigrateReport(report, migrateReport{workflowPath, changes}); err != nil {
+		return err
+	}
+
+	if migrateCheck {
+		migrated := 0
+		for _, change := range changes {
+			if change.Skipped == "" {
+				migrated++
+			}
+		}
+		if migrated > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d node(s) would be migrated", migrated)
+		}
+		return nil
+	}
+
+	data, err := encodeWorkflow(w)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[1], data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", args[1], err)
+	}
+	if verbose {
+		fmt.Printf("Wrote migrated workflow to %s\n", args[1])
+	}
+	return nil
+}
+
+func outputMigrateReport(out io.Writer, report migrateReport) error {
+	if viper.GetString("output") == "json" {
+		if report.Changes == nil {
+			report.Changes = []migrate.Change{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	fmt.Fprintf(out, "📁 Workflow: %s\n", filepath.Base(report.WorkflowPath))
+	if len(report.Changes) == 0 {
+		fmt.Fprintf(out, "\n✅ Nothing to migrate\n")
+		return nil
+	}
+
+	var migrated, skipped []migrate.Change
+	for _, change := range report.Changes {
+		if change.Skipped != "" {
+			skipped = append(skipped, change)
+		} else {
+			migrated = append(migrated, change)
+		}
+	}
+
+	if len(migrated) > 0 {
+		fmt.Fprintf(out, "\n🔁 Migrated nodes: %d\n", len(migrated))
+		for _, change := range migrated {
+			fmt.Fprintf(out, "  - node %s: %s → %s\n", change.NodeID, change.From, change.To)
+			if len(change.Details) > 0 {
+				fmt.Fprintf(out, "    %s\n", strings.Join(change.Details, "; "))
+			}
+			if change.Note != "" {
+				fmt.Fprintf(out, "    %s\n", change.Note)
+			}
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(out, "\n🟡 Not migrated: %d\n", len(skipped))
+		for _, change := range skipped {
+			fmt.Fprintf(out, "  - node %s: %s → %s: %s\n", change.NodeID, change.From, change.To, change.Skipped)
+		}
+	}
+	return nil
+}
+
+func outputMigrationRules(migrator *migrate.Migrator) error {
+	rules := migrator.Rules()
+	if viper.GetString("output") == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rules)
+	}
+
+	for _, rule := range rules {
+		fmt.Printf("  %s → %s\n", rule.From, rule.To)
+		if rule.Note != "" {
+			fmt.Printf("    %s\n", rule.Note)
+		}
+	}
+	return nil
+}
+
+func init() {
+	migrateCmd.Flags().StringSlice("rules", nil, "YAML files of migration rules to use besides the built-in ones")
+	migrateCmd.Flags().BoolVar(&migrateCheck, "check", false, "report what would be migrated and exit with an error if anything would")
+	migrateCmd.Flags().BoolVar(&migrateListRules, "list-rules", false, "list the migration rules in effect")
+	addOverrideFlags(migrateCmd)
+
+	viper.BindPFlag("migrate.rules", migrateCmd.Flags().Lookup("rules"))
+
+	rootCmd.AddCommand(migrateCmd)
+}
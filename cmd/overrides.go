@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"runcomfy/pkg/nodeschema"
+	"runcomfy/pkg/workflow"
+)
+
+var (
+	overrideValues []string
+	overridesFile  string
+)
+
+// addOverrideFlags lets a command patch widget values of the workflow it
+// loads through loadWorkflow or readWorkflow.
+func addOverrideFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&overrideValues, "set", nil, "override a widget value: [node=]<id|title>.<input>=<value> or <input>=<value> (repeatable)")
+	cmd.Flags().StringVar(&overridesFile, "overrides", "", "YAML file of widget value overrides")
+}
+
+// parseOverrides collects the overrides from --overrides and then --set, so
+// that values given on the command line win.
+func parseOverrides() ([]workflow.Override, error) {
+	var overrides []workflow.Override
+	if overridesFile != "" {
+		fromFile, err := workflow.LoadOverrides(overridesFile)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, fromFile...)
+	}
+	for _, value := range overrideValues {
+		o, err := workflow.ParseOverride(value)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+// readWorkflow returns the workflow JSON at path with the overrides applied.
+func readWorkflow(path string, registry *nodeschema.Registry) ([]byte, error) {
+	data, err := workflow.ReadWorkflow(path)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := parseOverrides()
+	if err != nil || len(overrides) == 0 {
+		return data, err
+	}
+	return workflow.ApplyOverrides(data, overrides, registry)
+}
+
+// loadWorkflow parses the workflow at path with the overrides applied.
+func loadWorkflow(path string, registry *nodeschema.Registry) (*workflow.Workflow, error) {
+	if overridesFile == "" && len(overrideValues) == 0 {
+		w, err := workflow.ParseWorkflow(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse workflow: %w", err)
+		}
+		return w, nil
+	}
+
+	data, err := readWorkflow(path, registry)
+	if err != nil {
+		return nil, err
+	}
+	w, err := workflow.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse workflow: %w", err)
+	}
+	return w, nil
+}
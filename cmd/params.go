@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/workflow"
+)
+
+var paramsCmd = &cobra.Command{
+	Use:   "params <workflow> [schema.json]",
+	Short: "Describe a workflow's tunable inputs as a JSON Schema",
+	Long: `List the values a user can change to run a workflow differently, such as
+prompts, seeds, image sizes, model choices and input images, as a JSON
+Schema.
+
+Each property is named by the "<node id>.<input>" selector that --set and
+--overrides accept, so values collected against the schema can be applied
+with the patch command. Types, ranges and allowed values come from the
+bundled core node definitions, or from those of your server when
+--object-info points to a dump of its /object_info endpoint. The workflow's
+current values become the defaults.
+
+Only active nodes are included, and widgets fed by other nodes are left
+out. Use --group to keep the nodes inside a named group, or --title-prefix
+to keep nodes (or the primitive nodes feeding them) whose title starts with
+a prefix, such as "Input:".
+
+The schema is written to schema.json, or to stdout when it is omitted. With
+--list the parameters are listed for reading instead.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runParams,
+}
+
+var (
+	paramsOptions workflow.ParamOptions
+	paramsList    bool
+)
+
+func runParams(cmd *cobra.Command, args []string) error {
+	workflowPath := args[0]
+	verbose := viper.GetBool("verbose")
+
+	if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
+		return fmt.Errorf("workflow file not found: %s", workflowPath)
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
+	}
+
+	params, err := w.Params(registry, paramsOptions)
+	if err != nil {
+		return err
+	}
+
+	if paramsList {
+		return outputParamsTable(workflowPath, params)
+	}
+
+	title := strings.TrimSuffix(filepath.Base(workflowPath), filepath.Ext(workflowPath))
+	data, err := json.MarshalIndent(workflow.ParamSchema(title, params), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema: %w", err)
+	}
+	data = append(data, '\n')
+
+	if len(args) < 2 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[1], data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", args[1], err)
+	}
+	if verbose {
+		fmt.Printf("Wrote %d parameter(s) to %s\n", len(params), args[1])
+	}
+	return nil
+}
+
+func outputParamsTable(workflowPath string, params []workflow.Param) error {
+	fmt.Printf("📁 Workflow: %s\n", filepath.Base(workflowPath))
+	fmt.Printf("📊 Parameters: %d\n\n", len(params))
+
+	for _, p := range params {
+		var details []string
+		if p.Type != "" {
+			details = append(details, p.Type)
+		}
+		if p.Min != nil || p.Max != nil {
+			lower, upper := "-inf", "+inf"
+			if p.Min != nil {
+				lower = fmt.Sprint(*p.Min)
+			}
+			if p.Max != nil {
+				upper = fmt.Sprint(*p.Max)
+			}
+			details = append(details, "["+lower+", "+upper+"]")
+		}
+		if len(p.Choices) > 0 {
+			details = append(details, fmt.Sprintf("%d choices", len(p.Choices)))
+		}
+		if p.Folder != "" {
+			details = append(details, "model in "+p.Folder)
+		}
+		if p.Upload != "" {
+			details = append(details, p.Upload+" upload")
+		}
+
+		value, _ := json.Marshal(p.Value)
+		fmt.Printf("  %s (%s): %s\n", p.Name, strings.Join(details, ", "), truncate(string(value), 60))
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+func init() {
+	paramsCmd.Flags().StringVar(&paramsOptions.Group, "group", "", "only include nodes inside the group with this title")
+	paramsCmd.Flags().StringVar(&paramsOptions.TitlePrefix, "title-prefix", "", "only include nodes whose title starts with this prefix")
+	paramsCmd.Flags().BoolVar(&paramsList, "list", false, "list the parameters instead of writing a schema")
+	addOverrideFlags(paramsCmd)
+
+	rootCmd.AddCommand(paramsCmd)
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/workflow"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune <workflow> [output.json]",
+	Short: "Remove nodes that do not lead to any output node",
+	Long: `Remove the branches of a workflow that no output node consumes, such as
+experiments left on the canvas, so that their loaders no longer pull in
+models and custom nodes.
+
+Links are followed back from every output node, muted and bypassed ones
+included; the nodes that are not reached are removed along with their
+links, and groups left without nodes are removed too. Subgraphs and group
+nodes are kept whole when anything inside them is reached, and notes are
+always kept.
+
+Output nodes are recognized by their definitions, the bundled ones or those
+given with --object-info; nodes of unknown type whose outputs nothing uses
+are taken for output nodes, to be safe. To keep only the branches that end
+in particular node types, list them with --outputs or in the config file:
+
+  prune:
+    outputs: [SaveImage, VHS_VideoCombine]
+
+The same setting applies to "runcomfy analyze --only-reachable".
+
+The pruned workflow is written in canonical form to output.json, or to
+stdout when it is omitted. The removed nodes are reported together with the
+custom nodes, models and input files the workflow no longer needs. With
+--check nothing is written and the command exits with an error if any node
+would be removed.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runPrune,
+}
+
+var pruneCheck bool
+
+type pruneReport struct {
+	WorkflowPath string `json:"workflowPath"`
+	*workflow.PruneResult
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	workflowPath := args[0]
+	verbose := viper.GetBool("verbose")
+
+	if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
+		return fmt.Errorf("workflow file not found: %s", workflowPath)
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
+	}
+
+	pruned, result := w.Prune(workflow.PruneOptions{
+		Outputs:  viper.GetStringSlice("prune.outputs"),
+		Registry: registry,
+	})
+
+	// Keep stdout for the workflow when it is written there.
+	report := io.Writer(os.Stdout)
+	if len(args) < 2 && !pruneCheck {
+		report = os.Stderr
+	}
+	if err := outputPruneReport(report, pruneReport{workflowPath, result}); err != nil {
+		return err
+	}
+
+	if pruneCheck {
+		if len(result.Nodes) > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d node(s) would be removed", len(result.Nodes))
+		}
+		return nil
+	}
+
+	data, err := encodeWorkflow(pruned)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[1], data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", args[1], err)
+	}
+	if verbose {
+		fmt.Printf("Wrote pruned workflow to %s\n", args[1])
+	}
+	return nil
+}
+
+func outputPruneReport(out io.Writer, report pruneReport) error {
+	if viper.GetString("output") == "json" {
+		if report.Nodes == nil {
+			report.Nodes = []workflow.PrunedNode{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	fmt.Fprintf(out, "📁 Workflow: %s\n", filepath.Base(report.WorkflowPath))
+	if len(report.Nodes) == 0 {
+		fmt.Fprintf(out, "\n✅ Every node leads to an output node\n")
+		return nil
+	}
+
+	fmt.Fprintf(out, "\n✂️  Removed nodes (%d):\n", len(report.Nodes))
+	for _, node := range report.Nodes {
+		if node.Title != "" && node.Title != node.Type {
+			fmt.Fprintf(out, "  - node %s (%s): %s\n", node.ID, node.Type, node.Title)
+		} else {
+			fmt.Fprintf(out, "  - node %s (%s)\n", node.ID, node.Type)
+		}
+	}
+	fmt.Fprintf(out, "  Links removed: %d\n", report.Links)
+	for _, group := range report.Groups {
+		fmt.Fprintf(out, "  Group removed: %s\n", group)
+	}
+
+	fmt.Fprintf(out, "\n📊 No longer needed: %d custom node type(s), %d file(s)\n", len(report.CustomNodes), len(report.Dependencies))
+	for _, nodeType := range report.CustomNodes {
+		fmt.Fprintf(out, "  - custom node: %s\n", nodeType)
+	}
+	for _, dep := range report.Dependencies {
+		fmt.Fprintf(out, "  - %s: %s\n", dep.Type, dep.Path)
+	}
+	return nil
+}
+
+func init() {
+	pruneCmd.Flags().StringSlice("outputs", nil, "node types whose branches are kept (default: the output nodes)")
+	pruneCmd.Flags().BoolVar(&pruneCheck, "check", false, "report what would be removed and exit with an error if anything would")
+	addOverrideFlags(pruneCmd)
+
+	viper.BindPFlag("prune.outputs", pruneCmd.Flags().Lookup("outputs"))
+
+	rootCmd.AddCommand(pruneCmd)
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"runcomfy/pkg/nodeschema"
 )
 
 var cfgFile string
@@ -28,14 +30,22 @@ func Execute() error {
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.runcomfy.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./.runcomfy.yaml or $HOME/.runcomfy.yaml)")
 	rootCmd.PersistentFlags().StringP("comfyui-path", "p", "/workspace/ComfyUI", "path to ComfyUI installation")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().StringP("output", "o", "table", "output format (table, json)")
+	rootCmd.PersistentFlags().String("object-info", "", "path to a ComfyUI /object_info JSON dump describing installed nodes")
 
 	viper.BindPFlag("comfyui-path", rootCmd.PersistentFlags().Lookup("comfyui-path"))
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("object-info", rootCmd.PersistentFlags().Lookup("object-info"))
+}
+
+// loadRegistry returns the node definitions from the configured /object_info
+// dump layered over the bundled core definitions.
+func loadRegistry() (*nodeschema.Registry, error) {
+	return nodeschema.LoadWithBundled(viper.GetString("object-info"))
 }
 
 func initConfig() {
@@ -45,6 +55,8 @@ func initConfig() {
 		home, err := os.UserHomeDir()
 		cobra.CheckErr(err)
 
+		// A project's own config takes precedence over the user's.
+		viper.AddConfigPath(".")
 		viper.AddConfigPath(home)
 		viper.SetConfigType("yaml")
 		viper.SetConfigName(".runcomfy")
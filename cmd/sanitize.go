@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/workflow"
+)
+
+var sanitizeCmd = &cobra.Command{
+	Use:   "sanitize <workflow> [output.json]",
+	Short: "Remove prompts, private paths and secrets before sharing a workflow",
+	Long: `Strip private information from a workflow before publishing it.
+
+The following rules are available, all but subfolders applied by default:
+
+  secrets     clear API keys, tokens and other credentials found in widget
+              values, node properties, model URLs and the workflow's extra
+              data
+  notes       clear the text of Note and MarkdownNote nodes
+  prompts     clear multiline text widgets such as prompts
+  subfolders  drop the subfolders of selected model files, which can carry
+              user names ("alice/sdxl/model.safetensors" becomes
+              "model.safetensors"); the workflow then loads the file from
+              the model folder itself, so only use it when the recipients
+              keep their models there
+  paths       reduce absolute file paths to their file name
+
+Select rules with --rules or leave some out with --skip. The rules can also
+be set in the config file:
+
+  sanitize:
+    rules: [secrets, notes, paths]
+
+Values are replaced rather than removed, so the sanitized workflow still
+loads in ComfyUI. Widgets are named using the bundled core node definitions,
+or those of your server when --object-info points to a dump of its
+/object_info endpoint.
+
+The sanitized workflow is written in canonical form to output.json, or to
+stdout when it is omitted, and the changes are reported. With --check
+nothing is written: the changes that would be made are reported and the
+command exits with an error if there are any.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runSanitize,
+}
+
+var (
+	sanitizeSkip  []string
+	sanitizeCheck bool
+)
+
+type sanitizeReport struct {
+	WorkflowPath string               `json:"workflowPath"`
+	Rules        []string             `json:"rules"`
+	Redactions   []workflow.Redaction `json:"redactions"`
+}
+
+func runSanitize(cmd *cobra.Command, args []string) error {
+	workflowPath := args[0]
+	verbose := viper.GetBool("verbose")
+
+	if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
+		return fmt.Errorf("workflow file not found: %s", workflowPath)
+	}
+
+	rules := sanitizeRules()
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
+	}
+
+	redactions, err := w.Sanitize(rules, registry)
+	if err != nil {
+		return err
+	}
+	workflow.SortRedactions(redactions)
+
+	// Keep stdout for the workflow when it is written there.
+	report := io.Writer(os.Stdout)
+	if len(args) < 2 && !sanitizeCheck {
+		report = os.Stderr
+	}
+	if err := outputSanitizeReport(report, sanitizeReport{workflowPath, rules, redactions}); err != nil {
+		return err
+	}
+
+	if sanitizeCheck {
+		if len(redactions) > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d value(s) would be sanitized", len(redactions))
+		}
+		return nil
+	}
+
+	data, err := encodeWorkflow(w)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[1], data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", args[1], err)
+	}
+	if verbose {
+		fmt.Printf("Wrote sanitized workflow to %s\n", args[1])
+	}
+	return nil
+}
+
+// encodeWorkflow writes a workflow in canonical form, in the format it was
+// read from.
+func encodeWorkflow(w *workflow.Workflow) ([]byte, error) {
+	var result interface{} = w
+	if w.Format == workflow.FormatAPI {
+		prompt, err := w.Prompt()
+		if err != nil {
+			return nil, err
+		}
+		result = prompt
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode workflow: %w", err)
+	}
+	if data, err = workflow.Canonicalize(data, workflow.CanonicalOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to encode workflow: %w", err)
+	}
+	return data, nil
+}
+
+// sanitizeRules returns the configured rules without the skipped ones.
+func sanitizeRules() []string {
+	rules := viper.GetStringSlice("sanitize.rules")
+	if len(rules) == 0 {
+		rules = workflow.DefaultSanitizeRules
+	}
+	skip := make(map[string]bool, len(sanitizeSkip))
+	for _, rule := range sanitizeSkip {
+		skip[rule] = true
+	}
+
+	var kept []string
+	for _, rule := range rules {
+		if !skip[rule] {
+			kept = append(kept, rule)
+		}
+	}
+	return kept
+}
+
+func outputSanitizeReport(out io.Writer, report sanitizeReport) error {
+	if viper.GetString("output") == "json" {
+		if report.Redactions == nil {
+			report.Redactions = []workflow.Redaction{}
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	fmt.Fprintf(out, "📁 Workflow: %s\n", filepath.Base(report.WorkflowPath))
+	fmt.Fprintf(out, "🧹 Rules: %s\n", strings.Join(report.Rules, ", "))
+	if len(report.Redactions) == 0 {
+		fmt.Fprintf(out, "\n✅ Nothing to sanitize\n")
+		return nil
+	}
+
+	fmt.Fprintf(out, "\n📊 Sanitized values: %d\n", len(report.Redactions))
+	for _, r := range report.Redactions {
+		location := r.Field
+		if r.NodeID != "" {
+			location = fmt.Sprintf("node %s (%s) %s", r.NodeID, r.NodeType, r.Field)
+		}
+		change := fmt.Sprintf("%q → %q", r.From, r.To)
+		if r.Reason != "" {
+			change += " (" + r.Reason + ")"
+		}
+		fmt.Fprintf(out, "  [%s] %s: %s\n", r.Rule, location, change)
+	}
+	return nil
+}
+
+func init() {
+	sanitizeCmd.Flags().StringSlice("rules", nil, "rules to apply, of "+strings.Join(workflow.SanitizeRules, ", ")+" (default "+strings.Join(workflow.DefaultSanitizeRules, ", ")+")")
+	sanitizeCmd.Flags().StringSliceVar(&sanitizeSkip, "skip", nil, "rules to leave out")
+	sanitizeCmd.Flags().BoolVar(&sanitizeCheck, "check", false, "report what would be sanitized and exit with an error if anything would")
+
+	viper.BindPFlag("sanitize.rules", sanitizeCmd.Flags().Lookup("rules"))
+
+	rootCmd.AddCommand(sanitizeCmd)
+}
@@ -63,6 +63,7 @@ func outputScanTable(result *scanner.ScanResult, verbose bool) error {
 	fmt.Printf("📊 Summary:\n")
 	fmt.Printf("  Custom Nodes: %d\n", len(result.CustomNodes))
 	fmt.Printf("  Models: %d\n", len(result.Models))
+	fmt.Printf("  Input Files: %d\n", len(result.Inputs))
 	fmt.Printf("  Total Files: %d\n\n", result.TotalFiles)
 
 	if len(result.CustomNodes) > 0 {
@@ -96,6 +97,13 @@ func outputScanTable(result *scanner.ScanResult, verbose bool) error {
 		}
 	}
 
+	if verbose && len(result.Inputs) > 0 {
+		fmt.Printf("\n🖼️  Input Files (%d):\n", len(result.Inputs))
+		for _, input := range result.Inputs {
+			fmt.Printf("  - %s (%.2f MB)\n", input.Path, float64(input.Size)/(1024*1024))
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/catalog"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search [dir]...",
+	Short: "Search the indexed workflows",
+	Long: `Search the workflows indexed with "runcomfy index", optionally only
+those under the given directories.
+
+  --node KSampler                  uses the node type
+  --pack comfyui-impact-pack       uses the custom node pack
+  --model sdxl                     uses a model file whose name contains this
+  --group Upscale                  has a group whose title contains this
+  --widget LoraLoader.strength_model>1
+                                   has a widget value satisfying this
+
+Each filter can be repeated, and a workflow must satisfy all of them. Node
+types and pack names are compared regardless of case.
+
+Widget filters are written [<node type>.]<input><op><value>, with the
+operators =, !=, <, <=, >, >= and ~ (contains); ordering only applies to
+numbers. Filters naming the same node type, or none, must all hold for the
+same node, so that
+
+  runcomfy search --widget "LoraLoader.lora_name~detail" --widget "LoraLoader.strength_model>1"
+
+finds the workflows applying that LoRA at a strength above 1. The matching
+nodes are listed with each workflow.`,
+	RunE: runSearch,
+}
+
+var (
+	searchNodes   []string
+	searchPacks   []string
+	searchModels  []string
+	searchGroups  []string
+	searchWidgets []string
+)
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	query := catalog.Query{
+		Nodes:  searchNodes,
+		Packs:  searchPacks,
+		Models: searchModels,
+		Groups: searchGroups,
+		Dirs:   args,
+	}
+	for _, value := range searchWidgets {
+		f, err := catalog.ParseWidgetFilter(value)
+		if err != nil {
+			return err
+		}
+		query.Widgets = append(query.Widgets, f)
+	}
+
+	path, err := catalogPath()
+	if err != nil {
+		return err
+	}
+	c, err := catalog.Load(path)
+	if err != nil {
+		return err
+	}
+	if len(c.Entries) == 0 {
+		return fmt.Errorf("the index at %s is empty; run \"runcomfy index <dir>\" first", path)
+	}
+
+	matches := c.Search(query)
+	if viper.GetString("output") == "json" {
+		if matches == nil {
+			matches = []catalog.Match{}
+		}
+		return writeJSON(matches)
+	}
+
+	if len(matches) == 0 {
+		fmt.Println("🔍 No workflow matches")
+		return nil
+	}
+	fmt.Printf("🔍 %d workflow(s) match:\n", len(matches))
+	for _, m := range matches {
+		fmt.Printf("\n📁 %s\n", m.Path)
+		for _, node := range m.Nodes {
+			line := fmt.Sprintf("  - node %s (%s)", node.ID, node.Type)
+			if node.Title != "" && node.Title != node.Type {
+				line += " " + node.Title
+			}
+			if node.Inactive {
+				line += " [inactive]"
+			}
+			if len(node.Widgets) > 0 {
+				line += ": " + formatWidgets(node.Widgets)
+			}
+			fmt.Println(line)
+		}
+		for _, model := range m.Models {
+			fmt.Printf("  - model: %s\n", model)
+		}
+	}
+	return nil
+}
+
+// formatWidgets lists widget values as name=value pairs in name order.
+func formatWidgets(widgets map[string]interface{}) string {
+	names := make([]string, 0, len(widgets))
+	for name := range widgets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%v", name, widgets[name])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func init() {
+	searchCmd.Flags().StringArrayVar(&searchNodes, "node", nil, "node type the workflow uses (repeatable)")
+	searchCmd.Flags().StringArrayVar(&searchPacks, "pack", nil, "custom node pack the workflow uses (repeatable)")
+	searchCmd.Flags().StringArrayVar(&searchModels, "model", nil, "part of the name of a model file the workflow uses (repeatable)")
+	searchCmd.Flags().StringArrayVar(&searchGroups, "group", nil, "part of the title of a group in the workflow (repeatable)")
+	searchCmd.Flags().StringArrayVar(&searchWidgets, "widget", nil, "widget value condition: [<node type>.]<input><op><value> (repeatable)")
+	searchCmd.Flags().StringVar(&indexPath, "index", "", "index file (default is index.json in runcomfy's cache directory)")
+
+	rootCmd.AddCommand(searchCmd)
+}
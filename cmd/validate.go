@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"runcomfy/pkg/workflow"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate <workflow.json>",
+	Short: "Check a ComfyUI workflow for structural and semantic errors",
+	Long: `Validate a ComfyUI workflow without running it.
+
+The workflow is checked for dangling links, cycles, connections between
+mismatched types, required inputs left unconnected, widget values outside
+the ranges or choices of their node definitions, and the absence of any
+output node.
+
+Widget values are checked against the bundled core node definitions, or
+against those of your server when --object-info points to a dump of its
+/object_info endpoint. The command exits with an error when any issue of
+error severity is found.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runValidate,
+}
+
+type validationReport struct {
+	WorkflowPath string           `json:"workflowPath"`
+	Format       string           `json:"format"`
+	Valid        bool             `json:"valid"`
+	Errors       int              `json:"errors"`
+	Warnings     int              `json:"warnings"`
+	Issues       []workflow.Issue `json:"issues"`
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	workflowPath := args[0]
+	outputFormat := viper.GetString("output")
+
+	if _, err := os.Stat(workflowPath); os.IsNotExist(err) {
+		return fmt.Errorf("workflow file not found: %s", workflowPath)
+	}
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	w, err := loadWorkflow(workflowPath, registry)
+	if err != nil {
+		return err
+	}
+
+	issues := w.Validate(registry)
+	report := &validationReport{
+		WorkflowPath: workflowPath,
+		Format:       string(w.Format),
+		Valid:        !workflow.HasErrors(issues),
+		Issues:       issues,
+	}
+	if report.Issues == nil {
+		report.Issues = []workflow.Issue{}
+	}
+	for _, issue := range issues {
+		if issue.Severity == workflow.SeverityError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+
+	switch outputFormat {
+	case "json":
+		err = outputValidateJSON(report)
+	case "table":
+		err = outputValidateTable(report)
+	default:
+		return fmt.Errorf("unsupported output format: %s", outputFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !report.Valid {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("workflow has %d error(s)", report.Errors)
+	}
+	return nil
+}
+
+func outputValidateJSON(report *validationReport) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func outputValidateTable(report *validationReport) error {
+	fmt.Printf("📁 Workflow: %s\n", filepath.Base(report.WorkflowPath))
+	if report.Format != "" {
+		fmt.Printf("🧩 Format: %s\n", report.Format)
+	}
+	fmt.Println()
+
+	printIssues := func(title string, severity workflow.Severity, count int) {
+		if count == 0 {
+			return
+		}
+		fmt.Printf("%s (%d):\n", title, count)
+		for _, issue := range report.Issues {
+			if issue.Severity != severity {
+				continue
+			}
+			location := "workflow"
+			if issue.NodeID != "" {
+				location = fmt.Sprintf("node %s", issue.NodeID)
+				if issue.NodeType != "" {
+					location += fmt.Sprintf(" (%s)", issue.NodeType)
+				}
+			}
+			fmt.Printf("  - [%s] %s: %s\n", issue.Code, location, issue.Message)
+		}
+		fmt.Println()
+	}
+	printIssues("🔴 Errors", workflow.SeverityError, report.Errors)
+	printIssues("🟡 Warnings", workflow.SeverityWarning, report.Warnings)
+
+	if len(report.Issues) == 0 {
+		fmt.Println("✅ No issues found.")
+	} else if report.Valid {
+		fmt.Println("✅ No errors found.")
+	}
+
+	return nil
+}
+
+func init() {
+	addOverrideFlags(validateCmd)
+
+	rootCmd.AddCommand(validateCmd)
+}
@@ -2,14 +2,38 @@ package analyzer
 
 import (
 	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 
+	"runcomfy/pkg/migrate"
+	"runcomfy/pkg/nodeschema"
 	"runcomfy/pkg/scanner"
 	"runcomfy/pkg/workflow"
 )
 
 type Analyzer struct {
 	installation *scanner.ComfyUIInstallation
+
+	// IncludeInactive reports dependencies of muted and bypassed nodes as
+	// optional instead of leaving them out.
+	IncludeInactive bool
+
+	// Registry supplies the node definitions used to name widget values and
+	// to tell the nodes that come with ComfyUI from custom ones. The bundled
+	// core definitions are used when it is nil.
+	Registry *nodeschema.Registry
+
+	// Migrator suggests replacements for missing node types. The built-in
+	// migrations are used when it is nil.
+	Migrator *migrate.Migrator
+
+	// OnlyReachable leaves out the nodes that are not upstream of an output
+	// node, as "runcomfy prune" does. Outputs overrides which node types
+	// count as output nodes.
+	OnlyReachable bool
+	Outputs       []string
 }
 
 func New(installation *scanner.ComfyUIInstallation) *Analyzer {
@@ -19,8 +43,13 @@ func New(installation *scanner.ComfyUIInstallation) *Analyzer {
 }
 
 func (a *Analyzer) AnalyzeWorkflow(w *workflow.Workflow) (*AnalysisResult, error) {
+	if a.OnlyReachable {
+		w, _ = w.Prune(workflow.PruneOptions{Outputs: a.Outputs, Registry: a.Registry})
+	}
+
 	result := &AnalysisResult{
 		WorkflowPath: "",
+		Format:       string(w.Format),
 		TotalNodes:   len(w.Nodes),
 		TotalModels:  len(w.Models),
 	}
@@ -32,11 +61,21 @@ func (a *Analyzer) AnalyzeWorkflow(w *workflow.Workflow) (*AnalysisResult, error
 	result.InstalledNodes = len(scanResult.CustomNodes)
 	result.InstalledModels = len(scanResult.Models)
 	
-	dependencies := w.ExtractDependencies()
+	dependencies := w.ExtractDependenciesUsing(a.Registry)
 	customNodes := w.GetCustomNodes()
 	
 	result.MissingNodes = a.findMissingNodes(customNodes, scanResult.CustomNodes)
-	result.MissingModels = a.findMissingModels(dependencies, scanResult.Models)
+	result.Models, result.Warnings = a.resolveModels(dependencies, w.ModelHints(), scanResult.Models)
+	for _, model := range result.Models {
+		if !model.Installed {
+			result.MissingModels = append(result.MissingModels, model)
+		}
+	}
+	result.MissingInputs = a.findMissingInputs(dependencies, scanResult.Inputs)
+	if a.IncludeInactive {
+		result.OptionalNodes = a.findMissingNodes(w.GetInactiveCustomNodes(), scanResult.CustomNodes)
+	}
+	result.Migrations = a.suggestMigrations(append(result.MissingNodes, result.OptionalNodes...))
 	
 	result.Summary = a.generateSummary(result)
 	
@@ -49,8 +88,22 @@ func (a *Analyzer) findMissingNodes(requiredNodes, installedNodes []string) []st
 		installedSet[node] = true
 	}
 	
+	reg := a.Registry
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	
 	var missing []string
 	for _, required := range requiredNodes {
+		// Nodes the registry defines come with ComfyUI unless their module
+		// is a custom node pack, which then has to be installed.
+		if def, ok := reg.Lookup(required); ok {
+			pack, custom := strings.CutPrefix(def.PythonModule, "custom_nodes.")
+			if custom && !installedSet[pack] && !installedSet[required] {
+				missing = append(missing, required)
+			}
+			continue
+		}
 		if !installedSet[required] && !isBuiltinNode(required) {
 			missing = append(missing, required)
 		}
@@ -59,40 +112,212 @@ func (a *Analyzer) findMissingNodes(requiredNodes, installedNodes []string) []st
 	return missing
 }
 
-func (a *Analyzer) findMissingModels(dependencies []workflow.Dependency, installedModels []scanner.FileInfo) []ModelDependency {
-	installedSet := make(map[string]bool)
+// suggestMigrations lists the missing node types that have a migration to
+// another node type.
+func (a *Analyzer) suggestMigrations(missingNodes []string) []NodeMigration {
+	migrator := a.Migrator
+	if migrator == nil {
+		migrator = migrate.Default()
+	}
+	
+	var migrations []NodeMigration
+	for _, node := range missingNodes {
+		chain := migrator.Chain(node)
+		if len(chain) == 0 {
+			continue
+		}
+		var notes []string
+		for _, rule := range chain {
+			if rule.Note != "" {
+				notes = append(notes, rule.Note)
+			}
+		}
+		migrations = append(migrations, NodeMigration{
+			Node:        node,
+			Replacement: chain[len(chain)-1].To,
+			Note:        strings.Join(notes, " "),
+		})
+	}
+	
+	return migrations
+}
+
+// resolveModels matches the model dependencies against the installed
+// models by their path relative to the models directory, such as
+// "checkpoints/SDXL/juggernaut.safetensors". ComfyUI loads models by the
+// exact file name, so a file differing only in its extension does not
+// count; it is listed as a candidate. A model not found at its path is
+// looked up by file name, preferring its own folder, and accepted with a
+// warning when that finds a single file; several files are listed as
+// candidates and the model is left missing. Download hints the workflow
+// carries are attached to the models they describe.
+func (a *Analyzer) resolveModels(dependencies []workflow.Dependency, hints map[string]workflow.Model, installedModels []scanner.FileInfo) ([]ModelDependency, []string) {
+	installedPaths := make(map[string]scanner.FileInfo)
+	installedStems := make(map[string][]string)
+	installedNames := make(map[string][]string)
+	installedFiles := make(map[string]scanner.FileInfo)
 	for _, model := range installedModels {
-		installedSet[model.Name] = true
+		modelPath := strings.TrimPrefix(filepath.ToSlash(model.Path), "models/")
+		installedPaths[matchKey(modelPath)] = model
+		installedFiles[modelPath] = model
 		
-		baseName := strings.TrimSuffix(model.Name, ".safetensors")
-		baseName = strings.TrimSuffix(baseName, ".ckpt")
-		baseName = strings.TrimSuffix(baseName, ".pt")
-		baseName = strings.TrimSuffix(baseName, ".pth")
-		baseName = strings.TrimSuffix(baseName, ".bin")
-		installedSet[baseName] = true
+		stem := matchKey(trimModelExt(modelPath))
+		installedStems[stem] = append(installedStems[stem], modelPath)
+		name := trimModelExt(path.Base(modelPath))
+		installedNames[name] = append(installedNames[name], modelPath)
+	}
+	
+	urls := make(map[string]string)
+	for hintPath, hint := range hints {
+		if hint.URL != "" {
+			urls[matchKey(hintPath)] = hint.URL
+		}
 	}
 	
-	var missing []ModelDependency
+	required := make(map[string]bool)
+	for _, dep := range dependencies {
+		if dep.Type == "model" && !dep.Optional {
+			required[matchKey(dep.Path)] = true
+		}
+	}
+	
+	var models []ModelDependency
+	var warnings []string
 	seen := make(map[string]bool)
 	
 	for _, dep := range dependencies {
-		if dep.Type == "model" && dep.Name != "" && !seen[dep.Name] {
-			seen[dep.Name] = true
-			
-			baseName := strings.TrimSuffix(dep.Name, ".safetensors")
-			baseName = strings.TrimSuffix(baseName, ".ckpt")
-			baseName = strings.TrimSuffix(baseName, ".pt")
-			baseName = strings.TrimSuffix(baseName, ".pth")
-			baseName = strings.TrimSuffix(baseName, ".bin")
-			
-			if !installedSet[dep.Name] && !installedSet[baseName] {
-				missing = append(missing, ModelDependency{
-					Name:         dep.Name,
-					Path:         dep.Path,
-					Category:     inferModelCategory(dep.Path),
-					Required:     true,
-				})
+		key := matchKey(dep.Path)
+		if dep.Type != "model" || dep.Name == "" || seen[key] {
+			continue
+		}
+		if !required[key] && !a.IncludeInactive {
+			continue
+		}
+		seen[key] = true
+		
+		folder, name := splitModelPath(dep.Path)
+		subfolder := path.Dir(name)
+		if subfolder == "." {
+			subfolder = ""
+		}
+		target := "models/" + dep.Path
+		if folder == "models" {
+			target = dep.Path
+		}
+		model := ModelDependency{
+			Name:        dep.Name,
+			Path:        dep.Path,
+			Category:    inferModelCategory(dep.Path),
+			Subfolder:   subfolder,
+			Target:      target,
+			Required:    required[key],
+			DownloadURL: urls[key],
+		}
+		installed := func(file scanner.FileInfo) {
+			model.Installed = true
+			model.Target = filepath.ToSlash(file.Path)
+			model.Size = file.Size
+		}
+		// References without an extension, as some loaders store them, match
+		// a file with any model extension.
+		hasExt := trimModelExt(name) != name
+		
+		if file, ok := installedPaths[key]; ok {
+			installed(file)
+			models = append(models, model)
+			continue
+		}
+		if stems := installedStems[matchKey(trimModelExt(dep.Path))]; len(stems) > 0 {
+			sort.Strings(stems)
+			switch {
+			case !hasExt && len(stems) == 1:
+				installed(installedFiles[stems[0]])
+			case hasExt:
+				warnings = append(warnings, fmt.Sprintf("model %s not found at %s; %s differs only in the file extension", dep.Name, dep.Path, strings.Join(stems, ", ")))
+				model.Candidates = stems
+			default:
+				warnings = append(warnings, fmt.Sprintf("model %s not found at %s; file name is ambiguous, matching %s", dep.Name, dep.Path, strings.Join(stems, ", ")))
+				model.Candidates = stems
+			}
+			models = append(models, model)
+			continue
+		}
+		
+		var candidates, sameFolder, sameName, otherExt []string
+		for _, candidate := range installedNames[trimModelExt(path.Base(name))] {
+			candidateFolder, candidateName := splitModelPath(candidate)
+			if hasExt && path.Base(candidate) != path.Base(name) {
+				otherExt = append(otherExt, candidate)
+				continue
+			}
+			candidates = append(candidates, candidate)
+			if nodeschema.CanonicalFolder(candidateFolder) == nodeschema.CanonicalFolder(folder) {
+				sameFolder = append(sameFolder, candidate)
 			}
+			if candidateName == name || !hasExt && trimModelExt(candidateName) == name {
+				sameName = append(sameName, candidate)
+			}
+		}
+		if len(sameFolder) > 0 {
+			candidates = sameFolder
+		}
+		sort.Strings(candidates)
+		sort.Strings(otherExt)
+		
+		switch {
+		case folder == "models" && len(sameName) == 1:
+			// The folder was guessed from the node type; the file is where
+			// the loader expects it.
+			installed(installedFiles[sameName[0]])
+		case len(candidates) == 1:
+			warnings = append(warnings, fmt.Sprintf("model %s not found at %s; matched %s by file name", dep.Name, dep.Path, candidates[0]))
+			installed(installedFiles[candidates[0]])
+		case len(candidates) > 1:
+			// Which of the files is meant cannot be told, so the model is
+			// left missing with the files listed.
+			warnings = append(warnings, fmt.Sprintf("model %s not found at %s; file name is ambiguous, matching %s", dep.Name, dep.Path, strings.Join(candidates, ", ")))
+			model.Candidates = candidates
+		case len(otherExt) > 0:
+			warnings = append(warnings, fmt.Sprintf("model %s not found at %s; %s differs only in the file extension", dep.Name, dep.Path, strings.Join(otherExt, ", ")))
+			model.Candidates = otherExt
+		}
+		models = append(models, model)
+	}
+	
+	return models, warnings
+}
+
+func (a *Analyzer) findMissingInputs(dependencies []workflow.Dependency, installedInputs []scanner.FileInfo) []InputDependency {
+	installedSet := make(map[string]bool)
+	for _, input := range installedInputs {
+		installedSet[filepath.ToSlash(input.Path)] = true
+	}
+	
+	required := make(map[string]bool)
+	for _, dep := range dependencies {
+		if dep.Type == "input" && !dep.Optional {
+			required[dep.Path] = true
+		}
+	}
+	
+	var missing []InputDependency
+	seen := make(map[string]bool)
+	
+	for _, dep := range dependencies {
+		if dep.Type != "input" || seen[dep.Path] {
+			continue
+		}
+		if !required[dep.Path] && !a.IncludeInactive {
+			continue
+		}
+		seen[dep.Path] = true
+		
+		if !installedSet[dep.Path] {
+			missing = append(missing, InputDependency{
+				Name:     dep.Name,
+				Path:     dep.Path,
+				Required: required[dep.Path],
+			})
 		}
 	}
 	
@@ -106,8 +331,30 @@ func (a *Analyzer) generateSummary(result *AnalysisResult) string {
 		parts = append(parts, fmt.Sprintf("%d missing custom nodes", len(result.MissingNodes)))
 	}
 	
-	if len(result.MissingModels) > 0 {
-		parts = append(parts, fmt.Sprintf("%d missing models", len(result.MissingModels)))
+	optionalModels := 0
+	for _, model := range result.MissingModels {
+		if !model.Required {
+			optionalModels++
+		}
+	}
+	
+	if requiredModels := len(result.MissingModels) - optionalModels; requiredModels > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing models", requiredModels))
+	}
+	
+	optionalInputs := 0
+	for _, input := range result.MissingInputs {
+		if !input.Required {
+			optionalInputs++
+		}
+	}
+	
+	if requiredInputs := len(result.MissingInputs) - optionalInputs; requiredInputs > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing input files", requiredInputs))
+	}
+	
+	if optional := len(result.OptionalNodes) + optionalModels + optionalInputs; optional > 0 {
+		parts = append(parts, fmt.Sprintf("%d optional from inactive nodes", optional))
 	}
 	
 	if len(parts) == 0 {
@@ -117,6 +364,8 @@ func (a *Analyzer) generateSummary(result *AnalysisResult) string {
 	return "Missing: " + strings.Join(parts, ", ")
 }
 
+// isBuiltinNode reports whether a node type the registry does not define
+// is known to come with ComfyUI.
 func isBuiltinNode(nodeType string) bool {
 	builtinNodes := map[string]bool{
 		"CheckpointLoaderSimple":  true,
@@ -267,27 +516,52 @@ func isBuiltinNode(nodeType string) bool {
 		"SamplerDEIS":             true,
 		"PhotoMakerLoader":        true,
 		"PhotoMakerEncode":        true,
+		"PrimitiveString":         true,
+		"PrimitiveStringMultiline": true,
+		"PrimitiveInt":            true,
+		"PrimitiveFloat":          true,
+		"PrimitiveBoolean":        true,
 	}
 	
 	return builtinNodes[nodeType]
 }
 
-func inferModelCategory(path string) string {
-	path = strings.ToLower(path)
-	switch {
-	case strings.Contains(path, "checkpoint"):
-		return "checkpoints"
-	case strings.Contains(path, "lora"):
-		return "loras"
-	case strings.Contains(path, "vae"):
-		return "vae"
-	case strings.Contains(path, "controlnet"):
-		return "controlnet"
-	case strings.Contains(path, "upscale"):
-		return "upscale_models"
-	case strings.Contains(path, "embedding"):
-		return "embeddings"
-	default:
+// inferModelCategory returns the model folder of a dependency path, or
+// "models" when the folder is unknown.
+func inferModelCategory(modelPath string) string {
+	folder, _ := splitModelPath(modelPath)
+	if folder == "" {
 		return "models"
 	}
-}
\ No newline at end of file
+	return folder
+}
+
+// splitModelPath splits a model path into its folder and the name relative
+// to that folder, which may include subfolders.
+func splitModelPath(modelPath string) (string, string) {
+	if i := strings.Index(modelPath, "/"); i >= 0 {
+		return modelPath[:i], modelPath[i+1:]
+	}
+	return "", modelPath
+}
+
+// matchKey identifies a model path for matching, treating the folders
+// ComfyUI searches together as one.
+func matchKey(modelPath string) string {
+	folder, name := splitModelPath(modelPath)
+	if folder == "" {
+		return name
+	}
+	return nodeschema.CanonicalFolder(folder) + "/" + name
+}
+
+// trimModelExt strips a model file extension, so that references without
+// one can be matched.
+func trimModelExt(name string) string {
+	for _, ext := range []string{".safetensors", ".ckpt", ".pt", ".pth", ".bin", ".gguf", ".sft"} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
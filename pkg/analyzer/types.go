@@ -2,20 +2,52 @@ package analyzer
 
 type AnalysisResult struct {
 	WorkflowPath     string            `json:"workflowPath"`
+	Format           string            `json:"format"`
 	TotalNodes       int               `json:"totalNodes"`
 	TotalModels      int               `json:"totalModels"`
 	InstalledNodes   int               `json:"installedNodes"`
 	InstalledModels  int               `json:"installedModels"`
+	Models           []ModelDependency `json:"models,omitempty"`
 	MissingNodes     []string          `json:"missingNodes"`
+	OptionalNodes    []string          `json:"optionalNodes,omitempty"`
 	MissingModels    []ModelDependency `json:"missingModels"`
+	MissingInputs    []InputDependency `json:"missingInputs"`
+	Migrations       []NodeMigration   `json:"migrations,omitempty"`
+	Warnings         []string          `json:"warnings,omitempty"`
 	Summary          string            `json:"summary"`
 }
 
+// ModelDependency is a model the workflow loads. Path is relative to the
+// models directory and Subfolder to the model's folder; Target is where the
+// file belongs relative to the ComfyUI directory, or for installed models
+// where it was found. Size is only known for installed models. Candidates
+// lists installed files a missing model may be, such as one with another
+// extension, relative to the models directory.
 type ModelDependency struct {
-	Name         string `json:"name"`
-	Path         string `json:"path"`
-	Category     string `json:"category"`
-	Required     bool   `json:"required"`
-	DownloadURL  string `json:"downloadUrl,omitempty"`
-	Size         int64  `json:"size,omitempty"`
-}
\ No newline at end of file
+	Name         string   `json:"name"`
+	Path         string   `json:"path"`
+	Category     string   `json:"category"`
+	Subfolder    string   `json:"subfolder,omitempty"`
+	Target       string   `json:"target"`
+	Required     bool     `json:"required"`
+	Installed    bool     `json:"installed"`
+	DownloadURL  string   `json:"downloadUrl,omitempty"`
+	Size         int64    `json:"size,omitempty"`
+	Candidates   []string `json:"candidates,omitempty"`
+}
+
+// InputDependency is a file the workflow loads from ComfyUI's input
+// directory, such as an image for LoadImage.
+type InputDependency struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Required bool   `json:"required"`
+}
+
+// NodeMigration suggests replacing a missing node type with the one that
+// superseded it, as "runcomfy migrate" does.
+type NodeMigration struct {
+	Node        string `json:"node"`
+	Replacement string `json:"replacement"`
+	Note        string `json:"note,omitempty"`
+}
@@ -0,0 +1,226 @@
+// Package bundle ships a workflow together with a manifest of what it needs
+// to run: the custom node packs at the commits installed, the model files
+// with their sizes, hashes and download URLs, and the input files, which can
+// be included. A bundle is a zip archive created from a local installation
+// with Create and restored onto another one with Bundle.Install.
+package bundle
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ManifestName is the name of the manifest inside a bundle.
+const ManifestName = "manifest.json"
+
+// manifestVersion is bumped when the manifest changes incompatibly.
+const manifestVersion = 1
+
+// Manifest describes a bundle's workflow and its dependencies. Paths use
+// forward slashes and are relative to the ComfyUI directory.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Workflow is the name of the workflow file in the bundle.
+	Workflow    string       `json:"workflow"`
+	CustomNodes []CustomNode `json:"customNodes"`
+	Models      []Model      `json:"models"`
+	Inputs      []Input      `json:"inputs"`
+}
+
+// CustomNode is a custom node pack the workflow uses.
+type CustomNode struct {
+	// Name is the directory the pack was installed in under custom_nodes.
+	// It is empty when the pack was not found in the installation, in which
+	// case the other fields come from what the editor recorded in the
+	// workflow.
+	Name       string `json:"name,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Commit     string `json:"commit,omitempty"`
+	// RegistryID and Version identify the pack in the Comfy registry.
+	RegistryID string `json:"registryId,omitempty"`
+	Version    string `json:"version,omitempty"`
+	// NodeTypes lists the node types the workflow uses from the pack.
+	NodeTypes []string `json:"nodeTypes"`
+	// Required is unset when only muted or bypassed nodes use the pack.
+	Required bool `json:"required"`
+}
+
+// Model is a model file the workflow loads. Path is relative to the models
+// directory, as the workflow refers to it, and Target is where the file goes.
+// Size and SHA256 are only known for models found in the installation.
+type Model struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Target   string `json:"target"`
+	URL      string `json:"url,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Required bool   `json:"required"`
+}
+
+// Input is a file the workflow loads from the input directory. Bundled ones
+// are stored in the bundle under their Path.
+type Input struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Bundled  bool   `json:"bundled,omitempty"`
+	Required bool   `json:"required"`
+}
+
+// Bundle is an open bundle archive.
+type Bundle struct {
+	Manifest *Manifest
+
+	archive *zip.ReadCloser
+	files   map[string]*zip.File
+}
+
+// Open opens the bundle at path and reads its manifest.
+func Open(path string) (*Bundle, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	b := &Bundle{archive: archive, files: make(map[string]*zip.File)}
+	for _, f := range archive.File {
+		b.files[f.Name] = f
+	}
+
+	data, err := b.read(ManifestName)
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("%s is not a bundle: %w", path, err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+	if m.Version != manifestVersion {
+		archive.Close()
+		return nil, fmt.Errorf("unsupported bundle version %d (expected %d)", m.Version, manifestVersion)
+	}
+	b.Manifest = &m
+	return b, nil
+}
+
+// Close closes the archive.
+func (b *Bundle) Close() error {
+	return b.archive.Close()
+}
+
+// Workflow returns the workflow JSON stored in the bundle.
+func (b *Bundle) Workflow() ([]byte, error) {
+	return b.read(b.Manifest.Workflow)
+}
+
+func (b *Bundle) open(name string) (io.ReadCloser, error) {
+	f, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is missing from the bundle", name)
+	}
+	return f.Open()
+}
+
+func (b *Bundle) read(name string) ([]byte, error) {
+	r, err := b.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// commitPattern matches a git commit id, full or abbreviated.
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// validRepository reports whether repo is an https or scp-like SSH
+// (git@host:path) URL. Other transports can run commands, and a value
+// starting with a dash would be taken by git as an option.
+func validRepository(repo string) bool {
+	if strings.ContainsAny(repo, " \t\r\n") {
+		return false
+	}
+	if rest, ok := strings.CutPrefix(repo, "https://"); ok {
+		return rest != "" && !strings.HasPrefix(rest, "-")
+	}
+	if rest, ok := strings.CutPrefix(repo, "git@"); ok {
+		host, repoPath, ok := strings.Cut(rest, ":")
+		return ok && host != "" && !strings.HasPrefix(host, "-") && repoPath != ""
+	}
+	return false
+}
+
+// fileName reports whether name is a plain file or directory name, which
+// cannot reach outside the directory it is joined to.
+func fileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// inside reports whether name is a relative path below dir, with forward
+// slashes and no "." or ".." components.
+func inside(name, dir string) bool {
+	rest, ok := strings.CutPrefix(name, dir+"/")
+	if !ok {
+		return false
+	}
+	for _, part := range strings.Split(rest, "/") {
+		if !fileName(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// packDir is the directory under custom_nodes a pack is installed in: its
+// recorded name, else the name of its repository. It is empty for packs
+// only known from the registry.
+func packDir(pack CustomNode) string {
+	if pack.Name != "" || pack.Repository == "" {
+		return pack.Name
+	}
+	return strings.TrimSuffix(path.Base(strings.TrimSuffix(pack.Repository, "/")), ".git")
+}
+
+// validate checks that every entry of the manifest is installed where it
+// belongs: packs in a directory of custom_nodes from an https or SSH
+// repository at a commit id, models below models and input files below
+// input. Bundles are shared, so an entry pointing anywhere else, such as an
+// input file written into a custom node pack that ComfyUI would run, makes
+// the whole bundle invalid.
+func (m *Manifest) validate() error {
+	if !fileName(m.Workflow) {
+		return fmt.Errorf("invalid workflow name %q", m.Workflow)
+	}
+	for _, pack := range m.CustomNodes {
+		if dir := packDir(pack); dir != "" && !fileName(dir) {
+			return fmt.Errorf("invalid custom node directory %q", dir)
+		}
+		if pack.Repository != "" && !validRepository(pack.Repository) {
+			return fmt.Errorf("invalid repository %q: only https:// and git@ URLs are supported", pack.Repository)
+		}
+		if pack.Commit != "" && !commitPattern.MatchString(pack.Commit) {
+			return fmt.Errorf("invalid commit %q for %s", pack.Commit, pack.Repository)
+		}
+	}
+	for _, model := range m.Models {
+		if !inside(model.Target, "models") {
+			return fmt.Errorf("invalid model target %q: models must go below models/", model.Target)
+		}
+	}
+	for _, input := range m.Inputs {
+		if !inside(input.Path, "input") {
+			return fmt.Errorf("invalid input path %q: input files must go below input/", input.Path)
+		}
+	}
+	return nil
+}
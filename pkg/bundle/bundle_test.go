@@ -0,0 +1,147 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"ComfyUI-Manager", true},
+		{"workflow.json", true},
+		{"..hidden", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"/etc", false},
+	}
+	for _, tt := range tests {
+		if got := fileName(tt.name); got != tt.want {
+			t.Errorf("fileName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInside(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  string
+		want bool
+	}{
+		{"input/cat.png", "input", true},
+		{"input/pets/cat.png", "input", true},
+		{"models/loras/x.safetensors", "models", true},
+		{"input", "input", false},
+		{"input/", "input", false},
+		{"input/../secret.txt", "input", false},
+		{"input/pets/../../custom_nodes/x.py", "input", false},
+		{"input/./cat.png", "input", false},
+		{"input//cat.png", "input", false},
+		{`input/..\secret.txt`, "input", false},
+		{`input\..\secret.txt`, "input", false},
+		{"/input/cat.png", "input", false},
+		{"/etc/passwd", "input", false},
+		{"inputs/cat.png", "input", false},
+		{"models/loras/x.safetensors", "input", false},
+	}
+	for _, tt := range tests {
+		if got := inside(tt.name, tt.dir); got != tt.want {
+			t.Errorf("inside(%q, %q) = %v, want %v", tt.name, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestValidRepository(t *testing.T) {
+	tests := []struct {
+		repo string
+		want bool
+	}{
+		{"https://github.com/ltdrdata/ComfyUI-Manager", true},
+		{"https://github.com/ltdrdata/ComfyUI-Manager.git", true},
+		{"git@github.com:ltdrdata/ComfyUI-Manager.git", true},
+		{"", false},
+		{"https://", false},
+		{"https://-oProxyCommand=touch /tmp/x", false},
+		{"http://github.com/a/b", false},
+		{"ext::sh -c touch% /tmp/x", false},
+		{"file:///tmp/repo", false},
+		{"/tmp/repo", false},
+		{"--upload-pack=touch /tmp/x", false},
+		{"git@-oProxyCommand=x:a/b", false},
+		{"git@github.com", false},
+		{"git@github.com:", false},
+		{"https://github.com/a/b\n--config", false},
+	}
+	for _, tt := range tests {
+		if got := validRepository(tt.repo); got != tt.want {
+			t.Errorf("validRepository(%q) = %v, want %v", tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	valid := func() *Manifest {
+		return &Manifest{
+			Version:  manifestVersion,
+			Workflow: "flux.json",
+			CustomNodes: []CustomNode{
+				{Name: "ComfyUI-GGUF", Repository: "https://github.com/city96/ComfyUI-GGUF", Commit: commit},
+				{Repository: "git@github.com:Kosinkadink/ComfyUI-VideoHelperSuite.git", Commit: "0123abc"},
+				{RegistryID: "comfyui-kjnodes", Version: "1.0.5"},
+			},
+			Models: []Model{{Name: "ae.safetensors", Path: "vae/ae.safetensors", Target: "models/vae/ae.safetensors"}},
+			Inputs: []Input{{Name: "cat.png", Path: "input/pets/cat.png"}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(m *Manifest)
+		err    string
+	}{
+		{"valid", func(m *Manifest) {}, ""},
+		{"workflow path", func(m *Manifest) { m.Workflow = "../flux.json" }, "invalid workflow name"},
+		{"workflow subdirectory", func(m *Manifest) { m.Workflow = "a/flux.json" }, "invalid workflow name"},
+		{"pack name", func(m *Manifest) { m.CustomNodes[0].Name = ".." }, "invalid custom node directory"},
+		{"pack path", func(m *Manifest) { m.CustomNodes[0].Name = "../../input" }, "invalid custom node directory"},
+		{"pack named by repository", func(m *Manifest) {
+			m.CustomNodes[1].Repository = "https://example.com/.."
+		}, "invalid custom node directory"},
+		{"local repository", func(m *Manifest) { m.CustomNodes[0].Repository = "/srv/git/pack" }, "invalid repository"},
+		{"ext repository", func(m *Manifest) { m.CustomNodes[0].Repository = "ext::sh -c id" }, "invalid repository"},
+		{"option repository", func(m *Manifest) {
+			m.CustomNodes[0].Repository = "https://-uhelper.example/x"
+		}, "invalid repository"},
+		{"commit option", func(m *Manifest) { m.CustomNodes[0].Commit = "--orphan" }, "invalid commit"},
+		{"commit branch", func(m *Manifest) { m.CustomNodes[0].Commit = "main" }, "invalid commit"},
+		{"commit too short", func(m *Manifest) { m.CustomNodes[0].Commit = "abc12" }, "invalid commit"},
+		{"commit uppercase", func(m *Manifest) { m.CustomNodes[0].Commit = strings.ToUpper(commit) }, "invalid commit"},
+		{"model outside models", func(m *Manifest) { m.Models[0].Target = "custom_nodes/x/__init__.py" }, "invalid model target"},
+		{"model traversal", func(m *Manifest) { m.Models[0].Target = "models/../main.py" }, "invalid model target"},
+		{"model absolute", func(m *Manifest) { m.Models[0].Target = "/models/vae/ae.safetensors" }, "invalid model target"},
+		{"model empty", func(m *Manifest) { m.Models[0].Target = "" }, "invalid model target"},
+		{"input outside input", func(m *Manifest) { m.Inputs[0].Path = "custom_nodes/x/__init__.py" }, "invalid input path"},
+		{"input traversal", func(m *Manifest) { m.Inputs[0].Path = "input/../secret.txt" }, "invalid input path"},
+		{"input absolute", func(m *Manifest) { m.Inputs[0].Path = "/etc/passwd" }, "invalid input path"},
+		{"input backslashes", func(m *Manifest) { m.Inputs[0].Path = `input\..\secret.txt` }, "invalid input path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := valid()
+			tt.modify(m)
+			err := m.validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("validate() = %v, want nil", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("validate() = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"runcomfy/pkg/analyzer"
+	"runcomfy/pkg/nodeschema"
+	"runcomfy/pkg/scanner"
+	"runcomfy/pkg/workflow"
+)
+
+// Source is what a bundle is made from: a workflow, the analysis of its
+// dependencies and the scan of the installation they were looked up in.
+type Source struct {
+	// Name is the file name the workflow is stored under, and Data its
+	// JSON.
+	Name     string
+	Data     []byte
+	Workflow *workflow.Workflow
+
+	Analysis     *analyzer.AnalysisResult
+	Scan         *scanner.ScanResult
+	Installation *scanner.ComfyUIInstallation
+
+	// Registry tells custom nodes from core ones and names widget values.
+	// The bundled core definitions are used when it is nil.
+	Registry *nodeschema.Registry
+}
+
+// CreateOptions control what Create records.
+type CreateOptions struct {
+	// IncludeInactive also records the custom nodes and input files only
+	// muted or bypassed nodes use, as optional. The analysis should have
+	// been made with the same setting, since it supplies the models.
+	IncludeInactive bool
+
+	// Inputs stores the input files found in the installation in the
+	// bundle.
+	Inputs bool
+
+	// Progress, when set, is called with each file before it is hashed.
+	Progress func(path string)
+}
+
+// Create writes a bundle of the source's workflow to out and returns its
+// manifest. Models and input files found in the installation are hashed;
+// those that are missing are recorded without a hash. It fails when a model
+// the analysis found installed cannot be read, or when a model or input file
+// lies outside the models or input directory, as Install would refuse the
+// bundle.
+func Create(out io.Writer, src Source, opts CreateOptions) (*Manifest, error) {
+	reg := src.Registry
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	base := src.Installation.BasePath
+
+	m := &Manifest{
+		Version:   manifestVersion,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Workflow:  src.Name,
+		Models:    []Model{},
+		Inputs:    []Input{},
+	}
+	m.CustomNodes = customNodes(src, reg, opts.IncludeInactive)
+
+	hash := func(relPath string) (int64, string, error) {
+		if opts.Progress != nil {
+			opts.Progress(relPath)
+		}
+		return hashFile(filepath.Join(base, filepath.FromSlash(relPath)))
+	}
+
+	for _, model := range src.Analysis.Models {
+		if !inside(model.Target, "models") {
+			return nil, fmt.Errorf("invalid model target %q: models must go below models/", model.Target)
+		}
+		entry := Model{
+			Name:     model.Name,
+			Path:     model.Path,
+			Target:   model.Target,
+			URL:      model.DownloadURL,
+			Required: model.Required,
+		}
+		if model.Installed {
+			var err error
+			if entry.Size, entry.SHA256, err = hash(model.Target); err != nil {
+				return nil, fmt.Errorf("failed to hash model %s: %w", model.Target, err)
+			}
+		}
+		m.Models = append(m.Models, entry)
+	}
+
+	var bundled []string
+	seen := make(map[string]int)
+	for _, dep := range src.Workflow.ExtractDependenciesUsing(reg) {
+		if dep.Type != "input" || (dep.Optional && !opts.IncludeInactive) {
+			continue
+		}
+		if i, ok := seen[dep.Path]; ok {
+			m.Inputs[i].Required = m.Inputs[i].Required || !dep.Optional
+			continue
+		}
+		if !inside(dep.Path, "input") {
+			return nil, fmt.Errorf("invalid input path %q: input files must go below input/", dep.Path)
+		}
+		seen[dep.Path] = len(m.Inputs)
+
+		entry := Input{Name: dep.Name, Path: dep.Path, Required: !dep.Optional}
+		// Input files that are missing are recorded without a hash.
+		size, sum, err := hash(dep.Path)
+		switch {
+		case err == nil:
+			entry.Size, entry.SHA256 = size, sum
+			if opts.Inputs {
+				entry.Bundled = true
+				bundled = append(bundled, dep.Path)
+			}
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("failed to hash input %s: %w", dep.Path, err)
+		}
+		m.Inputs = append(m.Inputs, entry)
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	zw := zip.NewWriter(out)
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEntry(zw, ManifestName, m.CreatedAt, bytes.NewReader(append(manifest, '\n'))); err != nil {
+		return nil, err
+	}
+	if err := writeEntry(zw, m.Workflow, m.CreatedAt, bytes.NewReader(src.Data)); err != nil {
+		return nil, err
+	}
+	for _, relPath := range bundled {
+		f, err := os.Open(filepath.Join(base, filepath.FromSlash(relPath)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+		err = writeEntry(zw, relPath, m.CreatedAt, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return m, nil
+}
+
+func writeEntry(zw *zip.Writer, name string, modified time.Time, r io.Reader) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+func hashFile(filePath string) (int64, string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// packNodes gathers what the workflow records about one custom node pack.
+type packNodes struct {
+	types    map[string]bool
+	modules  map[string]bool
+	auxID    string
+	cnrID    string
+	version  string
+	required bool
+}
+
+// customNodes lists the custom node packs the workflow's nodes come from,
+// as grouped by Node.Pack, and looks each up among the installed ones.
+func customNodes(src Source, reg *nodeschema.Registry, includeInactive bool) []CustomNode {
+	packs := make(map[string]*packNodes)
+	var order []string
+	for _, node := range src.Workflow.Expand().Nodes {
+		if !node.IsActive() && !includeInactive {
+			continue
+		}
+		key := node.Pack(reg)
+		if key == "" {
+			continue
+		}
+		p, ok := packs[key]
+		if !ok {
+			p = &packNodes{types: make(map[string]bool), modules: make(map[string]bool)}
+			packs[key] = p
+			order = append(order, key)
+		}
+		p.types[node.Type] = true
+		p.required = p.required || node.IsActive()
+		if def, ok := reg.Lookup(node.Type); ok {
+			if dir, ok := strings.CutPrefix(def.PythonModule, "custom_nodes."); ok {
+				p.modules[dir] = true
+			}
+		}
+		if id, _ := node.Properties["aux_id"].(string); id != "" {
+			p.auxID = id
+		}
+		if id, _ := node.Properties["cnr_id"].(string); id != "" {
+			p.cnrID = id
+		}
+		if ver, _ := node.Properties["ver"].(string); ver != "" {
+			p.version = ver
+		}
+	}
+
+	var installed []*scanner.CustomNodeInfo
+	for _, name := range src.Scan.CustomNodes {
+		if info, err := src.Installation.InspectCustomNode(name); err == nil {
+			installed = append(installed, info)
+		}
+	}
+
+	result := []CustomNode{}
+	byName := make(map[string]int)
+	for _, key := range order {
+		p := packs[key]
+		var nodeTypes []string
+		for nodeType := range p.types {
+			nodeTypes = append(nodeTypes, nodeType)
+		}
+
+		info := findInstalled(p, installed)
+		if info != nil {
+			// Nodes saved by older editors lack the pack id, so one pack
+			// can be found under several keys.
+			if i, ok := byName[info.Name]; ok {
+				result[i].NodeTypes = append(result[i].NodeTypes, nodeTypes...)
+				sort.Strings(result[i].NodeTypes)
+				result[i].Required = result[i].Required || p.required
+				continue
+			}
+			byName[info.Name] = len(result)
+		}
+
+		entry := CustomNode{NodeTypes: nodeTypes, Required: p.required}
+		sort.Strings(entry.NodeTypes)
+		if info != nil {
+			entry.Name = info.Name
+			// Remotes Install would not clone, such as local paths, are
+			// left out.
+			if validRepository(info.Repository) {
+				entry.Repository = info.Repository
+			}
+			entry.Commit = info.Commit
+			entry.RegistryID = info.RegistryID
+			entry.Version = info.Version
+		} else {
+			// Fall back on what the editor recorded when the workflow was
+			// saved: a GitHub repository for packs installed from git, with
+			// the commit as version, or a registry id and version.
+			if p.auxID != "" && validRepository("https://github.com/"+p.auxID) {
+				entry.Repository = "https://github.com/" + p.auxID
+				if len(p.version) == 40 && commitPattern.MatchString(p.version) {
+					entry.Commit = p.version
+				}
+			}
+			if p.cnrID != "" {
+				entry.RegistryID = p.cnrID
+				entry.Version = p.version
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// findInstalled finds the installed pack the nodes come from: the directory
+// their definitions' module names, else the one matching the repository or
+// registry id the editor recorded, else one named after a node type.
+func findInstalled(p *packNodes, installed []*scanner.CustomNodeInfo) *scanner.CustomNodeInfo {
+	match := func(ok func(info *scanner.CustomNodeInfo) bool) *scanner.CustomNodeInfo {
+		for _, info := range installed {
+			if ok(info) {
+				return info
+			}
+		}
+		return nil
+	}
+
+	if info := match(func(info *scanner.CustomNodeInfo) bool { return p.modules[info.Name] }); info != nil {
+		return info
+	}
+	if p.auxID != "" {
+		repo := strings.ToLower(p.auxID)
+		if info := match(func(info *scanner.CustomNodeInfo) bool {
+			remote := strings.ToLower(strings.TrimSuffix(info.Repository, ".git"))
+			return strings.HasSuffix(remote, "/"+repo) || strings.HasSuffix(remote, ":"+repo) ||
+				strings.EqualFold(info.Name, path.Base(repo))
+		}); info != nil {
+			return info
+		}
+	}
+	if p.cnrID != "" {
+		if info := match(func(info *scanner.CustomNodeInfo) bool {
+			return strings.EqualFold(info.RegistryID, p.cnrID) || strings.EqualFold(info.Name, p.cnrID)
+		}); info != nil {
+			return info
+		}
+	}
+	return match(func(info *scanner.CustomNodeInfo) bool { return p.types[info.Name] })
+}
@@ -0,0 +1,174 @@
+package bundle
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"runcomfy/pkg/analyzer"
+	"runcomfy/pkg/scanner"
+	"runcomfy/pkg/workflow"
+)
+
+// writeFiles creates the files, given by slash-separated paths relative to
+// dir, with the given contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// createBundle bundles the workflow in data from the installation at base,
+// as the bundle create command does.
+func createBundle(t *testing.T, base, name string, data []byte, out *bytes.Buffer) (*Manifest, error) {
+	t.Helper()
+	installation := scanner.NewComfyUIInstallation(base)
+	w, err := workflow.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := analyzer.New(installation).AnalyzeWorkflow(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan, err := installation.ScanInstallation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Create(out, Source{
+		Name:         name,
+		Data:         data,
+		Workflow:     w,
+		Analysis:     result,
+		Scan:         scan,
+		Installation: installation,
+	}, CreateOptions{Inputs: true})
+}
+
+func TestCreateInstallRoundTrip(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "workflow", "testdata", "sdxl_inpaint_api.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"models/checkpoints/sd_xl_base_1.0.safetensors": "checkpoint",
+		"input/street/cobblestone.png":                  "cobblestone",
+	})
+
+	var buf bytes.Buffer
+	m, err := createBundle(t, src, "sdxl_inpaint.json", data, &buf)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(m.Models) != 1 || m.Models[0].Target != "models/checkpoints/sd_xl_base_1.0.safetensors" ||
+		m.Models[0].Size != int64(len("checkpoint")) || m.Models[0].SHA256 == "" {
+		t.Errorf("models = %+v, want the hashed checkpoint", m.Models)
+	}
+	inputs := make(map[string]Input)
+	for _, input := range m.Inputs {
+		inputs[input.Path] = input
+	}
+	if in := inputs["input/street/cobblestone.png"]; !in.Bundled || in.SHA256 == "" {
+		t.Errorf("cobblestone.png = %+v, want it bundled and hashed", in)
+	}
+	if in, ok := inputs["input/car_mask.png"]; !ok || in.Bundled || in.SHA256 != "" {
+		t.Errorf("car_mask.png = %+v, want it recorded as missing", in)
+	}
+
+	path := filepath.Join(t.TempDir(), "sdxl_inpaint.bundle.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer b.Close()
+
+	dst := scanner.NewComfyUIInstallation(t.TempDir())
+	want := map[string]Status{
+		"sd_xl_base_1.0.safetensors": StatusManual,
+		"street/cobblestone.png":     StatusInstalled,
+		"car_mask.png":               StatusManual,
+		"sdxl_inpaint.json":          StatusInstalled,
+	}
+	for _, dryRun := range []bool{true, false} {
+		steps, err := b.Install(dst, InstallOptions{DryRun: dryRun})
+		if err != nil {
+			t.Fatalf("Install: %v", err)
+		}
+		if len(steps) != len(want) {
+			t.Errorf("Install took %d steps, want %d: %+v", len(steps), len(want), steps)
+		}
+		for _, step := range steps {
+			status := want[step.Name]
+			if dryRun && status == StatusInstalled {
+				status = StatusPlanned
+			}
+			if step.Status != status {
+				t.Errorf("dry run %v: %s %s = %s (%s), want %s", dryRun, step.Kind, step.Name, step.Status, step.Detail, status)
+			}
+		}
+	}
+
+	for name, wantData := range map[string][]byte{
+		"input/street/cobblestone.png":             []byte("cobblestone"),
+		"user/default/workflows/sdxl_inpaint.json": data,
+	} {
+		got, err := os.ReadFile(filepath.Join(dst.BasePath, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s was not installed: %v", name, err)
+		} else if !bytes.Equal(got, wantData) {
+			t.Errorf("%s differs from the source", name)
+		}
+	}
+
+	// Installing again finds everything in place.
+	steps, err := b.Install(dst, InstallOptions{})
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	for _, step := range steps {
+		if want[step.Name] == StatusInstalled && step.Status != StatusPresent {
+			t.Errorf("second install: %s = %s, want %s", step.Name, step.Status, StatusPresent)
+		}
+	}
+}
+
+func TestCreateRejectsPathsOutside(t *testing.T) {
+	tests := []struct {
+		name  string
+		image string
+	}{
+		{"parent", "../secret.txt"},
+		{"nested parent", "pets/../../secret.txt"},
+		{"backslashes", `..\secret.txt`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			writeFiles(t, base, map[string]string{"secret.txt": "TOP SECRET"})
+			data := []byte(`{"1": {"class_type": "LoadImage", "inputs": {"image": ` + strconv.Quote(tt.image) + `}}}`)
+
+			var buf bytes.Buffer
+			_, err := createBundle(t, base, "leak.json", data, &buf)
+			if err == nil || !strings.Contains(err.Error(), "invalid input path") {
+				t.Errorf("Create = %v, want an invalid input path error", err)
+			}
+			if buf.Len() > 0 {
+				t.Errorf("Create wrote %d bytes, want none", buf.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package bundle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"runcomfy/pkg/scanner"
+)
+
+// Status is the outcome of one step of an installation.
+type Status string
+
+const (
+	// StatusPresent means the item was already installed as recorded.
+	StatusPresent Status = "present"
+	// StatusInstalled means the item was installed.
+	StatusInstalled Status = "installed"
+	// StatusPlanned means the item would be installed, in a dry run.
+	StatusPlanned Status = "planned"
+	// StatusMismatch means the item is installed but differs from the
+	// bundle, as with a pack at another commit, and was left alone.
+	StatusMismatch Status = "mismatch"
+	// StatusManual means the item cannot be installed automatically.
+	StatusManual Status = "manual"
+	// StatusFailed means installing the item failed.
+	StatusFailed Status = "failed"
+)
+
+// Step reports what Install did about one item of the manifest.
+type Step struct {
+	// Kind is "custom-node", "model", "input" or "workflow".
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Target   string `json:"target,omitempty"`
+	Status   Status `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Required bool   `json:"required"`
+}
+
+// InstallOptions control how Install restores a bundle.
+type InstallOptions struct {
+	// DryRun reports what would be done without changing anything.
+	DryRun bool
+
+	// Verify hashes the models already installed to check they are the
+	// ones recorded; otherwise only their size is compared.
+	Verify bool
+
+	// Force overwrites input files and the workflow where the installed
+	// ones differ from the bundle's.
+	Force bool
+
+	// HTTPClient downloads models; http.DefaultClient is used when it is
+	// nil.
+	HTTPClient *http.Client
+
+	// Progress, when set, is called with a message before each step that
+	// takes time, such as a clone or a download.
+	Progress func(message string)
+}
+
+// Install restores the bundle onto the installation: it clones the custom
+// node packs that are missing at the recorded commits, downloads the missing
+// models that have a URL, extracts the bundled input files and saves the
+// workflow to the editor's workflow directory. Items already installed are
+// checked against the manifest but never changed, except for input files
+// and the workflow with Force. Steps that fail do not stop the others.
+//
+// A manifest with an entry that would be installed outside its place, such
+// as an input file outside the input directory, is rejected before
+// anything is installed.
+func (b *Bundle) Install(installation *scanner.ComfyUIInstallation, opts InstallOptions) ([]Step, error) {
+	if err := b.Manifest.validate(); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	i := &installer{bundle: b, base: installation.BasePath, opts: opts}
+	if i.opts.HTTPClient == nil {
+		i.opts.HTTPClient = http.DefaultClient
+	}
+
+	var steps []Step
+	for _, pack := range b.Manifest.CustomNodes {
+		steps = append(steps, i.customNode(installation, pack))
+	}
+	for _, model := range b.Manifest.Models {
+		steps = append(steps, i.model(model))
+	}
+	for _, input := range b.Manifest.Inputs {
+		steps = append(steps, i.input(input))
+	}
+	steps = append(steps, i.workflow())
+	return steps, nil
+}
+
+type installer struct {
+	bundle *Bundle
+	base   string
+	opts   InstallOptions
+}
+
+func (i *installer) progress(format string, args ...interface{}) {
+	if i.opts.Progress != nil {
+		i.opts.Progress(fmt.Sprintf(format, args...))
+	}
+}
+
+func (i *installer) customNode(installation *scanner.ComfyUIInstallation, pack CustomNode) Step {
+	name := packDir(pack)
+	step := Step{Kind: "custom-node", Name: name, Required: pack.Required}
+	if name == "" {
+		step.Name = pack.RegistryID
+		if step.Name == "" {
+			step.Name = strings.Join(pack.NodeTypes, ", ")
+		}
+		step.Status = StatusManual
+		if pack.RegistryID != "" {
+			step.Detail = "install " + registryRef(pack) + " from the Comfy registry with ComfyUI Manager or comfy-cli"
+		} else {
+			step.Detail = "the pack providing " + strings.Join(pack.NodeTypes, ", ") + " is not known"
+		}
+		return step
+	}
+	step.Target = "custom_nodes/" + name
+
+	if installation.HasCustomNode(name) {
+		info, err := installation.InspectCustomNode(name)
+		switch {
+		case err != nil:
+			step.Status = StatusFailed
+			step.Detail = err.Error()
+		case pack.Commit != "" && info.Commit != "" && info.Commit != pack.Commit:
+			step.Status = StatusMismatch
+			step.Detail = fmt.Sprintf("installed at commit %s, bundle has %s", shortCommit(info.Commit), shortCommit(pack.Commit))
+		case pack.Commit == "" && pack.Version != "" && info.Version != "" && info.Version != pack.Version:
+			step.Status = StatusMismatch
+			step.Detail = fmt.Sprintf("installed at version %s, bundle has %s", info.Version, pack.Version)
+		default:
+			step.Status = StatusPresent
+		}
+		return step
+	}
+
+	if pack.Repository == "" {
+		step.Status = StatusManual
+		step.Detail = "no repository recorded"
+		if pack.RegistryID != "" {
+			step.Detail = "install " + registryRef(pack) + " from the Comfy registry with ComfyUI Manager or comfy-cli"
+		}
+		return step
+	}
+	if i.opts.DryRun {
+		step.Status = StatusPlanned
+		step.Detail = "clone " + pack.Repository
+		if pack.Commit != "" {
+			step.Detail += " at " + shortCommit(pack.Commit)
+		}
+		return step
+	}
+
+	i.progress("Cloning %s", pack.Repository)
+	dir := filepath.Join(installation.CustomNodes, name)
+	if err := git("", "clone", "--quiet", "--", pack.Repository, dir); err != nil {
+		step.Status = StatusFailed
+		step.Detail = err.Error()
+		return step
+	}
+	if pack.Commit != "" {
+		if err := git(dir, "checkout", "--quiet", pack.Commit, "--"); err != nil {
+			step.Status = StatusFailed
+			step.Detail = fmt.Sprintf("cloned, but %v", err)
+			return step
+		}
+	}
+	step.Status = StatusInstalled
+	if _, err := os.Stat(filepath.Join(dir, "requirements.txt")); err == nil {
+		step.Detail = "install its Python requirements with: pip install -r " + filepath.Join(dir, "requirements.txt")
+	}
+	return step
+}
+
+func registryRef(pack CustomNode) string {
+	if pack.Version == "" {
+		return pack.RegistryID
+	}
+	return pack.RegistryID + "@" + pack.Version
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
+
+func git(dir string, args ...string) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git is not installed")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s failed: %s", args[0], strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (i *installer) model(model Model) Step {
+	step := Step{Kind: "model", Name: model.Name, Target: model.Target, Required: model.Required}
+	target := filepath.Join(i.base, filepath.FromSlash(model.Target))
+
+	if info, err := os.Stat(target); err == nil {
+		switch {
+		case model.Size != 0 && info.Size() != model.Size:
+			step.Status = StatusMismatch
+			step.Detail = fmt.Sprintf("installed file has %d bytes, bundle has %d", info.Size(), model.Size)
+		case i.opts.Verify && model.SHA256 != "":
+			i.progress("Verifying %s", model.Target)
+			_, sum, err := hashFile(target)
+			if err != nil {
+				step.Status = StatusFailed
+				step.Detail = err.Error()
+			} else if sum != model.SHA256 {
+				step.Status = StatusMismatch
+				step.Detail = "SHA-256 differs from the bundle's"
+			} else {
+				step.Status = StatusPresent
+			}
+		default:
+			step.Status = StatusPresent
+		}
+		return step
+	}
+
+	if model.URL == "" {
+		step.Status = StatusManual
+		step.Detail = "no download URL recorded; copy the file from the source installation"
+		return step
+	}
+	if i.opts.DryRun {
+		step.Status = StatusPlanned
+		step.Detail = "download " + model.URL
+		return step
+	}
+
+	i.progress("Downloading %s", model.URL)
+	if err := i.download(model, target); err != nil {
+		step.Status = StatusFailed
+		step.Detail = err.Error()
+		return step
+	}
+	step.Status = StatusInstalled
+	return step
+}
+
+// download fetches a model to target through a temporary file, checking its
+// size and hash against the manifest. Hugging Face downloads are
+// authenticated with the token in HF_TOKEN, if set, as gated models require.
+func (i *installer) download(model Model, target string) error {
+	req, err := http.NewRequest(http.MethodGet, model.URL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid download URL: %w", err)
+	}
+	if token := os.Getenv("HF_TOKEN"); token != "" && isHuggingFace(req.URL) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := i.opts.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp := target + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && model.Size != 0 && size != model.Size {
+		err = fmt.Errorf("downloaded %d bytes, bundle has %d", size, model.Size)
+	}
+	if err == nil && model.SHA256 != "" && hex.EncodeToString(h.Sum(nil)) != model.SHA256 {
+		err = fmt.Errorf("downloaded file's SHA-256 differs from the bundle's")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+func isHuggingFace(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return host == "huggingface.co" || strings.HasSuffix(host, ".huggingface.co")
+}
+
+func (i *installer) input(input Input) Step {
+	step := Step{Kind: "input", Name: input.Name, Target: input.Path, Required: input.Required}
+	target := filepath.Join(i.base, filepath.FromSlash(input.Path))
+
+	var data []byte
+	if input.Bundled {
+		var err error
+		if data, err = i.bundle.read(input.Path); err != nil {
+			step.Status = StatusFailed
+			step.Detail = err.Error()
+			return step
+		}
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		_, sum, err := hashFile(target)
+		switch {
+		case err != nil:
+			step.Status = StatusFailed
+			step.Detail = err.Error()
+		case input.SHA256 == "" || sum == input.SHA256:
+			step.Status = StatusPresent
+		case data != nil && i.opts.Force:
+			return i.write(step, target, data)
+		default:
+			step.Status = StatusMismatch
+			step.Detail = "installed file differs from the bundle's"
+		}
+		return step
+	}
+
+	if data == nil {
+		step.Status = StatusManual
+		step.Detail = "not included in the bundle; supply the file yourself"
+		return step
+	}
+	return i.write(step, target, data)
+}
+
+func (i *installer) workflow() Step {
+	name := i.bundle.Manifest.Workflow
+	step := Step{Kind: "workflow", Name: name, Required: true}
+	step.Target = "user/default/workflows/" + name
+	target := filepath.Join(i.base, filepath.FromSlash(step.Target))
+
+	data, err := i.bundle.Workflow()
+	if err != nil {
+		step.Status = StatusFailed
+		step.Detail = err.Error()
+		return step
+	}
+	if existing, err := os.ReadFile(target); err == nil {
+		switch {
+		case bytes.Equal(existing, data):
+			step.Status = StatusPresent
+		case i.opts.Force:
+			return i.write(step, target, data)
+		default:
+			step.Status = StatusMismatch
+			step.Detail = "a different workflow is saved under this name"
+		}
+		return step
+	}
+	return i.write(step, target, data)
+}
+
+// write saves data to target, creating its directory, unless in a dry run.
+func (i *installer) write(step Step, target string, data []byte) Step {
+	if i.opts.DryRun {
+		step.Status = StatusPlanned
+		step.Detail = "extract from the bundle"
+		return step
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		step.Status = StatusFailed
+		step.Detail = err.Error()
+		return step
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		step.Status = StatusFailed
+		step.Detail = err.Error()
+		return step
+	}
+	step.Status = StatusInstalled
+	return step
+}
@@ -0,0 +1,337 @@
+// Package catalog keeps a persistent index of the workflows found in
+// directory trees, recording the node types, custom node packs, models,
+// widget values and group titles each one uses, so that large collections
+// can be searched without parsing every file again. The index is a JSON file
+// that Update keeps current incrementally: files whose size and modification
+// time have not changed are not parsed again.
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"runcomfy/pkg/nodeschema"
+	"runcomfy/pkg/workflow"
+)
+
+// indexVersion is bumped whenever the entries change shape; indexes written
+// with another version are rebuilt from scratch.
+const indexVersion = 1
+
+// workflowExtensions are the files Update parses: workflow JSON and the
+// media ComfyUI embeds workflows into.
+var workflowExtensions = map[string]bool{
+	".json": true,
+	".png":  true,
+	".webp": true,
+	".mp4":  true,
+	".webm": true,
+}
+
+// Catalog is an index of workflow files.
+type Catalog struct {
+	Version int `json:"version"`
+	// Entries are keyed by the absolute path of the file.
+	Entries map[string]*Entry `json:"entries"`
+}
+
+// Entry is what the index records about one file.
+type Entry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+
+	// Error tells why the file could not be read as a workflow, as with
+	// images without embedded metadata or unrelated JSON files. The entry
+	// is kept so that the file is not parsed again until it changes, but
+	// it never matches a search.
+	Error string `json:"error,omitempty"`
+
+	Format workflow.Format `json:"format,omitempty"`
+	// Nodes lists the nodes with group nodes and subgraphs expanded, so
+	// inner nodes carry "<outer id>:<inner id>" ids.
+	Nodes []Node   `json:"nodes,omitempty"`
+	Packs []string `json:"packs,omitempty"`
+	// Dependencies lists the model and input files the workflow uses.
+	Dependencies []workflow.Dependency `json:"dependencies,omitempty"`
+	Groups       []string              `json:"groups,omitempty"`
+}
+
+// Node is an indexed node.
+type Node struct {
+	ID    workflow.NodeID `json:"id"`
+	Type  string          `json:"type"`
+	Title string          `json:"title,omitempty"`
+	// Pack is the custom node pack the node comes from, or "" for nodes
+	// that come with ComfyUI.
+	Pack     string `json:"pack,omitempty"`
+	Inactive bool   `json:"inactive,omitempty"`
+	// Widgets holds the widget values keyed by input name, or by
+	// "#<index>" for nodes without a definition.
+	Widgets map[string]interface{} `json:"widgets,omitempty"`
+}
+
+// New returns an empty catalog.
+func New() *Catalog {
+	return &Catalog{Version: indexVersion, Entries: make(map[string]*Entry)}
+}
+
+// DefaultPath is where the index is kept unless told otherwise: runcomfy's
+// directory in the user's cache directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the cache directory: %w", err)
+	}
+	return filepath.Join(dir, "runcomfy", "index.json"), nil
+}
+
+// Load reads the index stored at path. A missing file, or one written by an
+// incompatible version, gives an empty catalog.
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", path, err)
+	}
+	if c.Version != indexVersion {
+		return New(), nil
+	}
+	if c.Entries == nil {
+		c.Entries = make(map[string]*Entry)
+	}
+	return &c, nil
+}
+
+// Save writes the index to path, creating its directory if needed. The file
+// is replaced in one step, so an interrupted save leaves the previous index
+// intact.
+func (c *Catalog) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".index-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// UpdateOptions control how Update indexes files.
+type UpdateOptions struct {
+	// Registry names widget values and identifies custom node packs. The
+	// bundled core definitions are used when it is nil.
+	Registry *nodeschema.Registry
+
+	// Rebuild parses every file again, even unchanged ones, as is needed
+	// after the node definitions changed.
+	Rebuild bool
+
+	// Progress, when set, is called with the path of each file before it
+	// is parsed.
+	Progress func(path string)
+}
+
+// UpdateResult counts what Update did.
+type UpdateResult struct {
+	Dir       string `json:"dir"`
+	Added     int    `json:"added"`
+	Updated   int    `json:"updated"`
+	Unchanged int    `json:"unchanged"`
+	Removed   int    `json:"removed"`
+	// Skipped counts the files that are not workflows.
+	Skipped int `json:"skipped"`
+}
+
+// Update brings the entries for the files under dir up to date: new and
+// changed files are parsed, and entries for files that no longer exist are
+// dropped. Hidden files and directories are ignored, as are directories
+// that cannot be read.
+func (c *Catalog) Update(dir string, opts UpdateOptions) (*UpdateResult, error) {
+	reg := opts.Registry
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	result := &UpdateResult{Dir: root}
+	seen := make(map[string]bool)
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() || !workflowExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		seen[path] = true
+
+		old, ok := c.Entries[path]
+		if ok && !opts.Rebuild && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+			if old.Error != "" {
+				result.Skipped++
+			} else {
+				result.Unchanged++
+			}
+			return nil
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(path)
+		}
+		entry := indexFile(path, info, reg)
+		c.Entries[path] = entry
+		switch {
+		case entry.Error != "":
+			result.Skipped++
+		case ok:
+			result.Updated++
+		default:
+			result.Added++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	prefix := root + string(filepath.Separator)
+	for path := range c.Entries {
+		if (path == root || strings.HasPrefix(path, prefix)) && !seen[path] {
+			delete(c.Entries, path)
+			result.Removed++
+		}
+	}
+	return result, nil
+}
+
+// Workflows returns the entries for the files that are workflows, in path
+// order.
+func (c *Catalog) Workflows() []*Entry {
+	var entries []*Entry
+	for _, entry := range c.Entries {
+		if entry.Error == "" {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// indexFile parses the file at path into an entry.
+func indexFile(path string, info fs.FileInfo, reg *nodeschema.Registry) *Entry {
+	entry := &Entry{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+	w, err := workflow.ParseWorkflow(path)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.Format = w.Format
+
+	packs := make(map[string]bool)
+	for _, node := range w.Expand().Nodes {
+		pack := node.Pack(reg)
+		if pack != "" {
+			packs[pack] = true
+		}
+		entry.Nodes = append(entry.Nodes, Node{
+			ID:       node.ID,
+			Type:     node.Type,
+			Title:    node.Title,
+			Pack:     pack,
+			Inactive: !node.IsActive(),
+			Widgets:  node.NamedWidgetValues(reg),
+		})
+	}
+	entry.Packs = sortedSet(packs)
+
+	for _, dep := range w.ExtractDependenciesUsing(reg) {
+		if dep.Type != "node" {
+			entry.Dependencies = append(entry.Dependencies, dep)
+		}
+	}
+
+	groups := make(map[string]bool)
+	addGroups := func(list []workflow.Group) {
+		for _, group := range list {
+			if group.Title != "" {
+				groups[group.Title] = true
+			}
+		}
+	}
+	addGroups(w.Groups)
+	if w.Definitions != nil {
+		for _, sg := range w.Definitions.Subgraphs {
+			addGroups(sg.Groups)
+		}
+	}
+	entry.Groups = sortedSet(groups)
+	return entry
+}
+
+func sortedSet(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(set))
+	for value := range set {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,221 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// indexFixtures copies fixtures into a temporary tree, with the SDXL ones
+// under sdxl/ and the rest under other/, and indexes it.
+func indexFixtures(t *testing.T) (*Catalog, string) {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range []string{
+		"animatediff_vhs_v04.json",
+		"flux_dev_v1.json",
+		"flux_gguf_primitive_api.json",
+		"flux_subgraph_v1.json",
+		"sdxl_bypass_v04.json",
+		"sdxl_group_node_v04.json",
+		"sdxl_inpaint_api.json",
+		"sdxl_lora_output.png",
+		"sdxl_primitive_reroute_v04.json",
+		"sdxl_private_v04.json",
+		"object_info_animatediff.json",
+	} {
+		data, err := os.ReadFile(filepath.Join("..", "workflow", "testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := "other"
+		if strings.HasPrefix(name, "sdxl_") {
+			dir = "sdxl"
+		}
+		path := filepath.Join(root, dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := New()
+	result, err := c.Update(root, UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// The object_info dump is JSON but not a workflow.
+	if result.Added != 10 || result.Skipped != 1 {
+		t.Fatalf("Update = %+v, want 10 added and 1 skipped", result)
+	}
+	return c, root
+}
+
+func TestSearch(t *testing.T) {
+	c, root := indexFixtures(t)
+
+	widget := func(s string) WidgetFilter {
+		f, err := ParseWidgetFilter(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	tests := []struct {
+		name  string
+		query Query
+		// want lists the matches by path relative to the root, in order.
+		want []string
+	}{
+		{
+			name:  "everything",
+			query: Query{},
+			want: []string{
+				"other/animatediff_vhs_v04.json", "other/flux_dev_v1.json", "other/flux_gguf_primitive_api.json",
+				"other/flux_subgraph_v1.json", "sdxl/sdxl_bypass_v04.json", "sdxl/sdxl_group_node_v04.json",
+				"sdxl/sdxl_inpaint_api.json", "sdxl/sdxl_lora_output.png", "sdxl/sdxl_primitive_reroute_v04.json",
+				"sdxl/sdxl_private_v04.json",
+			},
+		},
+		{
+			// Nodes inside group nodes are indexed too.
+			name:  "node type",
+			query: Query{Nodes: []string{"loraloader"}},
+			want: []string{
+				"sdxl/sdxl_bypass_v04.json", "sdxl/sdxl_group_node_v04.json", "sdxl/sdxl_lora_output.png",
+				"sdxl/sdxl_primitive_reroute_v04.json", "sdxl/sdxl_private_v04.json",
+			},
+		},
+		{
+			name:  "model name",
+			query: Query{Models: []string{"FLUX1"}},
+			want:  []string{"other/flux_dev_v1.json", "other/flux_gguf_primitive_api.json", "other/flux_subgraph_v1.json"},
+		},
+		{
+			name:  "model folder",
+			query: Query{Models: []string{"checkpoints/alice"}},
+			want:  []string{"sdxl/sdxl_private_v04.json"},
+		},
+		{
+			name:  "group title",
+			query: Query{Groups: []string{"load models"}},
+			want:  []string{"other/flux_dev_v1.json", "other/flux_subgraph_v1.json"},
+		},
+		{
+			name:  "widget value",
+			query: Query{Widgets: []WidgetFilter{widget("KSampler.steps>=30")}},
+			want:  []string{"sdxl/sdxl_inpaint_api.json", "sdxl/sdxl_primitive_reroute_v04.json"},
+		},
+		{
+			name:  "widget values on one node",
+			query: Query{Widgets: []WidgetFilter{widget("LoraLoader.strength_model<0.8"), widget("LoraLoader.strength_clip>=0.6")}},
+			want:  []string{"sdxl/sdxl_group_node_v04.json", "sdxl/sdxl_primitive_reroute_v04.json"},
+		},
+		{
+			name:  "every filter must hold",
+			query: Query{Nodes: []string{"LoraLoader"}, Models: []string{"flux1"}},
+		},
+		{
+			name:  "directory",
+			query: Query{Nodes: []string{"KSampler"}, Dirs: []string{filepath.Join(root, "other")}},
+			want: []string{
+				"other/animatediff_vhs_v04.json", "other/flux_dev_v1.json", "other/flux_gguf_primitive_api.json",
+				"other/flux_subgraph_v1.json",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range c.Search(tt.query) {
+				rel, err := filepath.Rel(root, m.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchMatchedValues(t *testing.T) {
+	c, root := indexFixtures(t)
+	matches := c.Search(Query{
+		Models:  []string{"juggernaut"},
+		Widgets: []WidgetFilter{{NodeType: "LoraLoader", Name: "strength_model", Op: "=", Value: "0.7"}},
+	})
+	if len(matches) != 1 || matches[0].Path != filepath.Join(root, "sdxl", "sdxl_group_node_v04.json") {
+		t.Fatalf("Search = %+v, want the group node workflow", matches)
+	}
+	m := matches[0]
+	if want := []string{"checkpoints/juggernautXL_v9Rdphoto2Lightning.safetensors"}; !reflect.DeepEqual(m.Models, want) {
+		t.Errorf("models = %q, want %q", m.Models, want)
+	}
+	if len(m.Nodes) != 1 || m.Nodes[0].ID != "20:1" || !reflect.DeepEqual(m.Nodes[0].Widgets, map[string]interface{}{"strength_model": 0.7}) {
+		t.Errorf("nodes = %+v, want LoraLoader 20:1 with only strength_model", m.Nodes)
+	}
+}
+
+func TestUpdateIncremental(t *testing.T) {
+	c, root := indexFixtures(t)
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(root, "other", "flux_dev_v1.json")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := loaded.Update(root, UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	want := &UpdateResult{Dir: root, Unchanged: 9, Removed: 1, Skipped: 1}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Update = %+v, want %+v", result, want)
+	}
+	if got := len(loaded.Search(Query{Groups: []string{"Load Models"}})); got != 1 {
+		t.Errorf("found %d workflows with the group after the removal, want 1", got)
+	}
+}
+
+func TestParseWidgetFilter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want WidgetFilter
+		err  string
+	}{
+		{in: "seed=42", want: WidgetFilter{Name: "seed", Op: "=", Value: "42"}},
+		{in: "LoraLoader.strength_model >= 1", want: WidgetFilter{NodeType: "LoraLoader", Name: "strength_model", Op: ">=", Value: "1"}},
+		{in: "ckpt_name~sdxl", want: WidgetFilter{Name: "ckpt_name", Op: "~", Value: "sdxl"}},
+		{in: "sampler_name!=euler", want: WidgetFilter{Name: "sampler_name", Op: "!=", Value: "euler"}},
+		{in: "seed", err: "expected [<node type>.]<input><op><value>"},
+		{in: "seed!42", err: "unknown operator"},
+		{in: "KSampler.=42", err: "missing input name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseWidgetFilter(tt.in)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ParseWidgetFilter = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseWidgetFilter = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
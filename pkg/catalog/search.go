@@ -0,0 +1,308 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query selects workflows from the index. A workflow matches when it
+// satisfies every filter given; names are compared regardless of case.
+type Query struct {
+	// Nodes are node types the workflow must use.
+	Nodes []string
+	// Packs are custom node packs the workflow must use.
+	Packs []string
+	// Models are parts of the names of model files the workflow must use,
+	// such as "sdxl" or "loras/detail".
+	Models []string
+	// Groups are parts of the titles of groups the workflow must have.
+	Groups []string
+	// Widgets are conditions on widget values. Those naming the same node
+	// type, or none, must all hold for a single node.
+	Widgets []WidgetFilter
+	// Dirs limits the search to the workflows under these directories.
+	Dirs []string
+}
+
+// WidgetFilter is a condition on a widget value, written
+// "[<node type>.]<input><op><value>", such as "LoraLoader.strength_model>1"
+// or "seed=42".
+type WidgetFilter struct {
+	NodeType string `json:"nodeType,omitempty"`
+	Name     string `json:"name"`
+	// Op is one of =, !=, <, <=, >, >= and ~, which tests whether the value
+	// contains Value. Ordering operators only hold for numbers.
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// widgetOps are the operators ParseWidgetFilter accepts, longest first.
+var widgetOps = []string{"!=", "<=", ">=", "=", "<", ">", "~"}
+
+// ParseWidgetFilter parses a widget filter such as
+// "LoraLoader.strength_model>1".
+func ParseWidgetFilter(s string) (WidgetFilter, error) {
+	at := strings.IndexAny(s, "!<>=~")
+	if at < 0 {
+		return WidgetFilter{}, fmt.Errorf("invalid widget filter %q: expected [<node type>.]<input><op><value>", s)
+	}
+	var f WidgetFilter
+	for _, op := range widgetOps {
+		if strings.HasPrefix(s[at:], op) {
+			f.Op = op
+			break
+		}
+	}
+	if f.Op == "" {
+		return WidgetFilter{}, fmt.Errorf("invalid widget filter %q: unknown operator", s)
+	}
+
+	name := strings.TrimSpace(s[:at])
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		f.NodeType, name = strings.TrimSpace(name[:dot]), strings.TrimSpace(name[dot+1:])
+	}
+	if name == "" {
+		return WidgetFilter{}, fmt.Errorf("invalid widget filter %q: missing input name", s)
+	}
+	f.Name = name
+	f.Value = strings.TrimSpace(s[at+len(f.Op):])
+	return f, nil
+}
+
+func (f WidgetFilter) String() string {
+	name := f.Name
+	if f.NodeType != "" {
+		name = f.NodeType + "." + name
+	}
+	return name + f.Op + f.Value
+}
+
+// match reports whether a widget value satisfies the filter.
+func (f WidgetFilter) match(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	text := valueText(value)
+	if f.Op == "~" {
+		return strings.Contains(strings.ToLower(text), strings.ToLower(f.Value))
+	}
+
+	want, errWant := strconv.ParseFloat(f.Value, 64)
+	got, isNumber := number(value)
+	if errWant == nil && isNumber {
+		switch f.Op {
+		case "=":
+			return got == want
+		case "!=":
+			return got != want
+		case "<":
+			return got < want
+		case "<=":
+			return got <= want
+		case ">":
+			return got > want
+		case ">=":
+			return got >= want
+		}
+	}
+	switch f.Op {
+	case "=":
+		return strings.EqualFold(text, f.Value)
+	case "!=":
+		return !strings.EqualFold(text, f.Value)
+	}
+	return false
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func valueText(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	if data, err := json.Marshal(value); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// Match is a workflow found by Search.
+type Match struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	// Nodes lists the nodes that matched the node type and widget filters,
+	// with only the matching widget values.
+	Nodes []Node `json:"nodes,omitempty"`
+	// Models lists the model files that matched the model filters.
+	Models []string `json:"models,omitempty"`
+}
+
+// Search returns the indexed workflows matching the query, in path order.
+func (c *Catalog) Search(q Query) []Match {
+	var dirs []string
+	for _, dir := range q.Dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		dirs = append(dirs, dir)
+	}
+
+	// Widget filters are grouped by the node type they name, since each
+	// group has to hold for one node.
+	var widgetGroups [][]WidgetFilter
+	groupIndex := make(map[string]int)
+	for _, f := range q.Widgets {
+		key := strings.ToLower(f.NodeType)
+		i, ok := groupIndex[key]
+		if !ok {
+			i = len(widgetGroups)
+			groupIndex[key] = i
+			widgetGroups = append(widgetGroups, nil)
+		}
+		widgetGroups[i] = append(widgetGroups[i], f)
+	}
+
+	var matches []Match
+	for _, entry := range c.Workflows() {
+		if len(dirs) > 0 && !inDirs(entry.Path, dirs) {
+			continue
+		}
+		if m, ok := matchEntry(entry, q, widgetGroups); ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+func inDirs(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchEntry(entry *Entry, q Query, widgetGroups [][]WidgetFilter) (Match, bool) {
+	m := Match{Path: entry.Path, Format: string(entry.Format)}
+	matched := make(map[int]map[string]interface{})
+	mark := func(i int, widgets map[string]interface{}) {
+		if matched[i] == nil {
+			matched[i] = make(map[string]interface{})
+		}
+		for name, value := range widgets {
+			matched[i][name] = value
+		}
+	}
+
+	for _, nodeType := range q.Nodes {
+		found := false
+		for i, node := range entry.Nodes {
+			if strings.EqualFold(node.Type, nodeType) {
+				mark(i, nil)
+				found = true
+			}
+		}
+		if !found {
+			return Match{}, false
+		}
+	}
+
+	for _, pack := range q.Packs {
+		found := false
+		for _, p := range entry.Packs {
+			found = found || strings.EqualFold(p, pack)
+		}
+		if !found {
+			return Match{}, false
+		}
+	}
+
+	models := make(map[string]bool)
+	for _, model := range q.Models {
+		found := false
+		for _, dep := range entry.Dependencies {
+			if dep.Type == "model" && (containsFold(dep.Path, model) || containsFold(dep.Name, model)) {
+				models[dep.Path] = true
+				found = true
+			}
+		}
+		if !found {
+			return Match{}, false
+		}
+	}
+	m.Models = sortedSet(models)
+
+	for _, group := range q.Groups {
+		found := false
+		for _, title := range entry.Groups {
+			found = found || containsFold(title, group)
+		}
+		if !found {
+			return Match{}, false
+		}
+	}
+
+	for _, filters := range widgetGroups {
+		found := false
+		for i, node := range entry.Nodes {
+			if widgets, ok := matchWidgets(node, filters); ok {
+				mark(i, widgets)
+				found = true
+			}
+		}
+		if !found {
+			return Match{}, false
+		}
+	}
+
+	for i, node := range entry.Nodes {
+		widgets, ok := matched[i]
+		if !ok {
+			continue
+		}
+		node.Widgets = nil
+		if len(widgets) > 0 {
+			node.Widgets = widgets
+		}
+		m.Nodes = append(m.Nodes, node)
+	}
+	sort.SliceStable(m.Nodes, func(i, j int) bool { return m.Nodes[i].ID.Less(m.Nodes[j].ID) })
+	return m, true
+}
+
+// matchWidgets reports whether all the filters hold for the node, returning
+// the values they matched.
+func matchWidgets(node Node, filters []WidgetFilter) (map[string]interface{}, bool) {
+	widgets := make(map[string]interface{})
+	for _, f := range filters {
+		if f.NodeType != "" && !strings.EqualFold(node.Type, f.NodeType) {
+			return nil, false
+		}
+		value, ok := node.Widgets[f.Name]
+		if !ok || !f.match(value) {
+			return nil, false
+		}
+		widgets[f.Name] = value
+	}
+	return widgets, true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,247 @@
+// Package comfyclient is a small client for the HTTP API of a ComfyUI server.
+// It builds API prompts in code, queues them and waits for their results,
+// and is what Go programs generated by "runcomfy export" are built on.
+package comfyclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Client talks to one ComfyUI server.
+type Client struct {
+	// BaseURL is the server's address, such as "http://127.0.0.1:8188".
+	BaseURL string
+	// ClientID identifies this client to the server, which uses it to
+	// route progress messages.
+	ClientID string
+	// HTTPClient sends the requests; http.DefaultClient is used when it is
+	// nil.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the server at baseURL with a random client id.
+func New(baseURL string) *Client {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &Client{
+		BaseURL:  strings.TrimRight(baseURL, "/"),
+		ClientID: hex.EncodeToString(id),
+	}
+}
+
+// QueueResult is the server's reply to a queued prompt.
+type QueueResult struct {
+	PromptID   string                     `json:"prompt_id"`
+	Number     int                        `json:"number"`
+	NodeErrors map[string]json.RawMessage `json:"node_errors,omitempty"`
+}
+
+// Error is returned when the server rejects a request. Message is the
+// server's explanation where it gave one, and Body the full response.
+type Error struct {
+	StatusCode int
+	Message    string
+	Body       []byte
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("comfyui: %s (HTTP %d)", e.Message, e.StatusCode)
+	}
+	return fmt.Sprintf("comfyui: HTTP %d: %s", e.StatusCode, strings.TrimSpace(string(e.Body)))
+}
+
+// Queue submits a prompt for execution.
+func (c *Client) Queue(ctx context.Context, p *Prompt) (*QueueResult, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"prompt":    p,
+		"client_id": c.ClientID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode prompt: %w", err)
+	}
+
+	var result QueueResult
+	if err := c.do(ctx, http.MethodPost, "/prompt", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// HistoryEntry is the record of an executed prompt.
+type HistoryEntry struct {
+	// Outputs holds what each output node produced, keyed by node id and
+	// then by kind, such as "images".
+	Outputs map[string]map[string]json.RawMessage `json:"outputs"`
+	Status  Status                                `json:"status"`
+}
+
+// Status tells how the execution of a prompt ended.
+type Status struct {
+	StatusStr string `json:"status_str"`
+	Completed bool   `json:"completed"`
+	// Messages are the execution events, each a [type, data] pair.
+	Messages [][2]json.RawMessage `json:"messages"`
+}
+
+// File is a file written by an output node, relative to one of the
+// server's directories named by Type ("output" or "temp").
+type File struct {
+	Filename  string `json:"filename"`
+	Subfolder string `json:"subfolder"`
+	Type      string `json:"type"`
+}
+
+// Files lists the files written by the prompt's output nodes, ordered by
+// node id.
+func (e *HistoryEntry) Files() []File {
+	ids := make([]string, 0, len(e.Outputs))
+	for id := range e.Outputs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return nodeIDLess(ids[i], ids[j]) })
+
+	var files []File
+	for _, id := range ids {
+		kinds := make([]string, 0, len(e.Outputs[id]))
+		for kind := range e.Outputs[id] {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			var list []File
+			if json.Unmarshal(e.Outputs[id][kind], &list) != nil {
+				continue
+			}
+			for _, f := range list {
+				if f.Filename != "" {
+					files = append(files, f)
+				}
+			}
+		}
+	}
+	return files
+}
+
+// History returns the record of a prompt. It reports false while the prompt
+// is still queued or running.
+func (c *Client) History(ctx context.Context, promptID string) (*HistoryEntry, bool, error) {
+	var history map[string]*HistoryEntry
+	if err := c.do(ctx, http.MethodGet, "/history/"+url.PathEscape(promptID), nil, &history); err != nil {
+		return nil, false, err
+	}
+	entry, ok := history[promptID]
+	return entry, ok, nil
+}
+
+// Wait polls the server every interval until the prompt has run, and
+// returns its record. It fails if the execution failed or ctx is done.
+func (c *Client) Wait(ctx context.Context, promptID string, interval time.Duration) (*HistoryEntry, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		entry, done, err := c.History(ctx, promptID)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			if entry.Status.StatusStr == "error" {
+				return entry, fmt.Errorf("prompt %s failed: %s", promptID, entry.executionError())
+			}
+			return entry, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// executionError extracts the exception reported for a failed prompt.
+func (e *HistoryEntry) executionError() string {
+	for _, message := range e.Status.Messages {
+		var kind string
+		if json.Unmarshal(message[0], &kind) != nil || kind != "execution_error" {
+			continue
+		}
+		var data struct {
+			NodeID           string `json:"node_id"`
+			NodeType         string `json:"node_type"`
+			ExceptionMessage string `json:"exception_message"`
+		}
+		if json.Unmarshal(message[1], &data) == nil {
+			return fmt.Sprintf("node %s (%s): %s", data.NodeID, data.NodeType, strings.TrimSpace(data.ExceptionMessage))
+		}
+	}
+	return "execution error"
+}
+
+// ViewURL returns the address from which a file written by a prompt can be
+// downloaded.
+func (c *Client) ViewURL(f File) string {
+	query := url.Values{"filename": {f.Filename}, "subfolder": {f.Subfolder}, "type": {f.Type}}
+	return c.BaseURL + "/view?" + query.Encode()
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("comfyui: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("comfyui: failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{StatusCode: resp.StatusCode, Body: data}
+		var reply struct {
+			Error struct {
+				Message string `json:"message"`
+				Details string `json:"details"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &reply) == nil && reply.Error.Message != "" {
+			apiErr.Message = reply.Error.Message
+			if reply.Error.Details != "" {
+				apiErr.Message += ": " + reply.Error.Details
+			}
+		}
+		return apiErr
+	}
+
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("comfyui: failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package comfyclient
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// Prompt is an API prompt under construction, the graph of nodes the
+// server's /prompt endpoint executes.
+type Prompt struct {
+	nodes map[string]*promptNode
+}
+
+type promptNode struct {
+	ClassType string      `json:"class_type"`
+	Inputs    Inputs      `json:"inputs"`
+	Meta      *promptMeta `json:"_meta,omitempty"`
+}
+
+type promptMeta struct {
+	Title string `json:"title"`
+}
+
+// Inputs are the values of a node's inputs, keyed by input name. A value is
+// either a literal, such as a number or a string, or the Link returned by
+// Node.Output to connect the input to another node.
+type Inputs map[string]interface{}
+
+// NewPrompt returns an empty prompt.
+func NewPrompt() *Prompt {
+	return &Prompt{nodes: make(map[string]*promptNode)}
+}
+
+// Add adds a node of type classType with the given id, replacing any node
+// with that id.
+func (p *Prompt) Add(id, classType string, inputs Inputs) Node {
+	if inputs == nil {
+		inputs = Inputs{}
+	}
+	p.nodes[id] = &promptNode{ClassType: classType, Inputs: inputs}
+	return Node{prompt: p, id: id}
+}
+
+// Node returns the node with the given id, and whether there is one.
+func (p *Prompt) Node(id string) (Node, bool) {
+	_, ok := p.nodes[id]
+	return Node{prompt: p, id: id}, ok
+}
+
+// MarshalJSON writes the prompt as the /prompt endpoint expects it, with
+// the nodes in id order.
+func (p *Prompt) MarshalJSON() ([]byte, error) {
+	ids := make([]string, 0, len(p.nodes))
+	for id := range p.nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return nodeIDLess(ids[i], ids[j]) })
+
+	buf := []byte{'{'}
+	for i, id := range ids {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		key, err := json.Marshal(id)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(p.nodes[id])
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(append(buf, key...), ':'), value...)
+	}
+	return append(buf, '}'), nil
+}
+
+// Node refers to a node of a prompt.
+type Node struct {
+	prompt *Prompt
+	id     string
+}
+
+// ID returns the node's id.
+func (n Node) ID() string {
+	return n.id
+}
+
+// Output returns a link to one of the node's outputs, counted from zero,
+// to use as the value of another node's input.
+func (n Node) Output(slot int) Link {
+	return Link{NodeID: n.id, Slot: slot}
+}
+
+// Title sets the title the editor shows for the node.
+func (n Node) Title(title string) Node {
+	n.prompt.nodes[n.id].Meta = &promptMeta{Title: title}
+	return n
+}
+
+// Set changes the value of one of the node's inputs.
+func (n Node) Set(input string, value interface{}) Node {
+	n.prompt.nodes[n.id].Inputs[input] = value
+	return n
+}
+
+// Link connects an input to an output of another node. It is encoded as
+// the [node id, slot] pair of the API format.
+type Link struct {
+	NodeID string
+	Slot   int
+}
+
+func (l Link) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{l.NodeID, l.Slot})
+}
+
+// nodeIDLess orders numeric ids numerically and others lexically after
+// them, as ComfyUI does.
+func nodeIDLess(a, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return x < y
+	case errA == nil || errB == nil:
+		return errA == nil
+	}
+	return a < b
+}
@@ -0,0 +1,205 @@
+// Package export turns API prompts into standalone programs that build the
+// prompt in code, one statement per node, and queue it on a ComfyUI server.
+// Python scripts only need the standard library; Go programs use the
+// runcomfy/pkg/comfyclient package.
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"runcomfy/pkg/nodeschema"
+	"runcomfy/pkg/workflow"
+)
+
+// Languages lists the languages programs can be generated in.
+var Languages = []string{"python", "go"}
+
+// DefaultServer is the server address generated programs use unless told
+// otherwise.
+const DefaultServer = "http://127.0.0.1:8188"
+
+// Options control the generated program.
+type Options struct {
+	// Source names the workflow in the program's comments.
+	Source string
+	// Server is the default server address; DefaultServer when empty.
+	Server string
+	// Registry orders each node's inputs as its definition declares them.
+	// The bundled definitions are used when it is nil.
+	Registry *nodeschema.Registry
+}
+
+// Generate writes the program for prompt in the given language.
+func Generate(lang string, prompt workflow.Prompt, opts Options) ([]byte, error) {
+	switch lang {
+	case "python":
+		return Python(prompt, opts)
+	case "go":
+		return Go(prompt, opts)
+	}
+	return nil, fmt.Errorf("unsupported language: %s (expected %s)", lang, strings.Join(Languages, " or "))
+}
+
+// step is one node of the prompt, in the order the program adds it.
+type step struct {
+	id        string
+	classType string
+	title     string
+	// name is the variable holding the node, or empty when no other node
+	// uses its outputs.
+	name   string
+	inputs []stepInput
+}
+
+type stepInput struct {
+	name  string
+	value interface{}
+	// from is set for inputs connected to another node's output.
+	from *step
+	slot int
+}
+
+// naming turns the words of a node's title into a variable name.
+type naming struct {
+	join     func(words []string) string
+	suffix   func(name, id string) string
+	reserved map[string]bool
+}
+
+// plan orders the prompt's nodes so that every node comes after the nodes
+// it uses, and names the variables of those that are used.
+func plan(prompt workflow.Prompt, opts Options, names naming) ([]*step, error) {
+	reg := opts.Registry
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	w, err := prompt.Workflow()
+	if err != nil {
+		return nil, err
+	}
+	graph := workflow.NewGraph(w)
+	if dangling := graph.Dangling(); len(dangling) > 0 {
+		d := dangling[0]
+		return nil, fmt.Errorf("invalid link from node %s to node %s: %s", d.Link.OriginID, d.Link.TargetID, d.Reason)
+	}
+	order, err := graph.TopologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make(map[workflow.NodeID]*step, len(order))
+	var result []*step
+	for _, id := range order {
+		node, _ := graph.Node(id)
+		s := &step{id: id.String(), classType: node.Type, title: node.Title}
+		def, known := reg.Lookup(node.Type)
+		if known && (s.title == def.DisplayName || s.title == def.Name) {
+			s.title = ""
+		}
+
+		values := make(map[string]stepInput)
+		for name, value := range node.NamedWidgets {
+			values[name] = stepInput{name: name, value: value}
+		}
+		for _, edge := range graph.Incoming(id) {
+			values[edge.ToName] = stepInput{name: edge.ToName, from: steps[edge.From], slot: edge.FromSlot}
+		}
+		if known {
+			for _, in := range def.Inputs {
+				if value, ok := values[in.Name]; ok {
+					s.inputs = append(s.inputs, value)
+					delete(values, in.Name)
+				}
+			}
+		}
+		rest := make([]string, 0, len(values))
+		for name := range values {
+			rest = append(rest, name)
+		}
+		sort.Strings(rest)
+		for _, name := range rest {
+			s.inputs = append(s.inputs, values[name])
+		}
+
+		steps[id] = s
+		result = append(result, s)
+	}
+
+	used := make(map[string]bool)
+	for name := range names.reserved {
+		used[name] = true
+	}
+	for _, s := range result {
+		for _, in := range s.inputs {
+			if in.from == nil || in.from.name != "" {
+				continue
+			}
+			label := in.from.title
+			if label == "" {
+				label = in.from.classType
+			}
+			name := names.join(words(label))
+			if name == "" || unicode.IsDigit(rune(name[0])) {
+				name = names.join(append([]string{"node"}, words(name)...))
+			}
+			for used[name] {
+				name = names.suffix(name, in.from.id)
+			}
+			used[name] = true
+			in.from.name = name
+		}
+	}
+	return result, nil
+}
+
+// words splits a title or a class type into lowercase words:
+// "CLIPTextEncode" and "CLIP Text Encode" both give clip, text and encode.
+func words(s string) []string {
+	var result []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			result = append(result, strings.ToLower(string(word)))
+			word = nil
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 {
+			prev := word[len(word)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// A lone capital stays with the word it prefixes, as in KSampler.
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (nextLower && len(word) > 1) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return result
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// header describes the workflow a program was generated from.
+func header(opts Options) string {
+	if opts.Source == "" {
+		return "a ComfyUI workflow"
+	}
+	return opts.Source
+}
+
+func server(opts Options) string {
+	if opts.Server == "" {
+		return DefaultServer
+	}
+	return opts.Server
+}
@@ -0,0 +1,164 @@
+package export
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"runcomfy/pkg/workflow"
+)
+
+// ClientImport is the import path of the client package generated Go
+// programs use.
+const ClientImport = "runcomfy/pkg/comfyclient"
+
+// Go generates a Go program built on the comfyclient package.
+func Go(prompt workflow.Prompt, opts Options) ([]byte, error) {
+	reserved := map[string]bool{"p": true, "comfyclient": true}
+	steps, err := plan(prompt, opts, naming{
+		join: func(words []string) string {
+			for i := 1; i < len(words); i++ {
+				words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+			}
+			name := strings.Join(words, "")
+			if token.IsKeyword(name) {
+				name += "Node"
+			}
+			return name
+		},
+		suffix:   func(name, id string) string { return name + strings.Join(words(id), "") },
+		reserved: reserved,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var body strings.Builder
+	for _, s := range steps {
+		body.WriteString("\t")
+		if s.name != "" {
+			body.WriteString(s.name + " := ")
+		}
+		fmt.Fprintf(&body, "p.Add(%s, %s, comfyclient.Inputs{", strconv.Quote(s.id), strconv.Quote(s.classType))
+		if len(s.inputs) > 0 {
+			body.WriteString("\n")
+		}
+		for _, in := range s.inputs {
+			value := goValue(in.value)
+			if in.from != nil {
+				value = fmt.Sprintf("%s.Output(%d)", in.from.name, in.slot)
+			}
+			fmt.Fprintf(&body, "\t\t%s: %s,\n", strconv.Quote(in.name), value)
+		}
+		if len(s.inputs) > 0 {
+			body.WriteString("\t")
+		}
+		body.WriteString("})")
+		if s.title != "" {
+			fmt.Fprintf(&body, ".Title(%s)", strconv.Quote(s.title))
+		}
+		body.WriteString("\n")
+	}
+
+	src := fmt.Sprintf(goTemplate, header(opts), body.String(), strconv.Quote(server(opts)), ClientImport)
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated Go code: %w", err)
+	}
+	return out, nil
+}
+
+// goValue writes a JSON value as a Go literal.
+func goValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return formatNumber(v)
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = goValue(item)
+		}
+		return "[]interface{}{" + strings.Join(items, ", ") + "}"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, key := range keys {
+			items[i] = strconv.Quote(key) + ": " + goValue(v[key])
+		}
+		return "map[string]interface{}{" + strings.Join(items, ", ") + "}"
+	}
+	return strconv.Quote(fmt.Sprint(value))
+}
+
+const goTemplate = `// Command queues %[1]s on a ComfyUI server.
+//
+// Generated by runcomfy export. Run with -help for options.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"%[4]s"
+)
+
+// buildPrompt builds the API prompt of the workflow.
+func buildPrompt() *comfyclient.Prompt {
+	p := comfyclient.NewPrompt()
+%[2]s	return p
+}
+
+func main() {
+	server := flag.String("server", %[3]s, "ComfyUI server URL")
+	printOnly := flag.Bool("print", false, "print the prompt instead of queueing it")
+	noWait := flag.Bool("no-wait", false, "exit once the prompt is queued")
+	flag.Parse()
+
+	prompt := buildPrompt()
+	if *printOnly {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(prompt); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ctx := context.Background()
+	client := comfyclient.New(*server)
+	queued, err := client.Queue(ctx, prompt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Queued prompt", queued.PromptID)
+	if *noWait {
+		return
+	}
+
+	entry, err := client.Wait(ctx, queued.PromptID, time.Second)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, f := range entry.Files() {
+		fmt.Println(client.ViewURL(f))
+	}
+}
+`
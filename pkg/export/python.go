@@ -0,0 +1,239 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"runcomfy/pkg/workflow"
+)
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true, "class": true,
+	"continue": true, "def": true, "del": true, "elif": true, "else": true,
+	"except": true, "finally": true, "for": true, "from": true, "global": true,
+	"if": true, "import": true, "in": true, "is": true, "lambda": true,
+	"nonlocal": true, "not": true, "or": true, "pass": true, "raise": true,
+	"return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// Python generates a Python 3 script that needs only the standard library.
+func Python(prompt workflow.Prompt, opts Options) ([]byte, error) {
+	reserved := map[string]bool{"p": true, "Prompt": true, "Node": true}
+	for keyword := range pythonKeywords {
+		reserved[keyword] = true
+	}
+	steps, err := plan(prompt, opts, naming{
+		join:     func(words []string) string { return strings.Join(words, "_") },
+		suffix:   func(name, id string) string { return name + "_" + identifierSuffix(id) },
+		reserved: reserved,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var body strings.Builder
+	for _, s := range steps {
+		body.WriteString("    ")
+		if s.name != "" {
+			body.WriteString(s.name + " = ")
+		}
+		fmt.Fprintf(&body, "p.add(%s, %s", pythonString(s.id), pythonString(s.classType))
+
+		var extra []string
+		for _, in := range s.inputs {
+			value := pythonInput(in)
+			if identifier.MatchString(in.name) && !pythonKeywords[in.name] {
+				fmt.Fprintf(&body, ",\n        %s=%s", in.name, value)
+			} else {
+				extra = append(extra, fmt.Sprintf("%s: %s", pythonString(in.name), value))
+			}
+		}
+		if len(extra) > 0 {
+			fmt.Fprintf(&body, ",\n        **{%s}", strings.Join(extra, ", "))
+		}
+		if len(s.inputs) > 0 {
+			body.WriteString(",\n    ")
+		}
+		body.WriteString(")")
+		if s.title != "" {
+			fmt.Fprintf(&body, ".title(%s)", pythonString(s.title))
+		}
+		body.WriteString("\n")
+	}
+
+	var out bytes.Buffer
+	description := fmt.Sprintf("Queue %s on a ComfyUI server.", header(opts))
+	fmt.Fprintf(&out, pythonTemplate, header(opts), body.String(), pythonString(server(opts)), pythonString(description))
+	return out.Bytes(), nil
+}
+
+func pythonInput(in stepInput) string {
+	if in.from != nil {
+		return fmt.Sprintf("%s[%d]", in.from.name, in.slot)
+	}
+	return pythonValue(in.value)
+}
+
+// pythonValue writes a JSON value as a Python literal.
+func pythonValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case float64:
+		return formatNumber(v)
+	case string:
+		return pythonString(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = pythonValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, key := range keys {
+			items[i] = pythonString(key) + ": " + pythonValue(v[key])
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+	return pythonString(fmt.Sprint(value))
+}
+
+// pythonString quotes a string. JSON string escapes are valid in Python.
+func pythonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// formatNumber writes whole numbers without a fraction, as the API format
+// does, and others in their shortest form.
+func formatNumber(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e18 {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// identifierSuffix makes a node id usable in a variable name.
+func identifierSuffix(id string) string {
+	return strings.Join(words(id), "_")
+}
+
+const pythonTemplate = `#!/usr/bin/env python3
+"""Queue %[1]s on a ComfyUI server.
+
+Generated by runcomfy export. Run with --help for options.
+"""
+
+import argparse
+import json
+import sys
+import time
+import urllib.error
+import urllib.parse
+import urllib.request
+import uuid
+
+
+class Node:
+    """A node of the prompt. Indexing it gives a link to one of its outputs."""
+
+    def __init__(self, prompt, node_id):
+        self.prompt = prompt
+        self.id = node_id
+
+    def __getitem__(self, slot):
+        return [self.id, slot]
+
+    def title(self, title):
+        self.prompt.nodes[self.id]["_meta"] = {"title": title}
+        return self
+
+
+class Prompt:
+    """An API prompt under construction."""
+
+    def __init__(self):
+        self.nodes = {}
+
+    def add(self, node_id, class_type, **inputs):
+        self.nodes[node_id] = {"class_type": class_type, "inputs": inputs}
+        return Node(self, node_id)
+
+
+def build_prompt():
+    p = Prompt()
+%[2]s    return p.nodes
+
+
+def request(server, path, body=None):
+    url = server.rstrip("/") + path
+    data = None if body is None else json.dumps(body).encode()
+    req = urllib.request.Request(url, data=data, headers={"Content-Type": "application/json"})
+    try:
+        with urllib.request.urlopen(req) as resp:
+            return json.load(resp)
+    except urllib.error.HTTPError as err:
+        sys.exit("ComfyUI rejected the request (HTTP %%d): %%s" %% (err.code, err.read().decode(errors="replace")))
+
+
+def wait(server, prompt_id, interval):
+    while True:
+        history = request(server, "/history/" + prompt_id)
+        if prompt_id in history:
+            return history[prompt_id]
+        time.sleep(interval)
+
+
+def main():
+    parser = argparse.ArgumentParser(description=%[4]s)
+    parser.add_argument("--server", default=%[3]s, help="ComfyUI server URL")
+    parser.add_argument("--print", action="store_true", help="print the prompt instead of queueing it")
+    parser.add_argument("--no-wait", action="store_true", help="exit once the prompt is queued")
+    args = parser.parse_args()
+
+    prompt = build_prompt()
+    if args.print:
+        json.dump(prompt, sys.stdout, indent=2)
+        print()
+        return
+
+    queued = request(args.server, "/prompt", {"prompt": prompt, "client_id": str(uuid.uuid4())})
+    prompt_id = queued["prompt_id"]
+    print("Queued prompt", prompt_id)
+    if args.no_wait:
+        return
+
+    entry = wait(args.server, prompt_id, 1.0)
+    if entry.get("status", {}).get("status_str") == "error":
+        sys.exit("Prompt %%s failed" %% prompt_id)
+    for node_id, output in sorted(entry.get("outputs", {}).items()):
+        for files in output.values():
+            for f in files if isinstance(files, list) else []:
+                if isinstance(f, dict) and "filename" in f:
+                    query = urllib.parse.urlencode({k: f.get(k, "") for k in ("filename", "subfolder", "type")})
+                    print("Node %%s: %%s/view?%%s" %% (node_id, args.server.rstrip("/"), query))
+
+
+if __name__ == "__main__":
+    main()
+`
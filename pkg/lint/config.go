@@ -0,0 +1,137 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"runcomfy/pkg/workflow"
+)
+
+// Config holds the settings of individual rules, keyed by rule ID. Rules
+// without an entry run with their defaults.
+type Config map[string]RuleConfig
+
+// RuleConfig is the setting of one rule.
+type RuleConfig struct {
+	Disabled bool
+	// Severity overrides the rule's default severity.
+	Severity workflow.Severity
+	// Options are passed to rules implementing Configurable.
+	Options map[string]interface{}
+}
+
+// ParseConfig reads rule settings as found under "lint.rules" in the config
+// file. Each rule maps to "off" (or false) to disable it, a severity
+// ("error" or "warning"), or an object with optional "severity" and
+// "enabled" keys alongside the rule's own options:
+//
+//	no-randomized-seed: error
+//	no-preview-only-output: off
+//	safetensors-only:
+//	  severity: warning
+//	  extensions: [.safetensors, .gguf]
+func ParseConfig(raw map[string]interface{}) (Config, error) {
+	cfg := make(Config, len(raw))
+	ids := make([]string, 0, len(raw))
+	for id := range raw {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		var rc RuleConfig
+		switch value := raw[id].(type) {
+		case nil:
+		case bool:
+			rc.Disabled = !value
+		case string:
+			if err := rc.setLevel(value); err != nil {
+				return nil, fmt.Errorf("lint rule %s: %w", id, err)
+			}
+		case map[string]interface{}:
+			for key, option := range value {
+				switch key {
+				case "severity":
+					level, ok := option.(string)
+					if !ok {
+						return nil, fmt.Errorf("lint rule %s: severity must be a string", id)
+					}
+					if err := rc.setLevel(level); err != nil {
+						return nil, fmt.Errorf("lint rule %s: %w", id, err)
+					}
+				case "enabled":
+					enabled, ok := option.(bool)
+					if !ok {
+						return nil, fmt.Errorf("lint rule %s: enabled must be true or false", id)
+					}
+					rc.Disabled = !enabled
+				default:
+					if rc.Options == nil {
+						rc.Options = make(map[string]interface{})
+					}
+					rc.Options[key] = option
+				}
+			}
+		default:
+			return nil, fmt.Errorf("lint rule %s: expected off, a severity or an object, got %v", id, value)
+		}
+		cfg[id] = rc
+	}
+	return cfg, nil
+}
+
+// setLevel applies a level given as "off", "on" or a severity.
+func (rc *RuleConfig) setLevel(level string) error {
+	switch strings.ToLower(level) {
+	case "off":
+		rc.Disabled = true
+	case "on":
+	case string(workflow.SeverityError):
+		rc.Severity = workflow.SeverityError
+	case string(workflow.SeverityWarning):
+		rc.Severity = workflow.SeverityWarning
+	default:
+		return fmt.Errorf("unknown level %q (expected off, error or warning)", level)
+	}
+	return nil
+}
+
+// stringList reads an option holding a list of strings, or a single string.
+func stringList(options map[string]interface{}, key string) ([]string, bool, error) {
+	value, ok := options[key]
+	if !ok {
+		return nil, false, nil
+	}
+	switch value := value.(type) {
+	case string:
+		return []string{value}, true, nil
+	case []interface{}:
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			str, ok := item.(string)
+			if !ok {
+				return nil, false, fmt.Errorf("%s must be a list of strings", key)
+			}
+			list = append(list, str)
+		}
+		return list, true, nil
+	case []string:
+		return value, true, nil
+	}
+	return nil, false, fmt.Errorf("%s must be a list of strings", key)
+}
+
+// checkOptions rejects options a rule does not know.
+func checkOptions(options map[string]interface{}, known ...string) error {
+	for key := range options {
+		found := false
+		for _, name := range known {
+			found = found || key == name
+		}
+		if !found {
+			return fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package lint checks workflows against team conventions that go beyond
+// validity, such as fixed seeds or safetensors-only models. Each convention
+// is a Rule; the built-in rules are registered by this package and others
+// can be added with Register. Rules are enabled, given a severity and
+// configured through a Config.
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"runcomfy/pkg/nodeschema"
+	"runcomfy/pkg/workflow"
+)
+
+// Rule checks a workflow for one convention.
+type Rule interface {
+	// ID names the rule in configuration and reports, e.g.
+	// "no-absolute-paths".
+	ID() string
+	// Description says in one sentence what the rule enforces.
+	Description() string
+	// Severity is the severity of the rule's findings unless configured
+	// otherwise.
+	Severity() workflow.Severity
+	// Check returns the rule's findings. The linter fills in their
+	// severity and code.
+	Check(t *Target) []workflow.Issue
+}
+
+// Configurable is implemented by rules that take options. Configure is
+// called once, before any Check, with the options from the rule's
+// configuration.
+type Configurable interface {
+	Configure(options map[string]interface{}) error
+}
+
+// Target is the workflow a rule checks. Group nodes and subgraphs are
+// expanded, so nodes inside them have "<outer id>:<inner id>" ids.
+type Target struct {
+	Workflow *workflow.Workflow
+	Graph    *workflow.Graph
+	// Registry names widget values and describes node types.
+	Registry *nodeschema.Registry
+}
+
+var factories = make(map[string]func() Rule)
+
+// Register makes a rule available to linters under its ID. The factory is
+// called for each linter, so configuring a rule does not affect others.
+// Registering an ID twice panics.
+func Register(factory func() Rule) {
+	id := factory().ID()
+	if _, exists := factories[id]; exists {
+		panic(fmt.Sprintf("lint: rule %q registered twice", id))
+	}
+	factories[id] = factory
+}
+
+// Available returns a new instance of every registered rule, ordered by ID.
+func Available() []Rule {
+	rules := make([]Rule, 0, len(factories))
+	for _, factory := range factories {
+		rules = append(rules, factory())
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID() < rules[j].ID() })
+	return rules
+}
+
+// Linter runs a configured set of rules.
+type Linter struct {
+	rules    []Rule
+	severity map[string]workflow.Severity
+	registry *nodeschema.Registry
+}
+
+// New builds a linter running every registered rule that cfg does not
+// disable, configured by cfg. A nil registry means the bundled definitions.
+func New(cfg Config, reg *nodeschema.Registry) (*Linter, error) {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	for id := range cfg {
+		if _, ok := factories[id]; !ok {
+			return nil, fmt.Errorf("unknown lint rule %q", id)
+		}
+	}
+
+	l := &Linter{severity: make(map[string]workflow.Severity), registry: reg}
+	for _, rule := range Available() {
+		rc := cfg[rule.ID()]
+		if rc.Disabled {
+			continue
+		}
+		if configurable, ok := rule.(Configurable); ok {
+			if err := configurable.Configure(rc.Options); err != nil {
+				return nil, fmt.Errorf("invalid options for lint rule %s: %w", rule.ID(), err)
+			}
+		} else if len(rc.Options) > 0 {
+			return nil, fmt.Errorf("lint rule %s takes no options", rule.ID())
+		}
+
+		severity := rule.Severity()
+		if rc.Severity != "" {
+			severity = rc.Severity
+		}
+		l.rules = append(l.rules, rule)
+		l.severity[rule.ID()] = severity
+	}
+	return l, nil
+}
+
+// Rules returns the enabled rules, ordered by ID.
+func (l *Linter) Rules() []Rule {
+	return l.rules
+}
+
+// RuleSeverity returns the configured severity of an enabled rule.
+func (l *Linter) RuleSeverity(id string) workflow.Severity {
+	return l.severity[id]
+}
+
+// Lint runs the enabled rules on the workflow. Findings are ordered by node
+// id, with those concerning the whole workflow first; their Code is the ID
+// of the rule that reported them.
+func (l *Linter) Lint(w *workflow.Workflow) []workflow.Issue {
+	w = w.Expand()
+	t := &Target{Workflow: w, Graph: workflow.NewGraph(w), Registry: l.registry}
+
+	var issues []workflow.Issue
+	for _, rule := range l.rules {
+		for _, issue := range rule.Check(t) {
+			issue.Code = rule.ID()
+			issue.Severity = l.severity[rule.ID()]
+			if issue.NodeID != "" && issue.NodeType == "" {
+				if node, ok := t.Graph.Node(issue.NodeID); ok {
+					issue.NodeType = node.Type
+				}
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i].NodeID, issues[j].NodeID
+		if (a == "") != (b == "") {
+			return a == ""
+		}
+		return a.Less(b)
+	})
+	return issues
+}
@@ -0,0 +1,155 @@
+package lint
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"runcomfy/pkg/workflow"
+)
+
+func TestLintFixtures(t *testing.T) {
+	tests := []struct {
+		file   string
+		config map[string]interface{}
+		modify func(w *workflow.Workflow)
+		// want lists the findings as "severity code node input".
+		want []string
+	}{
+		{
+			file: "sdxl_inpaint_api.json",
+		},
+		{
+			file: "sdxl_private_v04.json",
+			want: []string{
+				"warning no-randomized-seed 3 seed",
+				"error no-absolute-paths 9 filename_prefix",
+				"error no-absolute-paths 10 lora_name",
+				"error safetensors-only 12 model_name",
+			},
+		},
+		{
+			file: "sdxl_private_v04.json",
+			config: map[string]interface{}{
+				"no-randomized-seed": "error",
+				"no-absolute-paths":  "off",
+				"safetensors-only":   map[string]interface{}{"extensions": []interface{}{"safetensors", ".PTH"}},
+			},
+			want: []string{"error no-randomized-seed 3 seed"},
+		},
+		{
+			// The value lives in the primitive, whose node is reported.
+			file: "flux_gguf_primitive_api.json",
+			want: []string{
+				"error safetensors-only 11 clip_name1",
+				"error safetensors-only 20 value",
+			},
+		},
+		{
+			file:   "flux_gguf_primitive_api.json",
+			config: map[string]interface{}{"safetensors-only": map[string]interface{}{"extensions": []interface{}{".safetensors", ".gguf"}}},
+		},
+		{
+			// Nodes inside subgraphs are reported with their full id.
+			file: "flux_subgraph_v1.json",
+			want: []string{"error safetensors-only 40:1 model_name"},
+		},
+		{
+			file: "sdxl_lora_v04.json",
+			config: map[string]interface{}{
+				"no-randomized-seed": map[string]interface{}{"modes": []interface{}{"increment"}},
+				"safetensors-only":   false,
+			},
+		},
+		{
+			file: "sdxl_inpaint_api.json",
+			modify: func(w *workflow.Workflow) {
+				for i := range w.Nodes {
+					if w.Nodes[i].Type == "SaveImage" {
+						w.Nodes[i].Type = "PreviewImage"
+					}
+				}
+			},
+			want: []string{"warning no-preview-only-output  "},
+		},
+		{
+			file:   "sdxl_inpaint_api.json",
+			config: map[string]interface{}{"no-deprecated-nodes": map[string]interface{}{"nodes": []interface{}{"VAEEncode"}}},
+			want:   []string{"warning no-deprecated-nodes 6 "},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d/%s", i, tt.file), func(t *testing.T) {
+			w, err := workflow.ParseWorkflow(filepath.Join("..", "workflow", "testdata", tt.file))
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			if tt.modify != nil {
+				tt.modify(w)
+			}
+			cfg, err := ParseConfig(tt.config)
+			if err != nil {
+				t.Fatalf("ParseConfig: %v", err)
+			}
+			l, err := New(cfg, nil)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			var got []string
+			for _, issue := range l.Lint(w) {
+				got = append(got, fmt.Sprintf("%s %s %s %s", issue.Severity, issue.Code, issue.NodeID, issue.Input))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		err    string
+	}{
+		{"bad level", map[string]interface{}{"safetensors-only": "loud"}, "safetensors-only"},
+		{"bad value", map[string]interface{}{"safetensors-only": 3}, "expected off, a severity or an object"},
+		{"bad severity type", map[string]interface{}{"safetensors-only": map[string]interface{}{"severity": 1}}, "severity must be a string"},
+		{"bad enabled", map[string]interface{}{"safetensors-only": map[string]interface{}{"enabled": "yes"}}, "enabled must be true or false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseConfig = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		err    string
+	}{
+		{"unknown rule", map[string]interface{}{"no-such-rule": "error"}, `unknown lint rule "no-such-rule"`},
+		{"options of plain rule", map[string]interface{}{"no-absolute-paths": map[string]interface{}{"paths": true}}, "takes no options"},
+		{"unknown option", map[string]interface{}{"safetensors-only": map[string]interface{}{"extension": ".gguf"}}, "invalid options for lint rule safetensors-only"},
+		{"option type", map[string]interface{}{"no-randomized-seed": map[string]interface{}{"modes": []interface{}{1}}}, "invalid options for lint rule no-randomized-seed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseConfig(tt.config)
+			if err != nil {
+				t.Fatalf("ParseConfig: %v", err)
+			}
+			if _, err := New(cfg, nil); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("New = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
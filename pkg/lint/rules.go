@@ -0,0 +1,375 @@
+package lint
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"runcomfy/pkg/nodeschema"
+	"runcomfy/pkg/workflow"
+)
+
+func init() {
+	Register(func() Rule { return &randomizedSeedRule{modes: []string{"randomize"}} })
+	Register(func() Rule { return &absolutePathRule{} })
+	Register(func() Rule {
+		return &previewOnlyRule{previews: []string{"PreviewImage", "PreviewAudio", "PreviewAny", "MaskPreview"}}
+	})
+	Register(func() Rule { return &deprecatedNodeRule{} })
+	Register(func() Rule { return &safetensorsRule{extensions: []string{".safetensors"}} })
+}
+
+// randomizedSeedRule reports seeds that change after every run, which makes
+// results impossible to reproduce.
+type randomizedSeedRule struct {
+	modes []string
+}
+
+func (r *randomizedSeedRule) ID() string { return "no-randomized-seed" }
+
+func (r *randomizedSeedRule) Description() string {
+	return "Seeds and other generated values must not change after each run"
+}
+
+func (r *randomizedSeedRule) Severity() workflow.Severity { return workflow.SeverityWarning }
+
+// Configure accepts "modes", the control_after_generate modes to report.
+func (r *randomizedSeedRule) Configure(options map[string]interface{}) error {
+	if err := checkOptions(options, "modes"); err != nil {
+		return err
+	}
+	modes, ok, err := stringList(options, "modes")
+	if ok {
+		r.modes = modes
+	}
+	return err
+}
+
+func (r *randomizedSeedRule) Check(t *Target) []workflow.Issue {
+	var issues []workflow.Issue
+	report := func(node *workflow.Node, input string, mode interface{}) {
+		str, ok := mode.(string)
+		if !ok || !contains(r.modes, str) {
+			return
+		}
+		issues = append(issues, workflow.Issue{
+			NodeID:  node.ID,
+			Input:   input,
+			Message: fmt.Sprintf("%s is set to %q after each run; set it to \"fixed\" so results can be reproduced", input, str),
+		})
+	}
+
+	for i := range t.Workflow.Nodes {
+		node := &t.Workflow.Nodes[i]
+		if !node.IsActive() {
+			continue
+		}
+		// Primitive nodes store their control mode after the value.
+		if node.Type == "PrimitiveNode" {
+			if len(node.Widgets) >= 2 && len(t.Graph.Outgoing(node.ID)) > 0 {
+				report(node, "value", node.Widgets[1])
+			}
+			continue
+		}
+
+		def, ok := t.Registry.Lookup(node.Type)
+		if !ok {
+			continue
+		}
+		if mode, ok := node.NamedWidgets["control_after_generate"]; ok {
+			report(node, "control_after_generate", mode)
+			continue
+		}
+		slots, ok := def.WidgetLayout(len(node.Widgets))
+		if !ok {
+			continue
+		}
+		for j := 1; j < len(slots) && j < len(node.Widgets); j++ {
+			if slots[j].Input != nil || slots[j].Name != "control_after_generate" {
+				continue
+			}
+			input := slots[j-1].Name
+			// Values fed by another node are controlled there.
+			if _, linked := t.Graph.InputEdge(node.ID, input); linked {
+				continue
+			}
+			report(node, input, node.Widgets[j])
+		}
+	}
+	return issues
+}
+
+// absolutePathRule reports absolute file paths, which tie a workflow to one
+// machine.
+type absolutePathRule struct{}
+
+func (r *absolutePathRule) ID() string { return "no-absolute-paths" }
+
+func (r *absolutePathRule) Description() string {
+	return "Widget values and node properties must not hold absolute file paths"
+}
+
+func (r *absolutePathRule) Severity() workflow.Severity { return workflow.SeverityError }
+
+func (r *absolutePathRule) Check(t *Target) []workflow.Issue {
+	var issues []workflow.Issue
+	report := func(node *workflow.Node, input, field, value string) {
+		issues = append(issues, workflow.Issue{
+			NodeID:  node.ID,
+			Input:   input,
+			Message: fmt.Sprintf("%s is an absolute path (%s); use a path relative to ComfyUI's folders", field, value),
+		})
+	}
+
+	for i := range t.Workflow.Nodes {
+		node := &t.Workflow.Nodes[i]
+		for _, widget := range widgetValues(node, t.Registry) {
+			if str, ok := widget.value.(string); ok && workflow.IsAbsolutePath(str) {
+				report(node, widget.name, widget.name, str)
+			}
+		}
+		for _, key := range sortedKeys(node.Properties) {
+			if str, ok := node.Properties[key].(string); ok && workflow.IsAbsolutePath(str) {
+				report(node, "", "property "+key, str)
+			}
+		}
+	}
+	return issues
+}
+
+// previewOnlyRule reports workflows whose results are only previewed, so
+// nothing is kept when they run unattended.
+type previewOnlyRule struct {
+	previews []string
+}
+
+func (r *previewOnlyRule) ID() string { return "no-preview-only-output" }
+
+func (r *previewOnlyRule) Description() string {
+	return "Workflows must save their results rather than only preview them"
+}
+
+func (r *previewOnlyRule) Severity() workflow.Severity { return workflow.SeverityWarning }
+
+// Configure accepts "preview_nodes", the node types that only preview.
+func (r *previewOnlyRule) Configure(options map[string]interface{}) error {
+	if err := checkOptions(options, "preview_nodes"); err != nil {
+		return err
+	}
+	previews, ok, err := stringList(options, "preview_nodes")
+	if ok {
+		r.previews = previews
+	}
+	return err
+}
+
+func (r *previewOnlyRule) Check(t *Target) []workflow.Issue {
+	var previews []string
+	for i := range t.Workflow.Nodes {
+		node := &t.Workflow.Nodes[i]
+		if !node.IsActive() || workflow.IsFrontendNode(node.Type) {
+			continue
+		}
+		def, known := t.Registry.Lookup(node.Type)
+		output := len(t.Graph.Outgoing(node.ID)) == 0
+		if known {
+			output = def.OutputNode
+		}
+		switch {
+		case !output:
+		case contains(r.previews, node.Type):
+			previews = append(previews, fmt.Sprintf("%s (%s)", node.ID, node.Type))
+		default:
+			// Something is saved.
+			return nil
+		}
+	}
+	if len(previews) == 0 {
+		return nil
+	}
+	return []workflow.Issue{{
+		Message: fmt.Sprintf("results are only previewed by node %s; add a save node such as SaveImage", strings.Join(previews, ", ")),
+	}}
+}
+
+// deprecatedNodeRule reports nodes kept by ComfyUI or a node pack only for
+// old workflows.
+type deprecatedNodeRule struct {
+	nodes []string
+}
+
+func (r *deprecatedNodeRule) ID() string { return "no-deprecated-nodes" }
+
+func (r *deprecatedNodeRule) Description() string {
+	return "Workflows must not use deprecated nodes"
+}
+
+func (r *deprecatedNodeRule) Severity() workflow.Severity { return workflow.SeverityWarning }
+
+// Configure accepts "nodes", more node types to treat as deprecated besides
+// those the node definitions mark.
+func (r *deprecatedNodeRule) Configure(options map[string]interface{}) error {
+	if err := checkOptions(options, "nodes"); err != nil {
+		return err
+	}
+	nodes, _, err := stringList(options, "nodes")
+	r.nodes = nodes
+	return err
+}
+
+func (r *deprecatedNodeRule) Check(t *Target) []workflow.Issue {
+	var issues []workflow.Issue
+	for i := range t.Workflow.Nodes {
+		node := &t.Workflow.Nodes[i]
+		def, known := t.Registry.Lookup(node.Type)
+		if (known && def.Deprecated) || contains(r.nodes, node.Type) {
+			issues = append(issues, workflow.Issue{
+				NodeID:  node.ID,
+				Message: fmt.Sprintf("%s is deprecated", node.Type),
+			})
+		}
+	}
+	return issues
+}
+
+// modelExtensions are the file extensions of model weights.
+var modelExtensions = []string{".safetensors", ".sft", ".gguf", ".ckpt", ".pt", ".pth", ".bin", ".pkl"}
+
+// safetensorsRule reports models stored in other formats, in particular
+// pickle-based ones that can run code when loaded.
+type safetensorsRule struct {
+	extensions []string
+}
+
+func (r *safetensorsRule) ID() string { return "safetensors-only" }
+
+func (r *safetensorsRule) Description() string {
+	return "Models must be in safetensors format"
+}
+
+func (r *safetensorsRule) Severity() workflow.Severity { return workflow.SeverityError }
+
+// Configure accepts "extensions", the model file extensions allowed.
+func (r *safetensorsRule) Configure(options map[string]interface{}) error {
+	if err := checkOptions(options, "extensions"); err != nil {
+		return err
+	}
+	extensions, ok, err := stringList(options, "extensions")
+	if !ok {
+		return err
+	}
+	r.extensions = nil
+	for _, ext := range extensions {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		r.extensions = append(r.extensions, ext)
+	}
+	return nil
+}
+
+func (r *safetensorsRule) Check(t *Target) []workflow.Issue {
+	var issues []workflow.Issue
+	check := func(node *workflow.Node, field, name string) {
+		ext := strings.ToLower(path.Ext(name))
+		if !contains(modelExtensions, ext) || contains(r.extensions, ext) {
+			return
+		}
+		issue := workflow.Issue{
+			Input:   field,
+			Message: fmt.Sprintf("model %s is not in an allowed format (%s)", name, strings.Join(r.extensions, ", ")),
+		}
+		if node != nil {
+			issue.NodeID = node.ID
+		}
+		issues = append(issues, issue)
+	}
+
+	for i := range t.Workflow.Nodes {
+		node := &t.Workflow.Nodes[i]
+		if workflow.IsFrontendNode(node.Type) && node.Type != "PrimitiveNode" {
+			continue
+		}
+		for _, widget := range widgetValues(node, t.Registry) {
+			if str, ok := widget.value.(string); ok {
+				check(node, widget.name, str)
+			}
+		}
+	}
+	for _, model := range t.Workflow.Models {
+		check(nil, "", model.Name)
+	}
+	return issues
+}
+
+type namedValue struct {
+	name  string
+	value interface{}
+}
+
+// widgetValues lists a node's widget values, named by the node definition
+// or the widget inputs where possible and by position otherwise.
+func widgetValues(node *workflow.Node, reg *nodeschema.Registry) []namedValue {
+	var values []namedValue
+	if len(node.NamedWidgets) > 0 {
+		for _, name := range sortedKeys(node.NamedWidgets) {
+			values = append(values, namedValue{name, node.NamedWidgets[name]})
+		}
+		return values
+	}
+
+	names := make([]string, len(node.Widgets))
+	if def, ok := reg.Lookup(node.Type); ok {
+		if slots, ok := def.WidgetLayout(len(node.Widgets)); ok {
+			for i := range names {
+				if slots[i].Input == nil {
+					names[i] = "-"
+				} else {
+					names[i] = slots[i].Name
+				}
+			}
+		}
+	} else {
+		var widgets []string
+		for _, input := range node.Inputs {
+			if input.Widget != nil {
+				widgets = append(widgets, input.Widget.Name)
+			}
+		}
+		if len(widgets) == len(names) {
+			copy(names, widgets)
+		}
+	}
+
+	for i, value := range node.Widgets {
+		switch names[i] {
+		case "-":
+			// Control and upload values.
+			continue
+		case "":
+			names[i] = fmt.Sprintf("widget %d", i)
+		}
+		values = append(values, namedValue{names[i], value})
+	}
+	return values
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,175 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"runcomfy/pkg/workflow"
+)
+
+// FileResult holds the findings for one workflow file. Data is the file's
+// content, used to point findings at the line defining their node; it may
+// be nil.
+type FileResult struct {
+	Path   string
+	Data   []byte
+	Issues []workflow.Issue
+}
+
+// SARIF builds a SARIF 2.1.0 log of the findings, the format code review
+// tools such as GitHub code scanning read. The log lists the linter's rules
+// with their configured severities.
+func (l *Linter) SARIF(version string, files []FileResult) map[string]interface{} {
+	rules := make([]map[string]interface{}, 0, len(l.rules))
+	index := make(map[string]int, len(l.rules))
+	for i, rule := range l.rules {
+		index[rule.ID()] = i
+		rules = append(rules, map[string]interface{}{
+			"id":                   rule.ID(),
+			"shortDescription":     map[string]interface{}{"text": rule.Description()},
+			"defaultConfiguration": map[string]interface{}{"level": sarifLevel(l.severity[rule.ID()])},
+		})
+	}
+
+	results := []map[string]interface{}{}
+	for _, file := range files {
+		lines := nodeLines(file.Data)
+		for _, issue := range file.Issues {
+			location := map[string]interface{}{
+				"physicalLocation": map[string]interface{}{
+					"artifactLocation": map[string]interface{}{"uri": file.Path},
+					"region":           map[string]interface{}{"startLine": issueLine(lines, issue.NodeID)},
+				},
+			}
+			if issue.NodeID != "" {
+				name := "node " + issue.NodeID.String()
+				if issue.NodeType != "" {
+					name += " (" + issue.NodeType + ")"
+				}
+				location["logicalLocations"] = []map[string]interface{}{{"name": name, "kind": "object"}}
+			}
+
+			result := map[string]interface{}{
+				"ruleId":    issue.Code,
+				"level":     sarifLevel(issue.Severity),
+				"message":   map[string]interface{}{"text": issue.Message},
+				"locations": []map[string]interface{}{location},
+			}
+			if i, ok := index[issue.Code]; ok {
+				result["ruleIndex"] = i
+			}
+			results = append(results, result)
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]interface{}{{
+			"tool": map[string]interface{}{
+				"driver": map[string]interface{}{
+					"name":           "runcomfy",
+					"version":        version,
+					"informationUri": "https://github.com/niuguy/runcomfy",
+					"rules":          rules,
+				},
+			},
+			"results": results,
+		}},
+	}
+}
+
+func sarifLevel(severity workflow.Severity) string {
+	if severity == workflow.SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// nodeLines finds the line on which each top-level node of a workflow file
+// starts: the node object in a UI workflow, or its key in an API prompt.
+func nodeLines(data []byte) map[workflow.NodeID]int {
+	lines := make(map[workflow.NodeID]int)
+	lineAt := func(offset int64) int {
+		return bytes.Count(data[:offset], []byte("\n")) + 1
+	}
+	format, err := workflow.DetectFormat(data)
+	if err != nil {
+		return lines
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return lines
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return lines
+		}
+		key, _ := tok.(string)
+		if format == workflow.FormatAPI {
+			lines[workflow.NodeID(key)] = lineAt(dec.InputOffset())
+		}
+		if format == workflow.FormatAPI || key != "nodes" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return lines
+			}
+			continue
+		}
+
+		// Nodes are an array, or an object keyed by node id.
+		open, err := dec.Token()
+		if err != nil {
+			return lines
+		}
+		for dec.More() {
+			var id workflow.NodeID
+			if open == json.Delim('{') {
+				tok, err := dec.Token()
+				if err != nil {
+					return lines
+				}
+				key, _ := tok.(string)
+				id = workflow.NodeID(key)
+			}
+			var node json.RawMessage
+			if err := dec.Decode(&node); err != nil {
+				return lines
+			}
+			if open == json.Delim('[') {
+				var header struct {
+					ID workflow.NodeID `json:"id"`
+				}
+				if json.Unmarshal(node, &header) != nil {
+					continue
+				}
+				id = header.ID
+			}
+			lines[id] = lineAt(dec.InputOffset() - int64(len(node)))
+		}
+		if _, err := dec.Token(); err != nil {
+			return lines
+		}
+	}
+	return lines
+}
+
+// issueLine returns the line of the node an issue concerns or, for nodes
+// inside group nodes and subgraphs, of the node containing it. Issues about
+// the whole workflow point at the first line.
+func issueLine(lines map[workflow.NodeID]int, id workflow.NodeID) int {
+	for id != "" {
+		if line, ok := lines[id]; ok {
+			return line
+		}
+		i := strings.LastIndex(string(id), ":")
+		if i < 0 {
+			break
+		}
+		id = id[:i]
+	}
+	return 1
+}
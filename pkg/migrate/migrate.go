@@ -0,0 +1,480 @@
+// Package migrate rewrites workflows that use renamed or deprecated node
+// types so that they use the replacements instead. Each replacement is
+// described by a Rule; the built-in rules cover deprecated ComfyUI core
+// nodes and nodes removed from popular custom node packs, and more can be
+// loaded from YAML files with LoadRules.
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"runcomfy/pkg/workflow"
+)
+
+// Rule replaces one node type with another.
+type Rule struct {
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
+
+	// Inputs renames inputs and widgets, from the old name to the new one.
+	Inputs map[string]string `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+
+	// FromWidgets names the widget values of the old node in the order a UI
+	// workflow stores them, and ToWidgets those of the new node. Values are
+	// carried over by name, after renaming: old values missing from
+	// ToWidgets are dropped and new ones are set from Defaults. When both
+	// are empty the widget values are kept as they are.
+	//
+	// Values that only exist in the editor, such as the mode stored after a
+	// seed, are named control_after_generate or upload, optionally with a
+	// suffix ("control_after_generate_noise"); they are left out of API
+	// prompts.
+	FromWidgets []string               `yaml:"from_widgets,omitempty" json:"fromWidgets,omitempty"`
+	ToWidgets   []string               `yaml:"to_widgets,omitempty" json:"toWidgets,omitempty"`
+	Defaults    map[string]interface{} `yaml:"defaults,omitempty" json:"defaults,omitempty"`
+
+	// Note explains the migration in reports.
+	Note string `yaml:"note,omitempty" json:"note,omitempty"`
+}
+
+func (r Rule) validate() error {
+	if r.From == "" || r.To == "" {
+		return fmt.Errorf("migration rule needs both from and to")
+	}
+	if r.From == r.To {
+		return fmt.Errorf("migration rule for %s replaces it with itself", r.From)
+	}
+	if (len(r.FromWidgets) == 0) != (len(r.ToWidgets) == 0) {
+		return fmt.Errorf("migration rule for %s: from_widgets and to_widgets must be given together", r.From)
+	}
+	if len(r.Defaults) > 0 && len(r.ToWidgets) == 0 {
+		return fmt.Errorf("migration rule for %s: defaults need to_widgets", r.From)
+	}
+
+	available := make(map[string]bool)
+	for _, name := range r.FromWidgets {
+		if available[r.rename(name)] {
+			return fmt.Errorf("migration rule for %s: widget %s is listed twice", r.From, name)
+		}
+		available[r.rename(name)] = true
+	}
+	for name := range r.Defaults {
+		available[name] = true
+	}
+	seen := make(map[string]bool)
+	for _, name := range r.ToWidgets {
+		if seen[name] {
+			return fmt.Errorf("migration rule for %s: widget %s is listed twice", r.From, name)
+		}
+		seen[name] = true
+		if !available[name] {
+			return fmt.Errorf("migration rule for %s: no value for widget %s; add it to defaults", r.From, name)
+		}
+	}
+	return nil
+}
+
+func (r Rule) rename(name string) string {
+	if to, ok := r.Inputs[name]; ok {
+		return to
+	}
+	return name
+}
+
+// editorOnly reports whether a widget value exists only in UI workflows.
+func editorOnly(name string) bool {
+	return strings.HasPrefix(name, "control_after_generate") || strings.HasPrefix(name, "upload")
+}
+
+// Change records the migration of one node. Skipped explains why a node
+// with a matching rule was left unchanged.
+type Change struct {
+	NodeID  workflow.NodeID `json:"nodeId"`
+	From    string          `json:"from"`
+	To      string          `json:"to"`
+	Details []string        `json:"details,omitempty"`
+	Note    string          `json:"note,omitempty"`
+	Skipped string          `json:"skipped,omitempty"`
+}
+
+// Migrator applies a set of migration rules.
+type Migrator struct {
+	rules map[string]Rule
+}
+
+// New builds a migrator from rules. A rule replaces any earlier one for the
+// same node type, so rules loaded after Builtin take precedence. Rules may
+// chain, replacing A with B and B with C, but not form a cycle.
+func New(rules []Rule) (*Migrator, error) {
+	m := &Migrator{rules: make(map[string]Rule, len(rules))}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		m.rules[rule.From] = rule
+	}
+
+	for from := range m.rules {
+		visited := map[string]bool{from: true}
+		path := []string{from}
+		for rule, ok := m.rules[from]; ok; rule, ok = m.rules[rule.To] {
+			path = append(path, rule.To)
+			if visited[rule.To] {
+				return nil, fmt.Errorf("migration rules form a cycle: %s", strings.Join(path, " → "))
+			}
+			visited[rule.To] = true
+		}
+	}
+	return m, nil
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultMigrator *Migrator
+)
+
+// Default returns a migrator with the built-in rules.
+func Default() *Migrator {
+	defaultOnce.Do(func() {
+		m, err := New(Builtin())
+		if err != nil {
+			panic(fmt.Sprintf("migrate: invalid built-in rules: %v", err))
+		}
+		defaultMigrator = m
+	})
+	return defaultMigrator
+}
+
+// Rules returns the migrator's rules, ordered by the node type they replace.
+func (m *Migrator) Rules() []Rule {
+	rules := make([]Rule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].From < rules[j].From })
+	return rules
+}
+
+// Chain returns the rules that migrate a node type, in the order they
+// apply; the last one names the final replacement. It is empty when the
+// node type has no migration.
+func (m *Migrator) Chain(nodeType string) []Rule {
+	var chain []Rule
+	for rule, ok := m.rules[nodeType]; ok; rule, ok = m.rules[rule.To] {
+		chain = append(chain, rule)
+	}
+	return chain
+}
+
+// Migrate replaces the node types of the workflow that have a migration, in
+// place, including nodes inside subgraph and group node definitions. It
+// returns one Change per node with a migration; nodes that cannot be
+// migrated are reported as skipped and left unchanged.
+func (m *Migrator) Migrate(w *workflow.Workflow) ([]Change, error) {
+	changes := m.graph(w.Nodes, w.Links)
+	if w.Definitions != nil {
+		for i := range w.Definitions.Subgraphs {
+			sg := &w.Definitions.Subgraphs[i]
+			changes = append(changes, m.graph(sg.Nodes, sg.Links)...)
+		}
+	}
+	groupChanges, err := m.groupNodes(w.Extra)
+	if err != nil {
+		return nil, err
+	}
+	return append(changes, groupChanges...), nil
+}
+
+// graph migrates the nodes of one graph. Links are updated when a dropped
+// widget input shifts the slots after it.
+func (m *Migrator) graph(nodes []workflow.Node, links []workflow.Link) []Change {
+	index := make(map[workflow.NodeID]int, len(nodes))
+	for i := range nodes {
+		index[nodes[i].ID] = i
+	}
+
+	var changes []Change
+	for i := range nodes {
+		chain := m.Chain(nodes[i].Type)
+		if len(chain) == 0 {
+			continue
+		}
+
+		node := copyNode(&nodes[i])
+		change := Change{NodeID: node.ID, From: node.Type, To: chain[len(chain)-1].To}
+		var notes []string
+		var removed []int
+		for _, rule := range chain {
+			details, dropped, err := migrateNode(&node, rule)
+			if err != nil {
+				change.Skipped = err.Error()
+				break
+			}
+			change.Details = append(change.Details, details...)
+			removed = append(removed, dropped...)
+			if rule.Note != "" {
+				notes = append(notes, rule.Note)
+			}
+		}
+		change.Note = strings.Join(notes, " ")
+		changes = append(changes, change)
+		if change.Skipped != "" {
+			continue
+		}
+		nodes[i] = node
+
+		// Slots are removed one at a time, each index referring to the
+		// inputs left by the previous removals.
+		for _, slot := range removed {
+			for j := range links {
+				if links[j].TargetID == node.ID && links[j].TargetSlot > slot {
+					links[j].TargetSlot--
+				}
+			}
+		}
+
+		// Primitive nodes carry the name of the widget they feed.
+		for _, input := range node.Inputs {
+			if input.Link == nil || input.Widget == nil {
+				continue
+			}
+			for _, link := range links {
+				if link.ID != *input.Link {
+					continue
+				}
+				j, ok := index[link.OriginID]
+				if !ok || nodes[j].Type != "PrimitiveNode" || link.OriginSlot >= len(nodes[j].Outputs) {
+					break
+				}
+				if output := &nodes[j].Outputs[link.OriginSlot]; output.Widget != nil {
+					output.Widget = &workflow.InputWidget{Name: input.Widget.Name}
+				}
+				break
+			}
+		}
+	}
+	return changes
+}
+
+// migrateNode applies one rule to a node. It returns what changed and the
+// indexes of the input slots removed, in order.
+func migrateNode(node *workflow.Node, rule Rule) ([]string, []int, error) {
+	var details []string
+	renamed := make(map[string]bool)
+	rename := func(name string) string {
+		to := rule.rename(name)
+		if to != name && !renamed[name] {
+			renamed[name] = true
+			details = append(details, fmt.Sprintf("renamed %s to %s", name, to))
+		}
+		return to
+	}
+
+	for j := range node.Inputs {
+		input := &node.Inputs[j]
+		input.Name = rename(input.Name)
+		if input.Widget != nil {
+			input.Widget = &workflow.InputWidget{Name: rename(input.Widget.Name)}
+		}
+	}
+	if node.NamedWidgets != nil {
+		named := make(map[string]interface{}, len(node.NamedWidgets))
+		for _, name := range sortedKeys(node.NamedWidgets) {
+			named[rename(name)] = node.NamedWidgets[name]
+		}
+		node.NamedWidgets = named
+	} else if len(rule.FromWidgets) > 0 && len(node.Widgets) > 0 {
+		for _, name := range rule.FromWidgets {
+			rename(name)
+		}
+	}
+
+	var removed []int
+	if len(rule.ToWidgets) > 0 {
+		keep := make(map[string]bool, len(rule.ToWidgets))
+		for _, name := range rule.ToWidgets {
+			keep[name] = true
+		}
+		var dropped []string
+		for _, name := range rule.FromWidgets {
+			if name = rule.rename(name); !keep[name] {
+				dropped = append(dropped, name)
+			}
+		}
+
+		// Widgets converted to inputs, or listed as inputs by newer
+		// editors, go with the widget unless something is connected.
+		for _, name := range dropped {
+			for j := 0; j < len(node.Inputs); j++ {
+				input := node.Inputs[j]
+				if input.Name != name && (input.Widget == nil || input.Widget.Name != name) {
+					continue
+				}
+				if input.Link != nil {
+					return nil, nil, fmt.Errorf("input %s is connected, but %s has no such input", name, rule.To)
+				}
+				node.Inputs = append(node.Inputs[:j], node.Inputs[j+1:]...)
+				removed = append(removed, j)
+				j--
+			}
+		}
+
+		switch {
+		case node.NamedWidgets != nil:
+			for _, name := range dropped {
+				if value, ok := node.NamedWidgets[name]; ok {
+					details = append(details, fmt.Sprintf("dropped %s (%s)", name, formatValue(value)))
+					delete(node.NamedWidgets, name)
+				}
+			}
+			for _, name := range rule.ToWidgets {
+				value, ok := rule.Defaults[name]
+				if _, exists := node.NamedWidgets[name]; exists || !ok || editorOnly(name) || hasInput(node, name) {
+					continue
+				}
+				node.NamedWidgets[name] = value
+				details = append(details, fmt.Sprintf("set %s to %s", name, formatValue(value)))
+			}
+		case len(node.Widgets) > 0:
+			if len(node.Widgets) != len(rule.FromWidgets) {
+				return nil, nil, fmt.Errorf("node has %d widget values where %d are expected (%s)", len(node.Widgets), len(rule.FromWidgets), strings.Join(rule.FromWidgets, ", "))
+			}
+			values := make(map[string]interface{}, len(node.Widgets))
+			for j, name := range rule.FromWidgets {
+				values[rule.rename(name)] = node.Widgets[j]
+			}
+			for _, name := range dropped {
+				details = append(details, fmt.Sprintf("dropped %s (%s)", name, formatValue(values[name])))
+			}
+			widgets := make([]interface{}, len(rule.ToWidgets))
+			for j, name := range rule.ToWidgets {
+				value, ok := values[name]
+				if !ok {
+					value = rule.Defaults[name]
+					details = append(details, fmt.Sprintf("set %s to %s", name, formatValue(value)))
+				}
+				widgets[j] = value
+			}
+			node.Widgets = widgets
+		}
+	}
+
+	if name, _ := node.Properties["Node name for S&R"].(string); name == rule.From {
+		node.Properties["Node name for S&R"] = rule.To
+	}
+	node.Type = rule.To
+	return details, removed, nil
+}
+
+// groupNodes migrates the nodes of the group node templates in extra. The
+// values and inputs of template nodes are stored with each group node
+// instance, so only rules that replace the node type alone are applied.
+func (m *Migrator) groupNodes(extra map[string]interface{}) ([]Change, error) {
+	defs, ok := extra["groupNodes"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var changes []Change
+	for _, name := range sortedKeys(defs) {
+		def, ok := defs[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		templates, _ := def["nodes"].([]interface{})
+		for i, item := range templates {
+			template, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			nodeType, _ := template["type"].(string)
+			chain := m.Chain(nodeType)
+			if len(chain) == 0 {
+				continue
+			}
+
+			id := fmt.Sprint(i)
+			if value, ok := template["id"]; ok && value != nil {
+				id = fmt.Sprint(value)
+			}
+			change := Change{NodeID: workflow.NodeID(name + ":" + id), From: nodeType, To: chain[len(chain)-1].To}
+			var notes []string
+			for _, rule := range chain {
+				if len(rule.Inputs) > 0 || len(rule.ToWidgets) > 0 {
+					change.Skipped = fmt.Sprintf("the node is part of group node %q, whose inputs and widget values this migration would change; convert the group node to a subgraph and migrate again", name)
+					break
+				}
+				if rule.Note != "" {
+					notes = append(notes, rule.Note)
+				}
+			}
+			change.Note = strings.Join(notes, " ")
+			changes = append(changes, change)
+			if change.Skipped != "" {
+				continue
+			}
+
+			template["type"] = change.To
+			if properties, ok := template["properties"].(map[string]interface{}); ok && properties["Node name for S&R"] == nodeType {
+				properties["Node name for S&R"] = change.To
+			}
+		}
+	}
+	return changes, nil
+}
+
+// copyNode copies a node deeply enough that migrating the copy leaves the
+// original untouched.
+func copyNode(node *workflow.Node) workflow.Node {
+	c := *node
+	if node.Widgets != nil {
+		c.Widgets = append([]interface{}(nil), node.Widgets...)
+	}
+	if node.NamedWidgets != nil {
+		c.NamedWidgets = make(map[string]interface{}, len(node.NamedWidgets))
+		for name, value := range node.NamedWidgets {
+			c.NamedWidgets[name] = value
+		}
+	}
+	if node.Properties != nil {
+		c.Properties = make(map[string]interface{}, len(node.Properties))
+		for key, value := range node.Properties {
+			c.Properties[key] = value
+		}
+	}
+	if node.Inputs != nil {
+		c.Inputs = append([]workflow.Input(nil), node.Inputs...)
+	}
+	return c
+}
+
+func hasInput(node *workflow.Node, name string) bool {
+	for _, input := range node.Inputs {
+		if input.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue shows a widget value in a report.
+func formatValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
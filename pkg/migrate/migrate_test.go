@@ -0,0 +1,291 @@
+package migrate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"runcomfy/pkg/workflow"
+)
+
+func parseFixture(t *testing.T, name string) *workflow.Workflow {
+	t.Helper()
+	w, err := workflow.ParseWorkflow(filepath.Join("..", "workflow", "testdata", name))
+	if err != nil {
+		t.Fatalf("ParseWorkflow: %v", err)
+	}
+	return w
+}
+
+func findNode(t *testing.T, w *workflow.Workflow, id workflow.NodeID) *workflow.Node {
+	t.Helper()
+	for i := range w.Nodes {
+		if w.Nodes[i].ID == id {
+			return &w.Nodes[i]
+		}
+	}
+	t.Fatalf("node %s not found", id)
+	return nil
+}
+
+func TestMigrateFixtures(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		rules []Rule // the built-in rules when nil
+		// setup turns the fixture into a workflow that needs migrating.
+		setup func(t *testing.T, w *workflow.Workflow)
+		want  []Change
+		check func(t *testing.T, w *workflow.Workflow)
+	}{
+		{
+			name: "deprecated core node in a UI workflow",
+			file: "sdxl_inpaint_v04.json",
+			setup: func(t *testing.T, w *workflow.Workflow) {
+				node := findNode(t, w, "1")
+				node.Type = "CheckpointLoader"
+				node.Widgets = []interface{}{"v1-inference.yaml", "sd_xl_base_1.0.safetensors"}
+			},
+			want: []Change{{
+				NodeID:  "1",
+				From:    "CheckpointLoader",
+				To:      "CheckpointLoaderSimple",
+				Details: []string{`dropped config_name ("v1-inference.yaml")`},
+				Note:    "Load Checkpoint With Config is deprecated; ComfyUI detects the model configuration itself.",
+			}},
+			check: func(t *testing.T, w *workflow.Workflow) {
+				// The migrated workflow is the original again, so it
+				// exports to the committed prompt.
+				prompt, err := w.ToPrompt(nil)
+				if err != nil {
+					t.Fatalf("ToPrompt: %v", err)
+				}
+				got, err := json.Marshal(prompt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := os.ReadFile(filepath.Join("..", "workflow", "testdata", "sdxl_inpaint_api.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !equalJSON(t, got, want) {
+					t.Errorf("ToPrompt = %s, want sdxl_inpaint_api.json", got)
+				}
+			},
+		},
+		{
+			name: "deprecated core node in an API prompt",
+			file: "sdxl_inpaint_api.json",
+			setup: func(t *testing.T, w *workflow.Workflow) {
+				node := findNode(t, w, "1")
+				node.Type = "CheckpointLoader"
+				node.NamedWidgets["config_name"] = "v1-inference.yaml"
+			},
+			want: []Change{{
+				NodeID:  "1",
+				From:    "CheckpointLoader",
+				To:      "CheckpointLoaderSimple",
+				Details: []string{`dropped config_name ("v1-inference.yaml")`},
+				Note:    "Load Checkpoint With Config is deprecated; ComfyUI detects the model configuration itself.",
+			}},
+			check: func(t *testing.T, w *workflow.Workflow) {
+				want := map[string]interface{}{"ckpt_name": "sd_xl_base_1.0.safetensors"}
+				if got := findNode(t, w, "1").NamedWidgets; !reflect.DeepEqual(got, want) {
+					t.Errorf("widgets = %v, want %v", got, want)
+				}
+			},
+		},
+		{
+			name: "widget values that do not fit the rule",
+			file: "sdxl_inpaint_v04.json",
+			setup: func(t *testing.T, w *workflow.Workflow) {
+				findNode(t, w, "1").Type = "CheckpointLoader"
+			},
+			want: []Change{{
+				NodeID:  "1",
+				From:    "CheckpointLoader",
+				To:      "CheckpointLoaderSimple",
+				Skipped: "node has 1 widget values where 2 are expected (config_name, ckpt_name)",
+			}},
+			check: func(t *testing.T, w *workflow.Workflow) {
+				if node := findNode(t, w, "1"); node.Type != "CheckpointLoader" {
+					t.Errorf("skipped node type = %s, want it unchanged", node.Type)
+				}
+			},
+		},
+		{
+			name: "renamed input fed by a primitive",
+			file: "sdxl_primitive_reroute_v04.json",
+			rules: []Rule{{
+				From:        "KSampler",
+				To:          "KSamplerNoise",
+				Inputs:      map[string]string{"seed": "noise_seed"},
+				FromWidgets: []string{"seed", "control_after_generate", "steps", "cfg", "sampler_name", "scheduler", "denoise"},
+				ToWidgets:   []string{"noise_seed", "control_after_generate", "steps", "cfg", "sampler_name", "scheduler"},
+			}},
+			want: []Change{{
+				NodeID:  "3",
+				From:    "KSampler",
+				To:      "KSamplerNoise",
+				Details: []string{"renamed seed to noise_seed", "dropped denoise (1)"},
+			}},
+			check: func(t *testing.T, w *workflow.Workflow) {
+				node := findNode(t, w, "3")
+				want := []interface{}{float64(123456789), "fixed", float64(30), float64(5), "dpmpp_2m_sde", "karras"}
+				if !reflect.DeepEqual(node.Widgets, want) {
+					t.Errorf("widgets = %v, want %v", node.Widgets, want)
+				}
+				if input := node.Inputs[4]; input.Name != "noise_seed" || input.Widget == nil || input.Widget.Name != "noise_seed" {
+					t.Errorf("input = %+v, want noise_seed", input)
+				}
+				if output := findNode(t, w, "18").Outputs[0]; output.Widget == nil || output.Widget.Name != "noise_seed" {
+					t.Errorf("primitive output = %+v, want it to feed noise_seed", output)
+				}
+			},
+		},
+		{
+			name: "chained rules",
+			file: "sdxl_inpaint_api.json",
+			rules: []Rule{
+				{From: "VAEEncode", To: "VAEEncodeV2", Note: "Renamed."},
+				{From: "VAEEncodeV2", To: "VAEEncodeV3", Inputs: map[string]string{"pixels": "image"}, Note: "Renamed again."},
+			},
+			want: []Change{{
+				NodeID:  "6",
+				From:    "VAEEncode",
+				To:      "VAEEncodeV3",
+				Details: []string{"renamed pixels to image"},
+				Note:    "Renamed. Renamed again.",
+			}},
+		},
+		{
+			name:  "node type inside a group node",
+			file:  "sdxl_group_node_v04.json",
+			rules: []Rule{{From: "LoraLoader", To: "LoraLoaderV2"}},
+			want:  []Change{{NodeID: "SDXL Loader:1", From: "LoraLoader", To: "LoraLoaderV2"}},
+			check: func(t *testing.T, w *workflow.Workflow) {
+				template := w.Extra["groupNodes"].(map[string]interface{})["SDXL Loader"].(map[string]interface{})["nodes"].([]interface{})[1].(map[string]interface{})
+				if template["type"] != "LoraLoaderV2" {
+					t.Errorf("template type = %v, want LoraLoaderV2", template["type"])
+				}
+			},
+		},
+		{
+			name:  "renamed input inside a group node",
+			file:  "sdxl_group_node_v04.json",
+			rules: []Rule{{From: "LoraLoader", To: "LoraLoaderV2", Inputs: map[string]string{"clip": "text_encoder"}}},
+			want: []Change{{
+				NodeID:  "SDXL Loader:1",
+				From:    "LoraLoader",
+				To:      "LoraLoaderV2",
+				Skipped: `the node is part of group node "SDXL Loader", whose inputs and widget values this migration would change; convert the group node to a subgraph and migrate again`,
+			}},
+		},
+		{
+			name: "nothing to migrate",
+			file: "flux_subgraph_v1.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := parseFixture(t, tt.file)
+			if tt.setup != nil {
+				tt.setup(t, w)
+			}
+			m := Default()
+			if tt.rules != nil {
+				var err error
+				if m, err = New(tt.rules); err != nil {
+					t.Fatalf("New: %v", err)
+				}
+			}
+			changes, err := m.Migrate(w)
+			if err != nil {
+				t.Fatalf("Migrate: %v", err)
+			}
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("changes = %+v\nwant %+v", changes, tt.want)
+			}
+			if tt.check != nil {
+				tt.check(t, w)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		err   string
+	}{
+		{"missing to", []Rule{{From: "A"}}, "needs both from and to"},
+		{"itself", []Rule{{From: "A", To: "A"}}, "replaces it with itself"},
+		{"widgets alone", []Rule{{From: "A", To: "B", FromWidgets: []string{"x"}}}, "must be given together"},
+		{"defaults alone", []Rule{{From: "A", To: "B", Defaults: map[string]interface{}{"x": 1}}}, "defaults need to_widgets"},
+		{"missing value", []Rule{{From: "A", To: "B", FromWidgets: []string{"x"}, ToWidgets: []string{"y"}}}, "no value for widget y"},
+		{"listed twice", []Rule{{From: "A", To: "B", FromWidgets: []string{"x", "x"}, ToWidgets: []string{"x"}}}, "listed twice"},
+		{"cycle", []Rule{{From: "A", To: "B"}, {From: "B", To: "C"}, {From: "C", To: "A"}}, "form a cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.rules); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("New = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.yaml")
+	data := `- from: OldSampler
+  to: NewSampler
+  inputs: {latent: latent_image}
+  from_widgets: [seed, control_after_generate, steps]
+  to_widgets: [seed, control_after_generate, steps, denoise]
+  defaults: {denoise: 1.0}
+  note: OldSampler was renamed in version 2 of the pack.
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+	want := []Rule{{
+		From:        "OldSampler",
+		To:          "NewSampler",
+		Inputs:      map[string]string{"latent": "latent_image"},
+		FromWidgets: []string{"seed", "control_after_generate", "steps"},
+		ToWidgets:   []string{"seed", "control_after_generate", "steps", "denoise"},
+		Defaults:    map[string]interface{}{"denoise": 1.0},
+		Note:        "OldSampler was renamed in version 2 of the pack.",
+	}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules = %+v, want %+v", rules, want)
+	}
+
+	if err := os.WriteFile(path, []byte("- from: A\n  to: B\n  rename: {x: y}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRules(path); err == nil || !strings.Contains(err.Error(), "rename") {
+		t.Errorf("LoadRules with an unknown field = %v, want an error naming it", err)
+	}
+}
+
+// equalJSON reports whether a and b decode to the same value.
+func equalJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Builtin returns the migrations of deprecated ComfyUI core nodes and of
+// nodes removed from popular custom node packs. Only the latter show up as
+// missing nodes; deprecated core nodes still load, but are best replaced.
+func Builtin() []Rule {
+	return []Rule{
+		{
+			From:        "CheckpointLoader",
+			To:          "CheckpointLoaderSimple",
+			FromWidgets: []string{"config_name", "ckpt_name"},
+			ToWidgets:   []string{"ckpt_name"},
+			Note:        "Load Checkpoint With Config is deprecated; ComfyUI detects the model configuration itself.",
+		},
+
+		// ComfyUI_IPAdapter_plus v2 removed the v1 apply nodes. The v1
+		// weight types have no v2 equivalent, so they are dropped in favour
+		// of the default.
+		{
+			From:        "IPAdapterApply",
+			To:          "IPAdapterAdvanced",
+			Inputs:      map[string]string{"weight_type": "weight_type_v1"},
+			FromWidgets: []string{"weight", "noise", "weight_type", "start_at", "end_at", "unfold_batch"},
+			ToWidgets:   []string{"weight", "weight_type", "combine_embeds", "start_at", "end_at", "embeds_scaling"},
+			Defaults: map[string]interface{}{
+				"weight_type":    "linear",
+				"combine_embeds": "concat",
+				"embeds_scaling": "V only",
+			},
+			Note: "IPAdapter Plus v2 replaced Apply IPAdapter with IPAdapter Advanced.",
+		},
+		{
+			From:        "IPAdapterApplyFaceID",
+			To:          "IPAdapterFaceID",
+			Inputs:      map[string]string{"weight_type": "weight_type_v1", "weight_v2": "weight_faceidv2"},
+			FromWidgets: []string{"weight", "noise", "weight_type", "start_at", "end_at", "faceid_v2", "weight_v2", "unfold_batch"},
+			ToWidgets:   []string{"weight", "weight_faceidv2", "weight_type", "combine_embeds", "start_at", "end_at", "embeds_scaling"},
+			Defaults: map[string]interface{}{
+				"weight_type":    "linear",
+				"combine_embeds": "concat",
+				"embeds_scaling": "V only",
+			},
+			Note: "IPAdapter Plus v2 replaced Apply IPAdapter FaceID with IPAdapter FaceID, which detects FaceID v2 models itself.",
+		},
+	}
+}
+
+// LoadRules reads migration rules from a YAML file holding a list of rules:
+//
+//	# migrations.yaml
+//	- from: OldSampler
+//	  to: NewSampler
+//	  inputs: {latent: latent_image}
+//	  from_widgets: [seed, control_after_generate, steps]
+//	  to_widgets: [seed, control_after_generate, steps, denoise]
+//	  defaults: {denoise: 1.0}
+//	  note: OldSampler was renamed in version 2 of the pack.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration rules: %w", err)
+	}
+
+	var rules []Rule
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse migration rules %s: %w", path, err)
+	}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return rules, nil
+}
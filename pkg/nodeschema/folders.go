@@ -0,0 +1,79 @@
+package nodeschema
+
+// nodeInputFolders maps loader inputs whose name alone does not tell which
+// model folder they select from.
+var nodeInputFolders = map[string]map[string]string{
+	"CheckpointLoader":       {"config_name": "configs"},
+	"UpscaleModelLoader":     {"model_name": "upscale_models"},
+	"CLIPVisionLoader":       {"clip_name": "clip_vision"},
+	"HypernetworkLoader":     {"hypernetwork_name": "hypernetworks"},
+	"PhotoMakerLoader":       {"photomaker_model_name": "photomaker"},
+	"DiffusersLoader":        {"model_path": "diffusers"},
+	"unCLIPCheckpointLoader": {"ckpt_name": "checkpoints"},
+
+	// AnimateDiff-Evolved motion model loaders.
+	"ADE_AnimateDiffLoaderGen1":        {"model_name": "animatediff_models"},
+	"ADE_AnimateDiffLoaderWithContext": {"model_name": "animatediff_models"},
+	"ADE_LoadAnimateDiffModel":         {"model_name": "animatediff_models"},
+}
+
+// inputFolders maps well-known loader input names to the model folder they
+// select from.
+var inputFolders = map[string]string{
+	"ckpt_name":        "checkpoints",
+	"lora_name":        "loras",
+	"vae_name":         "vae",
+	"unet_name":        "unet",
+	"clip_name":        "clip",
+	"clip_name1":       "clip",
+	"clip_name2":       "clip",
+	"clip_name3":       "clip",
+	"control_net_name": "controlnet",
+	"style_model_name": "style_models",
+	"gligen_name":      "gligen",
+}
+
+// InputFolder returns the model folder a node input selects from, or an
+// empty string when the input does not name a model.
+func InputFolder(nodeType, input string) string {
+	if folder, ok := nodeInputFolders[nodeType][input]; ok {
+		return folder
+	}
+	return inputFolders[input]
+}
+
+// folderAliases maps legacy model folder names to the folder ComfyUI now
+// uses for the same models; both are searched.
+var folderAliases = map[string]string{
+	"unet": "diffusion_models",
+	"clip": "text_encoders",
+}
+
+// CanonicalFolder returns the model folder that ComfyUI searches together
+// with folder under its current name, such as "diffusion_models" for "unet".
+func CanonicalFolder(folder string) string {
+	if canonical, ok := folderAliases[folder]; ok {
+		return canonical
+	}
+	return folder
+}
+
+// assetInputs maps loader inputs that pick a file from ComfyUI's input
+// directory. Node definitions mark these with an upload option; the map
+// covers nodes used without a definition, such as those of popular packs.
+var assetInputs = map[string]string{
+	"LoadImage":     "image",
+	"LoadImageMask": "image",
+	"LoadAudio":     "audio",
+	"LoadVideo":     "file",
+
+	// ComfyUI-VideoHelperSuite upload loaders.
+	"VHS_LoadVideo":       "video",
+	"VHS_LoadAudioUpload": "audio",
+}
+
+// IsAssetInput reports whether a node input names a file in
+// ComfyUI's input directory.
+func IsAssetInput(nodeType, input string) bool {
+	return assetInputs[nodeType] == input
+}
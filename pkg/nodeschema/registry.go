@@ -0,0 +1,394 @@
+// Package nodeschema describes ComfyUI node classes: their inputs, widget
+// order, value constraints and the model folders their combos select from.
+// Definitions are read from an /object_info dump, with a bundled snapshot of
+// the core nodes as fallback.
+package nodeschema
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed object_info.json
+var bundledObjectInfo []byte
+
+var (
+	bundledOnce     sync.Once
+	bundledRegistry *Registry
+)
+
+// Registry holds node definitions keyed by class type.
+type Registry struct {
+	nodes map[string]*NodeDef
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{nodes: make(map[string]*NodeDef)}
+}
+
+// Bundled returns the registry built from the snapshot of core nodes shipped
+// with runcomfy.
+func Bundled() *Registry {
+	bundledOnce.Do(func() {
+		reg, err := Parse(bundledObjectInfo)
+		if err != nil {
+			panic(fmt.Sprintf("nodeschema: invalid bundled object_info: %v", err))
+		}
+		bundledRegistry = reg
+	})
+	return bundledRegistry
+}
+
+// Load reads an /object_info dump from disk.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object_info file: %w", err)
+	}
+	return Parse(data)
+}
+
+// LoadWithBundled reads an /object_info dump and layers it over the bundled
+// snapshot. An empty path returns the bundled registry alone.
+func LoadWithBundled(path string) (*Registry, error) {
+	if path == "" {
+		return Bundled(), nil
+	}
+	reg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return Bundled().Merge(reg), nil
+}
+
+// Parse decodes the JSON returned by ComfyUI's /object_info endpoint.
+func Parse(data []byte) (*Registry, error) {
+	var raw map[string]rawNode
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse object_info JSON: %w", err)
+	}
+
+	reg := NewRegistry()
+	for name, node := range raw {
+		def, err := node.def(name)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", name, err)
+		}
+		reg.nodes[name] = def
+	}
+	return reg, nil
+}
+
+// Merge returns a registry holding the definitions of both registries, with
+// those of other taking precedence.
+func (r *Registry) Merge(other *Registry) *Registry {
+	merged := NewRegistry()
+	for name, def := range r.nodes {
+		merged.nodes[name] = def
+	}
+	for name, def := range other.nodes {
+		merged.nodes[name] = def
+	}
+	return merged
+}
+
+// Lookup returns the definition of a node class.
+func (r *Registry) Lookup(nodeType string) (*NodeDef, bool) {
+	if r == nil {
+		return nil, false
+	}
+	def, ok := r.nodes[nodeType]
+	return def, ok
+}
+
+// Names returns every node class in the registry, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.nodes))
+	for name := range r.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of node classes in the registry.
+func (r *Registry) Len() int {
+	return len(r.nodes)
+}
+
+// Input returns the named input.
+func (d *NodeDef) Input(name string) (*InputDef, bool) {
+	for i := range d.Inputs {
+		if d.Inputs[i].Name == name {
+			return &d.Inputs[i], true
+		}
+	}
+	return nil, false
+}
+
+// IsWidget reports whether the input is edited through a widget rather than
+// fed by a link.
+func (in *InputDef) IsWidget() bool {
+	if in.ForceInput {
+		return false
+	}
+	switch in.Type {
+	case TypeInt, TypeFloat, TypeString, TypeBoolean, TypeCombo:
+		return true
+	}
+	return false
+}
+
+// WidgetSlots returns the layout of the node's widgets_values: one slot per
+// widget input, followed where applicable by the control_after_generate
+// value or the upload button value the frontend stores alongside it.
+func (d *NodeDef) WidgetSlots() []WidgetSlot {
+	var slots []WidgetSlot
+	for i := range d.Inputs {
+		in := &d.Inputs[i]
+		if !in.IsWidget() {
+			continue
+		}
+		slots = append(slots, WidgetSlot{Name: in.Name, Input: in})
+		if in.ControlAfterGenerate {
+			slots = append(slots, WidgetSlot{Name: "control_after_generate"})
+		}
+		if in.Upload != "" {
+			slots = append(slots, WidgetSlot{Name: "upload"})
+		}
+	}
+	return slots
+}
+
+// MapWidgets names positional widget values. Values saved without the extra
+// control and upload entries, or before trailing optional widgets were added
+// to the node, are accepted too. It returns false when the count fits no
+// layout, which usually means the node definition and the workflow come from
+// different versions of the node.
+func (d *NodeDef) MapWidgets(values []interface{}) (map[string]interface{}, bool) {
+	slots, ok := d.WidgetLayout(len(values))
+	if !ok {
+		return nil, false
+	}
+	named := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		if slots[i].Input != nil {
+			named[slots[i].Name] = value
+		}
+	}
+	return named, true
+}
+
+// WidgetLayout returns the widget slots describing n positional values,
+// following the same rules as MapWidgets. The slots may outnumber the values
+// when trailing optional widgets are absent.
+func (d *NodeDef) WidgetLayout(n int) ([]WidgetSlot, bool) {
+	full := d.WidgetSlots()
+	var bare []WidgetSlot
+	for _, slot := range full {
+		if slot.Input != nil {
+			bare = append(bare, slot)
+		}
+	}
+
+	for _, slots := range [][]WidgetSlot{full, bare} {
+		if fitsSlots(slots, n) {
+			return slots, true
+		}
+	}
+	return nil, false
+}
+
+// fitsSlots reports whether n values fill the slots, allowing the slots of
+// trailing optional inputs to be absent.
+func fitsSlots(slots []WidgetSlot, n int) bool {
+	if n > len(slots) {
+		return false
+	}
+	if n < len(slots) && slots[n].Input == nil {
+		// Values cannot stop between a widget and its extra value.
+		return false
+	}
+	for _, slot := range slots[n:] {
+		if slot.Input != nil && slot.Input.Required {
+			return false
+		}
+	}
+	return true
+}
+
+type rawNode struct {
+	Input struct {
+		Required json.RawMessage `json:"required"`
+		Optional json.RawMessage `json:"optional"`
+	} `json:"input"`
+	InputOrder   map[string][]string `json:"input_order"`
+	Output       []interface{}       `json:"output"`
+	OutputIsList []bool              `json:"output_is_list"`
+	OutputName   []string            `json:"output_name"`
+	Name         string              `json:"name"`
+	DisplayName  string              `json:"display_name"`
+	Category     string              `json:"category"`
+	PythonModule string              `json:"python_module"`
+	OutputNode   bool                `json:"output_node"`
+	Deprecated   bool                `json:"deprecated"`
+}
+
+func (n rawNode) def(name string) (*NodeDef, error) {
+	def := &NodeDef{
+		Name:         name,
+		DisplayName:  n.DisplayName,
+		Category:     n.Category,
+		PythonModule: n.PythonModule,
+		OutputNode:   n.OutputNode,
+		// Older servers only say so in the display name.
+		Deprecated: n.Deprecated || strings.Contains(n.DisplayName, "(DEPRECATED)"),
+	}
+
+	for _, group := range []struct {
+		key      string
+		data     json.RawMessage
+		required bool
+	}{
+		{"required", n.Input.Required, true},
+		{"optional", n.Input.Optional, false},
+	} {
+		if len(group.data) == 0 || string(group.data) == "null" {
+			continue
+		}
+		names, specs, err := orderedSpecs(group.data)
+		if err != nil {
+			return nil, err
+		}
+		if order := n.InputOrder[group.key]; len(order) == len(names) {
+			names = order
+		}
+		for _, inputName := range names {
+			spec, ok := specs[inputName]
+			if !ok {
+				continue
+			}
+			in, err := parseInput(name, inputName, spec)
+			if err != nil {
+				// Custom nodes occasionally declare inputs the frontend
+				// cannot render either; leave them out.
+				continue
+			}
+			in.Required = group.required
+			def.Inputs = append(def.Inputs, in)
+		}
+	}
+
+	for i, out := range n.Output {
+		output := OutputDef{Type: TypeCombo}
+		if s, ok := out.(string); ok {
+			output.Type = s
+		}
+		output.Name = output.Type
+		if i < len(n.OutputName) && n.OutputName[i] != "" {
+			output.Name = n.OutputName[i]
+		}
+		if i < len(n.OutputIsList) {
+			output.IsList = n.OutputIsList[i]
+		}
+		def.Outputs = append(def.Outputs, output)
+	}
+
+	return def, nil
+}
+
+// orderedSpecs decodes an input group, keeping the declaration order that a
+// plain map would lose.
+func orderedSpecs(data json.RawMessage) ([]string, map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("input group is not an object")
+	}
+
+	var names []string
+	specs := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := tok.(string)
+		var spec json.RawMessage
+		if err := dec.Decode(&spec); err != nil {
+			return nil, nil, err
+		}
+		if _, seen := specs[key]; !seen {
+			names = append(names, key)
+		}
+		specs[key] = spec
+	}
+	return names, specs, nil
+}
+
+func parseInput(nodeType, name string, data json.RawMessage) (InputDef, error) {
+	in := InputDef{Name: name}
+
+	var spec []json.RawMessage
+	if err := json.Unmarshal(data, &spec); err != nil || len(spec) == 0 {
+		return in, fmt.Errorf("input %s: invalid specification", name)
+	}
+
+	var options map[string]interface{}
+	if len(spec) > 1 {
+		_ = json.Unmarshal(spec[1], &options)
+	}
+
+	var typeName string
+	var choices []interface{}
+	if err := json.Unmarshal(spec[0], &typeName); err == nil {
+		in.Type = typeName
+		if typeName == TypeCombo {
+			choices, _ = options["options"].([]interface{})
+		}
+	} else if err := json.Unmarshal(spec[0], &choices); err == nil {
+		in.Type = TypeCombo
+	} else {
+		return in, fmt.Errorf("input %s: invalid type", name)
+	}
+	for _, choice := range choices {
+		in.Choices = append(in.Choices, fmt.Sprint(choice))
+	}
+
+	in.Default = options["default"]
+	in.Min = number(options["min"])
+	in.Max = number(options["max"])
+	in.Step = number(options["step"])
+	in.Multiline, _ = options["multiline"].(bool)
+	in.ForceInput, _ = options["forceInput"].(bool)
+	in.ControlAfterGenerate, _ = options["control_after_generate"].(bool)
+	if in.Type == TypeInt && (name == "seed" || name == "noise_seed") {
+		// Older servers leave the control widget to the frontend, which
+		// adds it to every seed input.
+		in.ControlAfterGenerate = true
+	}
+	for _, kind := range []string{"image", "audio", "video"} {
+		if upload, _ := options[kind+"_upload"].(bool); upload {
+			in.Upload = kind
+		}
+	}
+	if in.Type == TypeCombo {
+		in.Folder = InputFolder(nodeType, name)
+	}
+
+	return in, nil
+}
+
+func number(v interface{}) *float64 {
+	f, ok := v.(float64)
+	if !ok {
+		return nil
+	}
+	return &f
+}
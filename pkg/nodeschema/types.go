@@ -0,0 +1,64 @@
+package nodeschema
+
+// Widget value types as reported by /object_info. Any other input type is a
+// link socket such as MODEL or CONDITIONING.
+const (
+	TypeInt     = "INT"
+	TypeFloat   = "FLOAT"
+	TypeString  = "STRING"
+	TypeBoolean = "BOOLEAN"
+	TypeCombo   = "COMBO"
+)
+
+// NodeDef describes a node class as reported by ComfyUI's /object_info.
+type NodeDef struct {
+	Name         string
+	DisplayName  string
+	Category     string
+	PythonModule string
+	OutputNode   bool
+	Inputs       []InputDef
+	Outputs      []OutputDef
+
+	// Deprecated marks nodes ComfyUI keeps only for old workflows.
+	Deprecated bool
+}
+
+// InputDef describes a single node input. Required inputs come first, each
+// group in the order ComfyUI declares them.
+type InputDef struct {
+	Name     string
+	Type     string
+	Required bool
+	// Choices lists the values a COMBO input accepts. It is empty for
+	// combos whose values depend on the installation, such as model files.
+	Choices              []string
+	Default              interface{}
+	Min                  *float64
+	Max                  *float64
+	Step                 *float64
+	Multiline            bool
+	ForceInput           bool
+	ControlAfterGenerate bool
+	// Upload is the upload widget kind ("image", "audio" or "video") a
+	// file combo carries.
+	Upload string
+	// Folder is the model folder a COMBO input selects from, if any.
+	Folder string
+}
+
+// OutputDef describes a single node output.
+type OutputDef struct {
+	Name   string
+	Type   string
+	IsList bool
+}
+
+// WidgetSlot is one position in a node's serialized widgets_values.
+type WidgetSlot struct {
+	// Name is the input the value belongs to.
+	Name string
+	// Input is the widget input, nil for the extra control and upload
+	// values the frontend serializes after some widgets.
+	Input *InputDef
+}
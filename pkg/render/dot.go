@@ -0,0 +1,90 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"runcomfy/pkg/workflow"
+)
+
+// DOT draws the graph in the Graphviz language, left to right.
+func DOT(w *workflow.Workflow, opts Options) []byte {
+	d := build(w, opts)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotString(orDefault(d.title, "workflow")))
+	b.WriteString("  graph [rankdir=LR, fontname=\"Helvetica\"")
+	if d.title != "" {
+		fmt.Fprintf(&b, ", label=%s, labelloc=t", dotString(d.title))
+	}
+	b.WriteString("];\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\", fontsize=10, penwidth=1.5];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\", fontsize=9];\n")
+
+	var writeGroup func(g *group, indent string)
+	writeGroup = func(g *group, indent string) {
+		if g.empty() {
+			return
+		}
+		fmt.Fprintf(&b, "\n%ssubgraph cluster_%d {\n", indent, g.index)
+		fmt.Fprintf(&b, "%s  label=%s;\n", indent, dotString(g.title))
+		fmt.Fprintf(&b, "%s  style=\"rounded,filled\";\n", indent)
+		fmt.Fprintf(&b, "%s  color=%s;\n", indent, dotString(g.color))
+		fmt.Fprintf(&b, "%s  fillcolor=%s;\n", indent, dotString(translucent(g.color, "22")))
+		for _, child := range g.groups {
+			writeGroup(child, indent+"  ")
+		}
+		for _, n := range g.nodes {
+			writeDOTNode(&b, d, n, indent+"  ")
+		}
+		fmt.Fprintf(&b, "%s}\n", indent)
+	}
+	for _, g := range d.groups {
+		if g.parent == nil {
+			writeGroup(g, "  ")
+		}
+	}
+
+	b.WriteString("\n")
+	for _, n := range d.nodes {
+		if n.group == nil {
+			writeDOTNode(&b, d, n, "  ")
+		}
+	}
+
+	if len(d.edges) > 0 {
+		b.WriteString("\n")
+	}
+	for _, e := range d.edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, color=%s];\n",
+			dotString(e.from.id.String()), dotString(e.to.id.String()), dotString(e.label), dotString(linkColor(e.label)))
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+func writeDOTNode(b *strings.Builder, d *diagram, n *node, indent string) {
+	style := "rounded,filled"
+	if n.inactive {
+		style += ",dashed"
+	}
+	fmt.Fprintf(b, "%s%s [label=%s, style=%s, fillcolor=%s, color=%s",
+		indent, dotString(n.id.String()), dotString(strings.Join(n.lines, "\n")),
+		dotString(style), dotString(d.fill(n)), dotString(statusColors[n.status]))
+	if n.status == StatusMissing {
+		b.WriteString(", penwidth=3")
+	}
+	tooltip := packName(n.pack)
+	if n.status == StatusInstalled || n.status == StatusMissing {
+		tooltip += ", " + string(n.status)
+	}
+	fmt.Fprintf(b, ", tooltip=%s];\n", dotString(tooltip))
+}
+
+// dotString quotes a DOT identifier or label.
+func dotString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"runcomfy/pkg/workflow"
+)
+
+// Mermaid draws the graph as a Mermaid flowchart, left to right, which
+// GitHub and GitLab render in Markdown files and comments.
+func Mermaid(w *workflow.Workflow, opts Options) []byte {
+	d := build(w, opts)
+
+	var b strings.Builder
+	if d.title != "" {
+		fmt.Fprintf(&b, "---\ntitle: %s\n---\n", mermaidText(d.title))
+	}
+	b.WriteString("flowchart LR\n")
+
+	var writeGroup func(g *group, indent string)
+	writeGroup = func(g *group, indent string) {
+		if g.empty() {
+			return
+		}
+		fmt.Fprintf(&b, "%ssubgraph group%d[\"%s\"]\n", indent, g.index, mermaidText(g.title))
+		for _, child := range g.groups {
+			writeGroup(child, indent+"  ")
+		}
+		for _, n := range g.nodes {
+			fmt.Fprintf(&b, "%s  %s\n", indent, mermaidNode(n))
+		}
+		fmt.Fprintf(&b, "%send\n", indent)
+	}
+	for _, g := range d.groups {
+		if g.parent == nil {
+			writeGroup(g, "  ")
+		}
+	}
+	for _, n := range d.nodes {
+		if n.group == nil {
+			fmt.Fprintf(&b, "  %s\n", mermaidNode(n))
+		}
+	}
+
+	for _, e := range d.edges {
+		if e.label == "" {
+			fmt.Fprintf(&b, "  %s --> %s\n", mermaidID(e.from.id), mermaidID(e.to.id))
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", mermaidID(e.from.id), mermaidText(e.label), mermaidID(e.to.id))
+	}
+
+	// Nodes get one class for their pack and one for their status.
+	classes := make(map[string][]string)
+	var classOrder []string
+	addClass := func(class string, n *node) {
+		if _, ok := classes[class]; !ok {
+			classOrder = append(classOrder, class)
+		}
+		classes[class] = append(classes[class], mermaidID(n.id))
+	}
+	packClasses := map[string]string{corePack: "corePack", subgraphPack: "subgraphPack", "": "unknownPack"}
+	for i, pack := range d.packs {
+		packClasses[pack] = fmt.Sprintf("pack%d", i)
+	}
+	for _, n := range d.nodes {
+		addClass(packClasses[n.pack], n)
+		addClass(string(n.status), n)
+		if n.inactive {
+			addClass("inactive", n)
+		}
+	}
+
+	classStyles := map[string]string{
+		"corePack":     "fill:" + coreColor,
+		"subgraphPack": "fill:" + subgraphColor,
+		"unknownPack":  "fill:" + unknownColor,
+		"inactive":     "stroke-dasharray:5 5",
+	}
+	for i, pack := range d.packs {
+		classStyles[fmt.Sprintf("pack%d", i)] = "fill:" + d.colors[pack]
+	}
+	for status, color := range statusColors {
+		style := "stroke:" + color + ",stroke-width:1.5px"
+		if status == StatusMissing {
+			style = "stroke:" + color + ",stroke-width:3px"
+		}
+		classStyles[string(status)] = style
+	}
+
+	for _, class := range classOrder {
+		fmt.Fprintf(&b, "  classDef %s %s\n", class, classStyles[class])
+	}
+	for _, class := range classOrder {
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(classes[class], ","), class)
+	}
+	for i, e := range d.edges {
+		fmt.Fprintf(&b, "  linkStyle %d stroke:%s\n", i, linkColor(e.label))
+	}
+	for _, g := range d.groups {
+		if !g.empty() {
+			fmt.Fprintf(&b, "  style group%d fill:%s,stroke:%s\n", g.index, translucent(g.color, "22"), g.color)
+		}
+	}
+	return []byte(b.String())
+}
+
+func mermaidNode(n *node) string {
+	lines := make([]string, len(n.lines))
+	for i, line := range n.lines {
+		lines[i] = mermaidText(line)
+	}
+	return fmt.Sprintf("%s[\"%s\"]", mermaidID(n.id), strings.Join(lines, "<br/>"))
+}
+
+// mermaidID turns a node id, which may contain colons, into a Mermaid node
+// name.
+func mermaidID(id workflow.NodeID) string {
+	return "n" + strings.NewReplacer(":", "_", "-", "_").Replace(id.String())
+}
+
+// mermaidText escapes text for a quoted Mermaid label, where characters
+// are written as entity codes such as #quot;.
+func mermaidText(s string) string {
+	return strings.NewReplacer(
+		"#", "#35;",
+		`"`, "#quot;",
+		"<", "#lt;",
+		">", "#gt;",
+	).Replace(s)
+}
@@ -0,0 +1,480 @@
+// Package render draws workflow graphs for review outside the ComfyUI
+// editor: as Graphviz DOT, as Mermaid flowcharts, which code hosts render in
+// Markdown, and as standalone SVG images drawn without external tools.
+//
+// Nodes are filled with the color of the custom node pack they come from and
+// outlined by their status in the installation; links are labeled with the
+// type they carry and the editor's groups become clusters. Reroutes are left
+// out and the links through them drawn directly, while subgraphs and group
+// nodes are drawn as the single node the editor shows.
+package render
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"runcomfy/pkg/nodeschema"
+	"runcomfy/pkg/workflow"
+)
+
+// Formats lists the formats graphs can be rendered in.
+var Formats = []string{"dot", "mermaid", "svg"}
+
+// Status tells whether a node's type is available in the installation.
+type Status string
+
+const (
+	// StatusUnknown is used when the workflow was not checked against an
+	// installation.
+	StatusUnknown   Status = "unknown"
+	StatusCore      Status = "core"
+	StatusInstalled Status = "installed"
+	StatusMissing   Status = "missing"
+)
+
+// Options control what the rendered graph shows.
+type Options struct {
+	// Title names the graph, typically after the workflow file.
+	Title string
+	// Registry tells which custom node pack each node type comes from. The
+	// bundled definitions are used when it is nil.
+	Registry *nodeschema.Registry
+	// Checked reports whether the workflow was checked against an
+	// installation. Custom nodes have an unknown status otherwise.
+	Checked bool
+	// Missing lists the node types the analyzer found missing.
+	Missing []string
+}
+
+// Render draws the workflow's graph in the given format.
+func Render(format string, w *workflow.Workflow, opts Options) ([]byte, error) {
+	switch format {
+	case "dot":
+		return DOT(w, opts), nil
+	case "mermaid":
+		return Mermaid(w, opts), nil
+	case "svg":
+		return SVG(w, opts), nil
+	}
+	return nil, fmt.Errorf("unsupported format: %s (expected %s)", format, strings.Join(Formats, ", "))
+}
+
+// Pseudo packs for nodes that do not come from a custom node pack.
+const (
+	corePack     = "core"
+	subgraphPack = "subgraph"
+)
+
+// Editor metrics, in canvas units.
+const (
+	titleHeight = 30
+	slotHeight  = 20
+)
+
+// diagram is the drawable form of a workflow shared by every format.
+type diagram struct {
+	title  string
+	nodes  []*node
+	edges  []edge
+	groups []*group
+	// packs lists the custom node packs in use, sorted, and colors gives
+	// each its fill.
+	packs  []string
+	colors map[string]string
+}
+
+type node struct {
+	id workflow.NodeID
+	// lines are the label, the type when it differs from the label, and
+	// the node id with its pack and status.
+	lines    []string
+	pack     string
+	status   Status
+	inactive bool
+	group    *group
+
+	// The box below the title bar, in editor coordinates.
+	x, y, width, height float64
+	collapsed           bool
+}
+
+type edge struct {
+	from, to         *node
+	fromSlot, toSlot int
+	label            string
+}
+
+type group struct {
+	index  int
+	title  string
+	color  string
+	parent *group
+	nodes  []*node
+	groups []*group
+
+	x, y, width, height float64
+}
+
+// build resolves everything the formats draw: labels, packs, statuses,
+// links through reroutes and group membership. Nodes without a position,
+// as in API prompts, are laid out as ToWorkflow does.
+func build(w *workflow.Workflow, opts Options) *diagram {
+	reg := opts.Registry
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+
+	c := *w
+	c.Nodes = append([]workflow.Node(nil), w.Nodes...)
+	for i := range c.Nodes {
+		if _, _, _, _, ok := c.Nodes[i].Bounds(); !ok {
+			c.Arrange(reg)
+			break
+		}
+	}
+	graph := workflow.NewGraph(&c)
+
+	missing := make(map[string]bool, len(opts.Missing))
+	for _, nodeType := range opts.Missing {
+		missing[nodeType] = true
+	}
+	status := func(nodeType, pack string) Status {
+		switch {
+		case pack == corePack:
+			// Core nodes ship with ComfyUI, whatever the analyzer's list
+			// of built-in nodes says.
+			return StatusCore
+		case missing[nodeType]:
+			return StatusMissing
+		case opts.Checked:
+			return StatusInstalled
+		}
+		return StatusUnknown
+	}
+
+	// Subgraphs and group nodes take the worst status of the nodes they
+	// contain, which expansion gives "<outer id>:<inner id>" ids.
+	inner := make(map[workflow.NodeID]Status)
+	for _, n := range w.Expand().Nodes {
+		outer, _, nested := strings.Cut(n.ID.String(), ":")
+		if !nested {
+			continue
+		}
+		s := status(n.Type, packOf(&n, reg))
+		if current, ok := inner[workflow.NodeID(outer)]; !ok || rank(s) > rank(current) {
+			inner[workflow.NodeID(outer)] = s
+		}
+	}
+
+	d := &diagram{title: opts.Title, colors: make(map[string]string)}
+	nodes := make(map[workflow.NodeID]*node)
+	seen := make(map[string]bool)
+	for _, id := range graph.NodeIDs() {
+		n, _ := graph.Node(id)
+		if workflow.IsPassThrough(n.Type) {
+			continue
+		}
+
+		label, typeName := n.Title, n.Type
+		pack := packOf(n, reg)
+		if sg, ok := w.Subgraph(n.Type); ok {
+			label, typeName, pack = orDefault(label, sg.Name), "subgraph", subgraphPack
+		} else if name, ok := workflow.GroupNodeName(n.Type); ok {
+			label, typeName, pack = orDefault(label, name), "group node", subgraphPack
+		} else if def, ok := reg.Lookup(n.Type); ok {
+			label = orDefault(label, def.DisplayName)
+		}
+		label = strings.Join(strings.Fields(orDefault(label, n.Type)), " ")
+
+		rn := &node{id: id, pack: pack, inactive: !n.IsActive(), lines: []string{label}}
+		rn.status = status(n.Type, pack)
+		if s, ok := inner[id]; ok {
+			rn.status = s
+		}
+		if typeName != label {
+			rn.lines = append(rn.lines, typeName)
+		}
+		info := "node " + id.String()
+		if pack != "" && pack != subgraphPack {
+			info += " · " + pack
+		}
+		if rn.status == StatusMissing {
+			info += " · missing"
+		}
+		rn.lines = append(rn.lines, info)
+
+		rn.x, rn.y, rn.width, rn.height, _ = n.Bounds()
+		rn.collapsed, _ = n.Flags["collapsed"].(bool)
+		if rn.width <= 0 {
+			rn.width = 200
+		}
+
+		nodes[id] = rn
+		d.nodes = append(d.nodes, rn)
+		if pack != "" && pack != corePack && pack != subgraphPack && !seen[pack] {
+			d.packs = append(d.packs, pack)
+			seen[pack] = true
+		}
+	}
+	sort.Strings(d.packs)
+	for i, pack := range d.packs {
+		d.colors[pack] = palette[i%len(palette)]
+	}
+
+	for _, e := range graph.Edges() {
+		to, ok := nodes[e.To]
+		if !ok {
+			continue
+		}
+		origin, ok := source(graph, e)
+		if !ok {
+			continue
+		}
+		from := nodes[origin.From]
+		label := origin.Type
+		if label == "" || label == "*" {
+			label = linkType(graph, reg, origin, e)
+		}
+		d.edges = append(d.edges, edge{from: from, to: to, fromSlot: origin.FromSlot, toSlot: e.ToSlot, label: label})
+	}
+
+	d.groups = groups(c.Groups, d.nodes)
+	return d
+}
+
+// source follows an edge upstream through reroutes to the edge leaving the
+// node that produces the value.
+func source(graph *workflow.Graph, e workflow.Edge) (workflow.Edge, bool) {
+	visited := make(map[workflow.NodeID]bool)
+	for {
+		n, ok := graph.Node(e.From)
+		if !ok || visited[e.From] {
+			return e, false
+		}
+		if !workflow.IsPassThrough(n.Type) {
+			return e, true
+		}
+		visited[e.From] = true
+		incoming := graph.Incoming(e.From)
+		if len(incoming) == 0 {
+			return e, false
+		}
+		upstream := incoming[0]
+		if upstream.Type == "" || upstream.Type == "*" {
+			upstream.Type = e.Type
+		}
+		e = upstream
+	}
+}
+
+// linkType looks up the type of an untyped link, as in API prompts, in the
+// definitions of the nodes it connects.
+func linkType(graph *workflow.Graph, reg *nodeschema.Registry, origin, target workflow.Edge) string {
+	if n, ok := graph.Node(origin.From); ok {
+		if def, ok := reg.Lookup(n.Type); ok && origin.FromSlot < len(def.Outputs) {
+			return def.Outputs[origin.FromSlot].Type
+		}
+	}
+	if n, ok := graph.Node(target.To); ok {
+		if def, ok := reg.Lookup(n.Type); ok {
+			if in, ok := def.Input(target.ToName); ok {
+				return in.Type
+			}
+		}
+	}
+	return ""
+}
+
+// packOf names the custom node pack a node comes from: the directory its
+// module was loaded from according to reg, or the pack the editor recorded
+// in the node's properties. Core and frontend-only nodes give corePack, and
+// nodes of unknown origin an empty string.
+func packOf(n *workflow.Node, reg *nodeschema.Registry) string {
+	if def, ok := reg.Lookup(n.Type); ok && def.PythonModule != "" {
+		module, ok := strings.CutPrefix(def.PythonModule, "custom_nodes.")
+		if !ok {
+			return corePack
+		}
+		pack, _, _ := strings.Cut(module, ".")
+		return pack
+	}
+	if workflow.IsFrontendNode(n.Type) {
+		return corePack
+	}
+	if id, ok := n.Properties["cnr_id"].(string); ok && id != "" {
+		if id == "comfy-core" {
+			return corePack
+		}
+		return id
+	}
+	if id, ok := n.Properties["aux_id"].(string); ok && id != "" {
+		return path.Base(id)
+	}
+	return ""
+}
+
+// rank orders statuses from best to worst.
+func rank(s Status) int {
+	switch s {
+	case StatusCore:
+		return 0
+	case StatusInstalled:
+		return 1
+	case StatusUnknown:
+		return 2
+	}
+	return 3
+}
+
+// groups places every node in the smallest group containing its centre,
+// which is how the editor decides membership, and nests groups the same
+// way.
+func groups(list []workflow.Group, nodes []*node) []*group {
+	var result []*group
+	for i, g := range list {
+		if len(g.Bounding) != 4 {
+			continue
+		}
+		result = append(result, &group{
+			index: i, title: g.Title, color: orDefault(g.Color, defaultGroupColor),
+			x: g.Bounding[0], y: g.Bounding[1], width: g.Bounding[2], height: g.Bounding[3],
+		})
+	}
+
+	smallest := func(x, y, width, height float64, self *group) *group {
+		var best *group
+		for _, g := range result {
+			if g == self || x < g.x || y < g.y || x+width > g.x+g.width || y+height > g.y+g.height {
+				continue
+			}
+			if self != nil && g.width*g.height <= self.width*self.height && g.index > self.index {
+				// Of two groups with the same bounds, the later one is
+				// drawn on top and nests in the earlier.
+				continue
+			}
+			if best == nil || g.width*g.height < best.width*best.height {
+				best = g
+			}
+		}
+		return best
+	}
+	for _, g := range result {
+		if g.parent = smallest(g.x, g.y, g.width, g.height, g); g.parent != nil {
+			g.parent.groups = append(g.parent.groups, g)
+		}
+	}
+	for _, n := range nodes {
+		x, y := n.x+n.width/2, n.y+n.height/2
+		if n.collapsed {
+			y = n.y - titleHeight/2
+		}
+		if n.group = smallest(x, y, 0, 0, nil); n.group != nil {
+			n.group.nodes = append(n.group.nodes, n)
+		}
+	}
+	return result
+}
+
+// empty reports whether a group contains no nodes, even through the groups
+// nested in it. Formats that lay graphs out themselves leave such groups
+// out.
+func (g *group) empty() bool {
+	if len(g.nodes) > 0 {
+		return false
+	}
+	for _, child := range g.groups {
+		if !child.empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// fill is the color of a node's pack.
+func (d *diagram) fill(n *node) string {
+	switch n.pack {
+	case corePack:
+		return coreColor
+	case subgraphPack:
+		return subgraphColor
+	case "":
+		return unknownColor
+	}
+	return d.colors[n.pack]
+}
+
+// packName describes a pack in legends.
+func packName(pack string) string {
+	switch pack {
+	case corePack:
+		return "ComfyUI core"
+	case subgraphPack:
+		return "subgraph or group node"
+	case "":
+		return "unknown pack"
+	}
+	return pack
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// Colors. Packs get pastel fills so labels stay readable; statuses and link
+// types use the editor's colors where it has them.
+const (
+	coreColor         = "#e0e0e0"
+	subgraphColor     = "#cfe2f3"
+	unknownColor      = "#ffffff"
+	defaultGroupColor = "#3f789e"
+	defaultLinkColor  = "#888888"
+)
+
+var palette = []string{
+	"#aec7e8", "#ffbb78", "#98df8a", "#ff9896", "#c5b0d5", "#c49c94",
+	"#f7b6d2", "#dbdb8d", "#9edae5", "#fdd0a2", "#c7e9c0", "#dadaeb",
+}
+
+var statusColors = map[Status]string{
+	StatusUnknown:   "#707070",
+	StatusCore:      "#707070",
+	StatusInstalled: "#2e7d32",
+	StatusMissing:   "#d32f2f",
+}
+
+var linkColors = map[string]string{
+	"CLIP":         "#FFD500",
+	"CLIP_VISION":  "#A8DADC",
+	"CONDITIONING": "#FFA931",
+	"CONTROL_NET":  "#6EE7B7",
+	"GUIDER":       "#66FFFF",
+	"IMAGE":        "#64B5F6",
+	"LATENT":       "#FF9CF9",
+	"MASK":         "#81C784",
+	"MODEL":        "#B39DDB",
+	"NOISE":        "#B0B0B0",
+	"SAMPLER":      "#ECB4B4",
+	"SIGMAS":       "#CDFFCD",
+	"STYLE_MODEL":  "#C2FFAE",
+	"VAE":          "#FF6E6E",
+}
+
+func linkColor(linkType string) string {
+	return orDefault(linkColors[linkType], defaultLinkColor)
+}
+
+// translucent adds an alpha channel to a #rgb or #rrggbb color.
+func translucent(color, alpha string) string {
+	if len(color) == 4 && color[0] == '#' {
+		color = string([]byte{'#', color[1], color[1], color[2], color[2], color[3], color[3]})
+	}
+	if len(color) == 7 && color[0] == '#' {
+		return color + alpha
+	}
+	return color
+}
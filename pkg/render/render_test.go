@@ -0,0 +1,148 @@
+package render
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"runcomfy/pkg/workflow"
+)
+
+func TestRenderFixtures(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		format string
+		opts   Options
+		modify func(w *workflow.Workflow)
+		// want lists lines the output must contain, and absent ids of
+		// nodes that must not be drawn.
+		want   []string
+		absent []string
+	}{
+		{
+			name:   "group node as DOT",
+			file:   "sdxl_group_node_v04.json",
+			format: "dot",
+			opts:   Options{Title: "sdxl_group_node_v04"},
+			want: []string{
+				`digraph "sdxl_group_node_v04" {`,
+				`  graph [rankdir=LR, fontname="Helvetica", label="sdxl_group_node_v04", labelloc=t];`,
+				`  "20" [label="SDXL Loader\ngroup node\nnode 20", style="rounded,filled", fillcolor="#cfe2f3", color="#707070", tooltip="subgraph or group node"];`,
+				`  "3" [label="KSampler\nnode 3 · core", style="rounded,filled", fillcolor="#e0e0e0", color="#707070", tooltip="ComfyUI core"];`,
+				`  "20" -> "3" [label="MODEL", color="#B39DDB"];`,
+				`  "20" -> "6" [label="CLIP", color="#FFD500"];`,
+				`  "20" -> "7" [label="CLIP", color="#FFD500"];`,
+				`  "20" -> "8" [label="VAE", color="#FF6E6E"];`,
+				`  "8" -> "9" [label="IMAGE", color="#64B5F6"];`,
+			},
+			absent: []string{`"20:0"`, `"20:1"`},
+		},
+		{
+			name:   "group node as Mermaid",
+			file:   "sdxl_group_node_v04.json",
+			format: "mermaid",
+			opts:   Options{Title: "sdxl_group_node_v04"},
+			want: []string{
+				"title: sdxl_group_node_v04",
+				"flowchart LR",
+				`  n20["SDXL Loader<br/>group node<br/>node 20"]`,
+				`  n20 -->|"MODEL"| n3`,
+				`  n20 -->|"VAE"| n8`,
+				"  classDef subgraphPack fill:#cfe2f3",
+				"  class n20 subgraphPack",
+				"  class n3,n5,n6,n7,n8,n9,n20 core",
+				"  linkStyle 2 stroke:#B39DDB",
+			},
+			absent: []string{"n20_0", "n20_1"},
+		},
+		{
+			// A group node takes the worst status of the nodes inside.
+			name:   "group node with a missing node",
+			file:   "sdxl_group_node_v04.json",
+			format: "dot",
+			opts:   Options{Checked: true, Missing: []string{"LoraLoaderBlockWeight"}},
+			modify: func(w *workflow.Workflow) {
+				nodes := w.Extra["groupNodes"].(map[string]interface{})["SDXL Loader"].(map[string]interface{})["nodes"].([]interface{})
+				nodes[1].(map[string]interface{})["type"] = "LoraLoaderBlockWeight"
+			},
+			want: []string{
+				`  "20" [label="SDXL Loader\ngroup node\nnode 20 · missing", style="rounded,filled", fillcolor="#cfe2f3", color="#d32f2f", penwidth=3, tooltip="subgraph or group node, missing"];`,
+			},
+		},
+		{
+			name:   "group node with a missing node as Mermaid",
+			file:   "sdxl_group_node_v04.json",
+			format: "mermaid",
+			opts:   Options{Checked: true, Missing: []string{"LoraLoaderBlockWeight"}},
+			modify: func(w *workflow.Workflow) {
+				nodes := w.Extra["groupNodes"].(map[string]interface{})["SDXL Loader"].(map[string]interface{})["nodes"].([]interface{})
+				nodes[1].(map[string]interface{})["type"] = "LoraLoaderBlockWeight"
+			},
+			want: []string{
+				`  n20["SDXL Loader<br/>group node<br/>node 20 · missing"]`,
+				"  classDef missing stroke:#d32f2f,stroke-width:3px",
+				"  class n20 missing",
+			},
+		},
+		{
+			// Reroute 16 is left out and primitive 15 linked to the
+			// checkpoint loader directly.
+			name:   "reroutes",
+			file:   "sdxl_primitive_reroute_v04.json",
+			format: "dot",
+			want: []string{
+				`  "15" -> "4" [label="COMBO", color="#888888"];`,
+				`  "18" -> "3" [label="INT", color="#888888"];`,
+			},
+			absent: []string{`"16"`},
+		},
+		{
+			name:   "bypassed nodes",
+			file:   "sdxl_bypass_v04.json",
+			format: "mermaid",
+			want: []string{
+				`  n10["Load LoRA<br/>LoraLoader<br/>node 10 · core"]`,
+				"  classDef inactive stroke-dasharray:5 5",
+				"  class n10,n12 inactive",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := workflow.ParseWorkflow(filepath.Join("..", "workflow", "testdata", tt.file))
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			if tt.modify != nil {
+				tt.modify(w)
+			}
+			out, err := Render(tt.format, w, tt.opts)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			lines := make(map[string]bool)
+			for _, line := range strings.Split(string(out), "\n") {
+				lines[line] = true
+			}
+			for _, line := range tt.want {
+				if !lines[line] {
+					t.Errorf("output lacks %s\n%s", line, out)
+				}
+			}
+			for _, id := range tt.absent {
+				if strings.Contains(string(out), id) {
+					t.Errorf("output draws %s\n%s", id, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderUnsupportedFormat(t *testing.T) {
+	_, err := Render("png", &workflow.Workflow{}, Options{})
+	if err == nil || !strings.Contains(err.Error(), "unsupported format: png") {
+		t.Errorf("Render = %v, want an unsupported format error", err)
+	}
+}
@@ -0,0 +1,252 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"runcomfy/pkg/workflow"
+)
+
+// SVG metrics, in canvas units.
+const (
+	svgMargin     = 40
+	svgTitleSize  = 20
+	svgLabelSize  = 14
+	svgTextSize   = 12
+	svgLineHeight = 16
+	svgLegendRow  = 22
+	// svgCharWidth approximates the width of a character relative to the
+	// font size, since text cannot be measured without the font.
+	svgCharWidth = 0.6
+)
+
+const svgFont = "Helvetica, Arial, sans-serif"
+
+// SVG draws the graph as a standalone SVG image. Nodes keep the positions
+// and sizes they have in the editor, and links are drawn as curves between
+// their slots; a legend below the graph explains the colors.
+func SVG(w *workflow.Workflow, opts Options) []byte {
+	d := build(w, opts)
+
+	// Boxes include the title bar, which the editor draws above a node's
+	// position.
+	type box struct{ x, y, width, height float64 }
+	boxes := make(map[*node]box, len(d.nodes))
+	for _, n := range d.nodes {
+		if n.collapsed {
+			width := math.Min(n.width, textWidth(n.lines[0], svgLabelSize)+40)
+			boxes[n] = box{n.x, n.y - titleHeight, width, titleHeight}
+			continue
+		}
+		height := math.Max(n.height, float64(len(n.lines)-1)*svgLineHeight+10)
+		boxes[n] = box{n.x, n.y - titleHeight, n.width, height + titleHeight}
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	extend := func(x, y, width, height float64) {
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x+width), math.Max(maxY, y+height)
+	}
+	for _, b := range boxes {
+		extend(b.x, b.y, b.width, b.height)
+	}
+	for _, g := range d.groups {
+		extend(g.x, g.y, g.width, g.height)
+	}
+	if len(boxes) == 0 && len(d.groups) == 0 {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+
+	top := float64(svgMargin)
+	if d.title != "" {
+		top += svgTitleSize + 10
+	}
+	dx, dy := svgMargin-minX, top-minY
+
+	legend := svgLegend(d)
+	legendTop := top + (maxY - minY) + svgMargin
+	legendWidth := 0.0
+	for _, entry := range legend {
+		legendWidth = math.Max(legendWidth, 30+textWidth(entry.text, svgTextSize))
+	}
+	width := math.Max(maxX-minX, legendWidth) + 2*svgMargin
+	height := legendTop + float64(len(legend))*svgLegendRow + svgMargin
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %[1]s %[2]s" font-family="%s">`+"\n",
+		num(width), num(height), svgFont)
+	if d.title != "" {
+		fmt.Fprintf(&b, "<title>%s</title>\n", svgText(d.title))
+	}
+	b.WriteString(`<rect width="100%" height="100%" fill="#ffffff"/>` + "\n")
+	if d.title != "" {
+		fmt.Fprintf(&b, `<text x="%s" y="%s" font-size="%d" font-weight="bold" fill="#222222">%s</text>`+"\n",
+			num(svgMargin), num(svgMargin+svgTitleSize), svgTitleSize, svgText(d.title))
+	}
+
+	fmt.Fprintf(&b, `<g transform="translate(%s %s)">`+"\n", num(dx), num(dy))
+
+	// Larger groups first, so that nested groups are drawn on top.
+	groups := append([]*group(nil), d.groups...)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].width*groups[i].height > groups[j].width*groups[j].height
+	})
+	for _, g := range groups {
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" rx="6" fill="%s" fill-opacity="0.15" stroke="%[5]s"/>`+"\n",
+			num(g.x), num(g.y), num(g.width), num(g.height), svgText(g.color))
+		fmt.Fprintf(&b, `<text x="%s" y="%s" font-size="%d" fill="%s">%s</text>`+"\n",
+			num(g.x+10), num(g.y+24), 20, svgText(g.color), svgText(truncate(g.title, g.width-20, 20)))
+	}
+
+	slot := func(n *node, index int, output bool) (float64, float64) {
+		bx := boxes[n]
+		x := bx.x
+		if output {
+			x += bx.width
+		}
+		if n.collapsed {
+			return x, bx.y + titleHeight/2
+		}
+		y := n.y + (float64(index)+0.7)*slotHeight
+		return x, math.Min(y, bx.y+bx.height-5)
+	}
+	type point struct{ x, y float64 }
+	labels := make([]point, len(d.edges))
+	for i, e := range d.edges {
+		x1, y1 := slot(e.from, e.fromSlot, true)
+		x2, y2 := slot(e.to, e.toSlot, false)
+		bend := math.Max(math.Abs(x2-x1)/2, 50)
+		fmt.Fprintf(&b, `<path d="M%s %s C%s %s %s %s %s %s" fill="none" stroke="%s" stroke-width="2.5"/>`+"\n",
+			num(x1), num(y1), num(x1+bend), num(y1), num(x2-bend), num(y2), num(x2), num(y2), linkColor(e.label))
+		// The curve is symmetric, so its middle is halfway between its ends.
+		labels[i] = point{(x1 + x2) / 2, (y1 + y2) / 2}
+	}
+
+	for _, n := range d.nodes {
+		bx := boxes[n]
+		strokeWidth := "1.5"
+		if n.status == StatusMissing {
+			strokeWidth = "3"
+		}
+		dash := ""
+		if n.inactive {
+			dash = ` stroke-dasharray="6 4"`
+		}
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" rx="8" fill="%s" stroke="%s" stroke-width="%s"%s/>`+"\n",
+			num(bx.x), num(bx.y), num(bx.width), num(bx.height), d.fill(n), statusColors[n.status], strokeWidth, dash)
+		if !n.collapsed {
+			fmt.Fprintf(&b, `<path d="M%s %s H%s" stroke="%s" stroke-width="1"/>`+"\n",
+				num(bx.x), num(n.y), num(bx.x+bx.width), statusColors[n.status])
+		}
+		fmt.Fprintf(&b, `<text x="%s" y="%s" font-size="%d" font-weight="bold" fill="#222222">%s</text>`+"\n",
+			num(bx.x+10), num(bx.y+titleHeight/2+svgLabelSize*0.35), svgLabelSize, svgText(truncate(n.lines[0], bx.width-20, svgLabelSize)))
+		if !n.collapsed {
+			body := n.lines[1:]
+			y := n.y + (bx.y+bx.height-n.y)/2 - float64(len(body)-1)*svgLineHeight/2 + svgTextSize*0.35
+			for i, line := range body {
+				fmt.Fprintf(&b, `<text x="%s" y="%s" font-size="%d" text-anchor="middle" fill="#444444">%s</text>`+"\n",
+					num(bx.x+bx.width/2), num(y+float64(i)*svgLineHeight), svgTextSize, svgText(truncate(line, bx.width-16, svgTextSize)))
+			}
+		}
+	}
+
+	// Link labels go on top, with a halo to keep them readable.
+	for i, e := range d.edges {
+		if e.label == "" {
+			continue
+		}
+		fmt.Fprintf(&b, `<text x="%s" y="%s" font-size="11" text-anchor="middle" fill="#333333" stroke="#ffffff" stroke-width="3" paint-order="stroke">%s</text>`+"\n",
+			num(labels[i].x), num(labels[i].y+4), svgText(e.label))
+	}
+	b.WriteString("</g>\n")
+
+	for i, entry := range legend {
+		y := legendTop + float64(i)*svgLegendRow
+		dash := ""
+		if entry.dashed {
+			dash = ` stroke-dasharray="4 2"`
+		}
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="18" height="14" rx="3" fill="%s" stroke="%s" stroke-width="%s"%s/>`+"\n",
+			num(svgMargin), num(y), entry.fill, entry.stroke, entry.strokeWidth, dash)
+		fmt.Fprintf(&b, `<text x="%s" y="%s" font-size="%d" fill="#222222">%s</text>`+"\n",
+			num(svgMargin+28), num(y+11), svgTextSize, svgText(entry.text))
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+type legendEntry struct {
+	text                      string
+	fill, stroke, strokeWidth string
+	dashed                    bool
+}
+
+// svgLegend explains the pack colors and status outlines in use.
+func svgLegend(d *diagram) []legendEntry {
+	packs := make(map[string]bool)
+	statuses := make(map[Status]bool)
+	inactive := false
+	for _, n := range d.nodes {
+		packs[n.pack] = true
+		statuses[n.status] = true
+		inactive = inactive || n.inactive
+	}
+
+	var entries []legendEntry
+	order := append([]string{corePack}, d.packs...)
+	for _, pack := range append(order, subgraphPack, "") {
+		if packs[pack] {
+			n := &node{pack: pack}
+			entries = append(entries, legendEntry{text: packName(pack), fill: d.fill(n), stroke: statusColors[StatusCore], strokeWidth: "1"})
+		}
+	}
+	for _, status := range []Status{StatusInstalled, StatusMissing} {
+		if statuses[status] {
+			width := "2"
+			if status == StatusMissing {
+				width = "3"
+			}
+			entries = append(entries, legendEntry{text: string(status), fill: unknownColor, stroke: statusColors[status], strokeWidth: width})
+		}
+	}
+	if inactive {
+		entries = append(entries, legendEntry{text: "muted or bypassed", fill: unknownColor, stroke: statusColors[StatusCore], strokeWidth: "1.5", dashed: true})
+	}
+	return entries
+}
+
+// textWidth estimates the width of a line of text.
+func textWidth(s string, size float64) float64 {
+	return float64(len([]rune(s))) * size * svgCharWidth
+}
+
+// truncate shortens text to fit the given width, ending it with an
+// ellipsis.
+func truncate(s string, width, size float64) string {
+	runes := []rune(s)
+	fit := int(width / (size * svgCharWidth))
+	if len(runes) <= fit {
+		return s
+	}
+	if fit < 1 {
+		return ""
+	}
+	return string(runes[:fit-1]) + "…"
+}
+
+func svgText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// num writes a coordinate with at most one decimal.
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
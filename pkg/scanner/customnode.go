@@ -0,0 +1,174 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InspectCustomNode reports where the custom node pack installed in the
+// named custom_nodes directory came from. Whatever cannot be told is left
+// empty; credentials in the repository URL are left out.
+func (c *ComfyUIInstallation) InspectCustomNode(name string) (*CustomNodeInfo, error) {
+	dir := filepath.Join(c.CustomNodes, name)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("custom node %s is not installed", name)
+	}
+
+	info := &CustomNodeInfo{Name: name}
+	if gitDir, ok := findGitDir(dir); ok {
+		info.Commit = gitHead(gitDir)
+		info.Repository = stripCredentials(gitRemoteURL(gitDir))
+	}
+	info.RegistryID, info.Version = pyprojectName(filepath.Join(dir, "pyproject.toml"))
+	return info, nil
+}
+
+// findGitDir returns the git directory of a checkout, following the
+// "gitdir:" file that submodules and worktrees have instead.
+func findGitDir(dir string) (string, bool) {
+	gitPath := filepath.Join(dir, ".git")
+	info, err := os.Stat(gitPath)
+	if err != nil {
+		return "", false
+	}
+	if info.IsDir() {
+		return gitPath, true
+	}
+
+	data, err := os.ReadFile(gitPath)
+	if err != nil {
+		return "", false
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", false
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return target, true
+}
+
+// gitHead returns the commit checked out, resolving the branch HEAD points
+// at through loose and packed refs.
+func gitHead(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	head := strings.TrimSpace(string(data))
+	ref, ok := strings.CutPrefix(head, "ref:")
+	if !ok {
+		return head
+	}
+	ref = strings.TrimSpace(ref)
+
+	// Worktrees keep their refs in the main repository.
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	for _, dir := range []string{gitDir, commonDir} {
+		if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+
+	f, err := os.Open(filepath.Join(commonDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		fields := strings.Fields(lines.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// gitRemoteURL returns the URL of the "origin" remote, or of the first
+// remote when there is no origin.
+func gitRemoteURL(gitDir string) string {
+	f, err := os.Open(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var section, first string
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "url" || !strings.HasPrefix(section, "remote ") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if section == `remote "origin"` {
+			return value
+		}
+		if first == "" {
+			first = value
+		}
+	}
+	return first
+}
+
+func stripCredentials(repository string) string {
+	u, err := url.Parse(repository)
+	if err != nil || u.User == nil || u.Scheme == "" {
+		return repository
+	}
+	u.User = nil
+	return u.String()
+}
+
+// pyprojectName reads the name and version from the [project] table of a
+// pyproject.toml.
+func pyprojectName(path string) (string, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	var section, name, version string
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			continue
+		}
+		if section != "project" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.TrimSpace(key) {
+		case "name":
+			name = value
+		case "version":
+			version = value
+		}
+	}
+	return name, version
+}
@@ -31,6 +31,7 @@ func NewComfyUIInstallation(basePath string) *ComfyUIInstallation {
 		ControlNet:  filepath.Join(basePath, "models", "controlnet"),
 		Upscale:     filepath.Join(basePath, "models", "upscale_models"),
 		Embeddings:  filepath.Join(basePath, "models", "embeddings"),
+		InputPath:   filepath.Join(basePath, "input"),
 	}
 }
 
@@ -51,7 +52,13 @@ func (c *ComfyUIInstallation) ScanInstallation() (*ScanResult, error) {
 		return nil, fmt.Errorf("failed to scan models: %w", err)
 	}
 	result.Models = models
-	result.TotalFiles = len(models)
+	
+	inputs, err := c.scanInputs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan inputs: %w", err)
+	}
+	result.Inputs = inputs
+	result.TotalFiles = len(models) + len(inputs)
 	
 	return result, nil
 }
@@ -77,6 +84,9 @@ func (c *ComfyUIInstallation) scanCustomNodes() ([]string, error) {
 	return nodes, nil
 }
 
+// scanModels lists the model files in the standard model folders and in any
+// other folder under models, such as diffusion_models or clip, including
+// those in subfolders. FileType is the name of the model folder.
 func (c *ComfyUIInstallation) scanModels() ([]FileInfo, error) {
 	var models []FileInfo
 	
@@ -89,6 +99,14 @@ func (c *ComfyUIInstallation) scanModels() ([]FileInfo, error) {
 		"embeddings":    c.Embeddings,
 	}
 	
+	if entries, err := os.ReadDir(c.ModelsPath); err == nil {
+		for _, entry := range entries {
+			if _, known := modelDirs[entry.Name()]; !known && entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				modelDirs[entry.Name()] = filepath.Join(c.ModelsPath, entry.Name())
+			}
+		}
+	}
+	
 	for category, dirPath := range modelDirs {
 		if _, err := os.Stat(dirPath); os.IsNotExist(err) {
 			continue
@@ -122,6 +140,50 @@ func (c *ComfyUIInstallation) scanModels() ([]FileInfo, error) {
 	return models, nil
 }
 
+// scanInputs lists the files in the input directory that workflows load
+// images, masks, audio and video from. Hidden files and folders, such as the
+// editor's thumbnail cache, are skipped.
+func (c *ComfyUIInstallation) scanInputs() ([]FileInfo, error) {
+	var inputs []FileInfo
+	
+	if _, err := os.Stat(c.InputPath); os.IsNotExist(err) {
+		return inputs, nil
+	}
+	
+	err := filepath.Walk(c.InputPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		
+		if strings.HasPrefix(info.Name(), ".") && path != c.InputPath {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		
+		if !info.IsDir() {
+			relPath, _ := filepath.Rel(c.BasePath, path)
+			inputs = append(inputs, FileInfo{
+				Name:     info.Name(),
+				Path:     relPath,
+				Size:     info.Size(),
+				IsDir:    false,
+				ModTime:  info.ModTime(),
+				FileType: "input",
+			})
+		}
+		
+		return nil
+	})
+	
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk input directory: %w", err)
+	}
+	
+	return inputs, nil
+}
+
 func (c *ComfyUIInstallation) HasCustomNode(nodeName string) bool {
 	nodePath := filepath.Join(c.CustomNodes, nodeName)
 	_, err := os.Stat(nodePath)
@@ -148,6 +210,13 @@ func (c *ComfyUIInstallation) HasModel(modelName string) bool {
 	return false
 }
 
+// HasInput reports whether a file exists in the input directory. The name
+// is relative to that directory and may include subfolders.
+func (c *ComfyUIInstallation) HasInput(name string) bool {
+	info, err := os.Stat(filepath.Join(c.InputPath, filepath.FromSlash(name)))
+	return err == nil && !info.IsDir()
+}
+
 func (c *ComfyUIInstallation) GetModelPath(modelName string) (string, bool) {
 	modelDirs := []string{
 		c.CheckPoints,
@@ -177,6 +246,8 @@ func isModelFile(filename string) bool {
 		".pt":          true,
 		".pth":         true,
 		".bin":         true,
+		".gguf":        true,
+		".sft":         true,
 	}
 	return modelExts[ext]
 }
\ No newline at end of file
@@ -12,6 +12,7 @@ type ComfyUIInstallation struct {
 	ControlNet   string
 	Upscale      string
 	Embeddings   string
+	InputPath    string
 }
 
 type FileInfo struct {
@@ -26,6 +27,7 @@ type FileInfo struct {
 type ScanResult struct {
 	CustomNodes []string   `json:"customNodes"`
 	Models      []FileInfo `json:"models"`
+	Inputs      []FileInfo `json:"inputs"`
 	TotalFiles  int        `json:"totalFiles"`
 	ScanTime    time.Time  `json:"scanTime"`
 	BasePath    string     `json:"basePath"`
@@ -35,4 +37,16 @@ type MissingDependencies struct {
 	CustomNodes []string   `json:"missingCustomNodes"`
 	Models      []string   `json:"missingModels"`
 	Summary     string     `json:"summary"`
-}
\ No newline at end of file
+}
+
+// CustomNodeInfo tells where an installed custom node pack came from.
+// Repository and Commit are read from the pack's git checkout; RegistryID
+// and Version from the pyproject.toml of packs published to the Comfy
+// registry.
+type CustomNodeInfo struct {
+	Name       string `json:"name"`
+	Repository string `json:"repository,omitempty"`
+	Commit     string `json:"commit,omitempty"`
+	RegistryID string `json:"registryId,omitempty"`
+	Version    string `json:"version,omitempty"`
+}
@@ -0,0 +1,267 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Format identifies how a workflow was serialized.
+type Format string
+
+const (
+	// FormatUI is the graph exported by the ComfyUI editor ("Save").
+	FormatUI Format = "ui"
+	// FormatAPI is the prompt exported with "Save (API Format)" and accepted
+	// by the /prompt endpoint.
+	FormatAPI Format = "api"
+)
+
+// Prompt is a workflow in API format, keyed by node id.
+type Prompt map[string]PromptNode
+
+type PromptNode struct {
+	ClassType string                 `json:"class_type"`
+	Inputs    map[string]interface{} `json:"inputs"`
+	Meta      *PromptMeta            `json:"_meta,omitempty"`
+}
+
+type PromptMeta struct {
+	Title string `json:"title,omitempty"`
+}
+
+// DetectFormat reports whether data holds a UI workflow or an API prompt.
+func DetectFormat(data []byte) (Format, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return "", fmt.Errorf("failed to parse workflow JSON: %w", err)
+	}
+
+	if _, ok := top["nodes"]; ok {
+		return FormatUI, nil
+	}
+	if isPrompt(top) {
+		return FormatAPI, nil
+	}
+	if inner, ok := top["prompt"]; ok {
+		var prompt map[string]json.RawMessage
+		if json.Unmarshal(inner, &prompt) == nil && isPrompt(prompt) {
+			return FormatAPI, nil
+		}
+	}
+
+	return "", fmt.Errorf("unrecognized workflow format: expected a UI workflow with \"nodes\" or an API prompt with \"class_type\" entries")
+}
+
+func isPrompt(entries map[string]json.RawMessage) bool {
+	if len(entries) == 0 {
+		return false
+	}
+	for _, raw := range entries {
+		var probe struct {
+			ClassType string `json:"class_type"`
+		}
+		if err := json.Unmarshal(raw, &probe); err != nil || probe.ClassType == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ParsePrompt decodes an API-format prompt. Both the bare prompt and the
+// {"prompt": {...}} request body sent to /prompt are accepted.
+func ParsePrompt(data []byte) (Prompt, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt JSON: %w", err)
+	}
+	if !isPrompt(top) {
+		if inner, ok := top["prompt"]; ok {
+			data = inner
+		}
+	}
+
+	var prompt Prompt
+	if err := json.Unmarshal(data, &prompt); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt JSON: %w", err)
+	}
+	return prompt, nil
+}
+
+// MarshalJSON writes the prompt's nodes in node id order, as ComfyUI does,
+// rather than in the lexical key order of a plain map.
+func (p Prompt) MarshalJSON() ([]byte, error) {
+	ids := make([]NodeID, 0, len(p))
+	for id := range p {
+		ids = append(ids, NodeID(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, id := range ids {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(string(id))
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(p[string(id)])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Workflow builds the normalized workflow model from the prompt. Literal
+// inputs become named widget values and [node_id, slot] inputs become links.
+// It fails on links to invalid output slots.
+func (p Prompt) Workflow() (*Workflow, error) {
+	w := &Workflow{Format: FormatAPI}
+
+	ids := make([]NodeID, 0, len(p))
+	for id := range p {
+		ids = append(ids, NodeID(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+
+	index := make(map[NodeID]int, len(ids))
+	for i, id := range ids {
+		pn := p[string(id)]
+		node := Node{
+			ID:           id,
+			Type:         pn.ClassType,
+			Order:        i,
+			NamedWidgets: make(map[string]interface{}),
+		}
+		if pn.Meta != nil {
+			node.Title = pn.Meta.Title
+		}
+		index[id] = len(w.Nodes)
+		w.Nodes = append(w.Nodes, node)
+	}
+
+	for _, id := range ids {
+		pn := p[string(id)]
+		target := &w.Nodes[index[id]]
+
+		names := make([]string, 0, len(pn.Inputs))
+		for name := range pn.Inputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			value := pn.Inputs[name]
+			originID, originSlot, ok, err := promptLink(value)
+			if err != nil {
+				return nil, fmt.Errorf("node %s input %q: %w", id, name, err)
+			}
+			if !ok {
+				target.NamedWidgets[name] = value
+				continue
+			}
+
+			w.LastLinkID++
+			link := Link{
+				ID:         w.LastLinkID,
+				OriginID:   originID,
+				OriginSlot: originSlot,
+				TargetID:   id,
+				TargetSlot: len(target.Inputs),
+			}
+			linkID := link.ID
+			target.Inputs = append(target.Inputs, Input{Name: name, Link: &linkID})
+
+			if i, ok := index[originID]; ok {
+				origin := &w.Nodes[i]
+				for len(origin.Outputs) <= originSlot {
+					origin.Outputs = append(origin.Outputs, Output{SlotIndex: len(origin.Outputs)})
+				}
+				origin.Outputs[originSlot].Links = append(origin.Outputs[originSlot].Links, link.ID)
+			}
+			w.Links = append(w.Links, link)
+		}
+	}
+
+	if len(ids) > 0 {
+		if n, ok := ids[len(ids)-1].Int(); ok {
+			w.LastNodeID = n
+		}
+	}
+
+	return w, nil
+}
+
+// Prompt rebuilds the API prompt of a workflow read from one, the inverse of
+// Prompt.Workflow. UI workflows are converted with ToPrompt instead.
+func (w *Workflow) Prompt() (Prompt, error) {
+	if w.Format != FormatAPI {
+		return nil, fmt.Errorf("workflow is not in API format")
+	}
+	graph := NewGraph(w)
+
+	prompt := make(Prompt, len(w.Nodes))
+	for i := range w.Nodes {
+		node := &w.Nodes[i]
+		inputs := make(map[string]interface{}, len(node.NamedWidgets)+len(node.Inputs))
+		for name, value := range node.NamedWidgets {
+			inputs[name] = value
+		}
+		for _, edge := range graph.Incoming(node.ID) {
+			inputs[edge.ToName] = []interface{}{edge.From.String(), edge.FromSlot}
+		}
+
+		pn := PromptNode{ClassType: node.Type, Inputs: inputs}
+		if node.Title != "" {
+			pn.Meta = &PromptMeta{Title: node.Title}
+		}
+		prompt[node.ID.String()] = pn
+	}
+	return prompt, nil
+}
+
+// maxOutputSlot bounds the output slots links may refer to, far above the
+// outputs of any real node, so that a bad prompt cannot make a workflow
+// allocate an output for every slot up to it.
+const maxOutputSlot = 1024
+
+// promptLink reports whether an API input value is a [node_id, slot] link.
+// A link to a negative slot, or one beyond maxOutputSlot, is reported as a
+// link with an error.
+func promptLink(value interface{}) (NodeID, int, bool, error) {
+	pair, ok := value.([]interface{})
+	if !ok || len(pair) != 2 {
+		return "", 0, false, nil
+	}
+
+	id, ok := pair[0].(string)
+	if !ok {
+		return "", 0, false, nil
+	}
+
+	// Prompts built in memory, such as by ToPrompt, hold int slots.
+	var slot int
+	switch value := pair[1].(type) {
+	case float64:
+		if value != float64(int(value)) {
+			return "", 0, false, nil
+		}
+		slot = int(value)
+	case int:
+		slot = value
+	default:
+		return "", 0, false, nil
+	}
+	if slot < 0 || slot > maxOutputSlot {
+		return NodeID(id), slot, true, fmt.Errorf("link to node %s has invalid output slot %d", id, slot)
+	}
+	return NodeID(id), slot, true, nil
+}
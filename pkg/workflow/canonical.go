@@ -0,0 +1,234 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CanonicalOptions selects the editor-only state Canonicalize removes.
+type CanonicalOptions struct {
+	// StripLayout removes node positions and sizes.
+	StripLayout bool
+	// StripColors removes node and group colors.
+	StripColors bool
+	// StripViewState removes the canvas offset and zoom kept in extra.ds.
+	StripViewState bool
+}
+
+// Canonicalize rewrites workflow or prompt JSON into a stable form suited to
+// version control: object keys are sorted (numerically when they are all
+// node ids), nodes and links are ordered by id, floats are written in their
+// shortest exact form and arrays of scalars are kept on one line. Fields the
+// parser does not know about are preserved, and the result is semantically
+// identical to the input apart from what opts strips. Canonicalize is
+// idempotent.
+func Canonicalize(data []byte, opts CanonicalOptions) ([]byte, error) {
+	format, err := DetectFormat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow JSON: %w", err)
+	}
+
+	if format == FormatUI {
+		canonicalGraph(doc, opts)
+		if defs, ok := doc["definitions"].(map[string]interface{}); ok {
+			if subgraphs, ok := defs["subgraphs"].([]interface{}); ok {
+				for _, sg := range subgraphs {
+					if sg, ok := sg.(map[string]interface{}); ok {
+						canonicalGraph(sg, opts)
+					}
+				}
+			}
+		}
+		if opts.StripViewState {
+			if extra, ok := doc["extra"].(map[string]interface{}); ok {
+				delete(extra, "ds")
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, doc, 0); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// canonicalGraph orders the nodes and links of a workflow or subgraph and
+// strips the requested editor state.
+func canonicalGraph(graph map[string]interface{}, opts CanonicalOptions) {
+	if nodes, ok := graph["nodes"].([]interface{}); ok {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return canonicalID(nodes[i], "id").Less(canonicalID(nodes[j], "id"))
+		})
+		for _, node := range nodes {
+			node, ok := node.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if opts.StripLayout {
+				delete(node, "pos")
+				delete(node, "size")
+			}
+			if opts.StripColors {
+				delete(node, "color")
+				delete(node, "bgcolor")
+			}
+		}
+	}
+
+	if links, ok := graph["links"].([]interface{}); ok {
+		sort.SliceStable(links, func(i, j int) bool {
+			return canonicalID(links[i], "id").Less(canonicalID(links[j], "id"))
+		})
+	}
+
+	if opts.StripColors {
+		if groups, ok := graph["groups"].([]interface{}); ok {
+			for _, group := range groups {
+				if group, ok := group.(map[string]interface{}); ok {
+					delete(group, "color")
+				}
+			}
+		}
+	}
+}
+
+// canonicalID reads the id of a node or link, which is the first element of
+// array-encoded links.
+func canonicalID(v interface{}, key string) NodeID {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return NodeID(fmt.Sprint(v[key]))
+	case []interface{}:
+		if len(v) > 0 {
+			return NodeID(fmt.Sprint(v[0]))
+		}
+	}
+	return ""
+}
+
+func writeCanonical(buf *bytes.Buffer, v interface{}, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i, key := range canonicalKeys(v) {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(indent + "  ")
+			writeString(buf, key)
+			buf.WriteString(": ")
+			if err := writeCanonical(buf, v[key], depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "}")
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		if scalars(v) {
+			buf.WriteByte('[')
+			for i, item := range v {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				if err := writeCanonical(buf, item, depth+1); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(indent + "  ")
+			if err := writeCanonical(buf, item, depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "]")
+	case json.Number:
+		buf.WriteString(canonicalNumber(v))
+	case string:
+		writeString(buf, v)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	default:
+		return fmt.Errorf("unexpected JSON value of type %T", v)
+	}
+	return nil
+}
+
+// canonicalKeys sorts object keys, numerically when every key is a node id.
+func canonicalKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	numeric := true
+	for key := range m {
+		keys = append(keys, key)
+		if _, ok := NodeID(key).Int(); !ok {
+			numeric = false
+		}
+	}
+	if numeric {
+		sort.Slice(keys, func(i, j int) bool { return NodeID(keys[i]).Less(NodeID(keys[j])) })
+	} else {
+		sort.Strings(keys)
+	}
+	return keys
+}
+
+// canonicalNumber writes floats in their shortest exact form. Integer
+// literals are kept as written, since seeds exceed float64 precision.
+func canonicalNumber(n json.Number) string {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		return s
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Encode terminates the value with a newline.
+	buf.Truncate(buf.Len() - 1)
+}
+
+func scalars(items []interface{}) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+
+	"runcomfy/pkg/nodeschema"
+)
+
+// ToPrompt converts a UI workflow into the API prompt the /prompt endpoint
+// accepts, following the editor's own export rules: group nodes and
+// subgraphs are flattened, muted nodes are dropped, bypassed nodes and
+// reroutes are spliced out, and values held by primitive nodes are inlined.
+// Widget values are named using the node definitions in reg; a nil registry
+// means the bundled definitions. Nodes whose widget values cannot be named
+// are reported in the returned error.
+func (w *Workflow) ToPrompt(reg *nodeschema.Registry) (Prompt, error) {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	w = w.Expand()
+	graph := NewGraph(w)
+
+	prompt := make(Prompt)
+	var problems []string
+	for i := range w.Nodes {
+		node := &w.Nodes[i]
+		if node.Mode == ModeNever || node.Mode == ModeBypass || frontendNodes[node.Type] {
+			continue
+		}
+
+		def, _ := reg.Lookup(node.Type)
+		inputs, err := promptInputs(graph, node, def)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("node %s (%s): %v", node.ID, node.Type, err))
+			continue
+		}
+
+		title := node.Title
+		if title == "" && def != nil {
+			title = def.DisplayName
+		}
+		if title == "" {
+			title = node.Type
+		}
+		prompt[node.ID.String()] = PromptNode{
+			ClassType: node.Type,
+			Inputs:    inputs,
+			Meta:      &PromptMeta{Title: title},
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("cannot convert %d node(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return prompt, nil
+}
+
+func promptInputs(g *Graph, node *Node, def *nodeschema.NodeDef) (map[string]interface{}, error) {
+	inputs := make(map[string]interface{})
+
+	var values map[string]interface{}
+	if def != nil {
+		named, ok := node.WidgetsByName(def)
+		if !ok {
+			return nil, fmt.Errorf("%d widget values do not match the %d widgets of its definition", len(node.Widgets), len(def.WidgetSlots()))
+		}
+		values = named
+	} else {
+		named, ok := node.inputWidgetNames()
+		if !ok {
+			return nil, fmt.Errorf("no node definition names its %d widget values; pass an /object_info dump that includes it", len(node.Widgets))
+		}
+		values = named
+	}
+	for name, value := range values {
+		inputs[name] = value
+	}
+
+	for _, edge := range g.Incoming(node.ID) {
+		inputType := node.Inputs[edge.ToSlot].Type
+		if inputType == "" && def != nil {
+			if in, ok := def.Input(edge.ToName); ok {
+				inputType = in.Type
+			}
+		}
+		if value, ok := promptSource(g, edge, inputType); ok {
+			inputs[edge.ToName] = value
+		} else {
+			delete(inputs, edge.ToName)
+		}
+	}
+	return inputs, nil
+}
+
+// inputWidgetNames names widget values for nodes without a definition using
+// the widget inputs that newer editors list for every widget.
+func (n *Node) inputWidgetNames() (map[string]interface{}, bool) {
+	if len(n.NamedWidgets) > 0 {
+		return n.NamedWidgets, true
+	}
+	var names []string
+	for _, input := range n.Inputs {
+		if input.Widget != nil {
+			names = append(names, input.Widget.Name)
+		}
+	}
+	if len(names) != len(n.Widgets) {
+		return nil, false
+	}
+	named := make(map[string]interface{}, len(names))
+	for i, name := range names {
+		named[name] = n.Widgets[i]
+	}
+	return named, true
+}
+
+// promptSource follows a link upstream past reroutes and bypassed nodes. It
+// returns either a ["id", slot] reference or the literal held by a primitive
+// node, and false when the value comes from a muted node or nowhere at all.
+func promptSource(g *Graph, edge Edge, inputType string) (interface{}, bool) {
+	visited := make(map[NodeID]bool)
+	for {
+		node, ok := g.Node(edge.From)
+		if !ok || visited[node.ID] {
+			return nil, false
+		}
+		visited[node.ID] = true
+
+		switch {
+		case node.Type == "PrimitiveNode":
+			return primitiveValue(node)
+		case passThroughNodes[node.Type]:
+			incoming := g.Incoming(node.ID)
+			if len(incoming) == 0 {
+				return nil, false
+			}
+			edge = incoming[0]
+		case node.Mode == ModeBypass:
+			next, ok := bypassInput(g, node, edge.FromSlot, inputType)
+			if !ok {
+				return nil, false
+			}
+			edge = next
+		case node.Mode == ModeNever:
+			return nil, false
+		default:
+			return []interface{}{node.ID.String(), edge.FromSlot}, true
+		}
+	}
+}
+
+// bypassInput picks the input a bypassed node passes through in place of the
+// given output: the input at the same index if its type matches, otherwise
+// the first input of that type. Like the editor, it gives up when that input
+// is not connected.
+func bypassInput(g *Graph, node *Node, slot int, inputType string) (Edge, bool) {
+	candidates := []int{slot}
+	for i := range node.Inputs {
+		candidates = append(candidates, i)
+	}
+	for _, i := range candidates {
+		if i < 0 || i >= len(node.Inputs) {
+			continue
+		}
+		if inputType != "" && node.Inputs[i].Type != inputType {
+			continue
+		}
+		for _, edge := range g.Incoming(node.ID) {
+			if edge.ToSlot == i {
+				return edge, true
+			}
+		}
+		return Edge{}, false
+	}
+	return Edge{}, false
+}
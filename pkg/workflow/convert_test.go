@@ -0,0 +1,59 @@
+package workflow
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestToPromptMatchesExport converts UI workflows and compares the result
+// with the prompt the editor exported for the same graph.
+func TestToPromptMatchesExport(t *testing.T) {
+	tests := []struct {
+		workflow string
+		prompt   string
+	}{
+		{"sdxl_bypass_v04.json", "sdxl_bypass_v04_api.json"},
+		{"sdxl_group_node_v04.json", "sdxl_group_node_v04_api.json"},
+		{"sdxl_inpaint_v04.json", "sdxl_inpaint_api.json"},
+		{"sdxl_primitive_reroute_v04.json", "sdxl_primitive_reroute_v04_api.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.workflow, func(t *testing.T) {
+			w, err := ParseWorkflow(filepath.Join("testdata", tt.workflow))
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			prompt, err := w.ToPrompt(nil)
+			if err != nil {
+				t.Fatalf("ToPrompt: %v", err)
+			}
+			got, err := json.Marshal(prompt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", tt.prompt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalJSON(t, got, want) {
+				t.Errorf("ToPrompt = %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+// equalJSON reports whether a and b decode to the same value.
+func equalJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
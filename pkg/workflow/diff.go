@@ -0,0 +1,308 @@
+package workflow
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"runcomfy/pkg/nodeschema"
+)
+
+// Patch operations, named after their JSON Patch counterparts.
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+// Kinds of change reported by Diff.
+const (
+	ChangeNode       = "node"
+	ChangeType       = "type"
+	ChangeMode       = "mode"
+	ChangeTitle      = "title"
+	ChangeWidget     = "widget"
+	ChangeLink       = "link"
+	ChangeModel      = "model"
+	ChangeCustomNode = "custom-node"
+)
+
+// Change is one semantic difference between two workflows. Path is a JSON
+// Pointer into a logical view of the workflow, such as
+// "/nodes/3/widgets/steps" or "/models/loras~1detail.safetensors".
+type Change struct {
+	Op       string      `json:"op"`
+	Kind     string      `json:"kind"`
+	Path     string      `json:"path"`
+	NodeID   NodeID      `json:"nodeId,omitempty"`
+	NodeType string      `json:"nodeType,omitempty"`
+	From     interface{} `json:"from,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+// Diff compares two workflows by meaning rather than by serialization:
+// positions, sizes, link ids and ordering are ignored, nodes are matched by
+// id after group nodes and subgraphs are expanded, links are compared by the
+// node output feeding each input with reroutes looked through, and the
+// resulting model and custom node pack requirements are compared as sets.
+// Widget values are named using reg; a nil registry means the bundled
+// definitions.
+func Diff(a, b *Workflow, reg *nodeschema.Registry) []Change {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	a, b = a.Expand(), b.Expand()
+	ga, gb := NewGraph(a), NewGraph(b)
+
+	var changes []Change
+	ids := make(map[NodeID]bool)
+	for _, id := range ga.NodeIDs() {
+		ids[id] = true
+	}
+	for _, id := range gb.NodeIDs() {
+		ids[id] = true
+	}
+	sorted := make([]NodeID, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Less(sorted[j]) })
+
+	for _, id := range sorted {
+		na, inA := ga.Node(id)
+		nb, inB := gb.Node(id)
+		if (inA && passThroughNodes[na.Type]) || (inB && passThroughNodes[nb.Type]) {
+			continue
+		}
+		path := "/nodes/" + pointerEscape(id.String())
+		switch {
+		case !inB:
+			changes = append(changes, Change{Op: OpRemove, Kind: ChangeNode, Path: path, NodeID: id, NodeType: na.Type, From: na.Type})
+		case !inA:
+			changes = append(changes, Change{Op: OpAdd, Kind: ChangeNode, Path: path, NodeID: id, NodeType: nb.Type, Value: nb.Type})
+		default:
+			changes = append(changes, diffNode(ga, gb, na, nb, reg)...)
+		}
+	}
+
+	changes = append(changes, diffSets(ChangeModel, "/models/", modelPaths(a, reg), modelPaths(b, reg))...)
+	changes = append(changes, diffSets(ChangeCustomNode, "/customNodes/", customNodePacks(a, reg), customNodePacks(b, reg))...)
+	return changes
+}
+
+func diffNode(ga, gb *Graph, na, nb *Node, reg *nodeschema.Registry) []Change {
+	var changes []Change
+	path := "/nodes/" + pointerEscape(na.ID.String())
+	change := func(kind, subpath string, from, to interface{}) {
+		c := Change{Kind: kind, Path: path + subpath, NodeID: na.ID, NodeType: nb.Type, From: from, Value: to}
+		switch {
+		case from == nil:
+			c.Op = OpAdd
+		case to == nil:
+			c.Op = OpRemove
+		default:
+			c.Op = OpReplace
+		}
+		changes = append(changes, c)
+	}
+
+	if na.Type != nb.Type {
+		change(ChangeType, "/type", na.Type, nb.Type)
+	}
+	if modeName(na.Mode) != modeName(nb.Mode) {
+		change(ChangeMode, "/mode", modeName(na.Mode), modeName(nb.Mode))
+	}
+	if ta, tb := displayTitle(na, reg), displayTitle(nb, reg); ta != tb {
+		change(ChangeTitle, "/title", ta, tb)
+	}
+
+	wa, wb := na.NamedWidgetValues(reg), nb.NamedWidgetValues(reg)
+	for _, name := range unionKeys(wa, wb) {
+		va, okA := wa[name]
+		vb, okB := wb[name]
+		if okA && okB && reflect.DeepEqual(va, vb) {
+			continue
+		}
+		change(ChangeWidget, "/widgets/"+pointerEscape(name), va, vb)
+	}
+
+	la, lb := inputSources(ga, na), inputSources(gb, nb)
+	for _, name := range unionKeys(la, lb) {
+		va, okA := la[name]
+		vb, okB := lb[name]
+		if okA && okB && va == vb {
+			continue
+		}
+		change(ChangeLink, "/inputs/"+pointerEscape(name), va, vb)
+	}
+	return changes
+}
+
+// displayTitle is the title the editor shows, which defaults to the node
+// definition's display name.
+func displayTitle(n *Node, reg *nodeschema.Registry) string {
+	if n.Title != "" {
+		return n.Title
+	}
+	if def, ok := reg.Lookup(n.Type); ok && def.DisplayName != "" {
+		return def.DisplayName
+	}
+	return n.Type
+}
+
+// NamedWidgetValues keys the node's widget values by input name where a
+// definition in reg or the node's widget inputs allow it, and by "#<index>"
+// otherwise.
+func (n *Node) NamedWidgetValues(reg *nodeschema.Registry) map[string]interface{} {
+	if n.Type == "PrimitiveNode" {
+		if value, ok := primitiveValue(n); ok {
+			return map[string]interface{}{"value": value}
+		}
+	}
+	if def, ok := reg.Lookup(n.Type); ok {
+		if named, ok := n.WidgetsByName(def); ok {
+			return named
+		}
+	}
+	if named, ok := n.inputWidgetNames(); ok {
+		return named
+	}
+	named := make(map[string]interface{}, len(n.Widgets))
+	for i, value := range n.Widgets {
+		named[fmt.Sprintf("#%d", i)] = value
+	}
+	return named
+}
+
+// inputSources maps each linked input to the "<node id>:<slot>" output that
+// ultimately feeds it.
+func inputSources(g *Graph, n *Node) map[string]interface{} {
+	sources := make(map[string]interface{})
+	for _, edge := range g.Incoming(n.ID) {
+		source, ok := g.Source(edge)
+		if !ok {
+			continue
+		}
+		slot := edge.FromSlot
+		if source.ID != edge.From {
+			// The edge was followed through reroutes; take the slot
+			// feeding the last of them.
+			slot = sourceSlot(g, edge)
+		}
+		sources[edge.ToName] = fmt.Sprintf("%s:%d", source.ID, slot)
+	}
+	return sources
+}
+
+func sourceSlot(g *Graph, edge Edge) int {
+	visited := make(map[NodeID]bool)
+	for {
+		node, ok := g.Node(edge.From)
+		if !ok || visited[node.ID] || !passThroughNodes[node.Type] {
+			return edge.FromSlot
+		}
+		visited[node.ID] = true
+		incoming := g.Incoming(node.ID)
+		if len(incoming) == 0 {
+			return edge.FromSlot
+		}
+		edge = incoming[0]
+	}
+}
+
+func modeName(mode int) string {
+	switch mode {
+	case ModeNever:
+		return "muted"
+	case ModeBypass:
+		return "bypassed"
+	default:
+		return "active"
+	}
+}
+
+// modelPaths returns the workflow's required models keyed by fileKey, so
+// moving a model between folders ComfyUI searches together, such as "unet"
+// and "diffusion_models", is not a change.
+func modelPaths(w *Workflow, reg *nodeschema.Registry) map[string]bool {
+	paths := make(map[string]bool)
+	for _, dep := range w.ExtractDependenciesUsing(reg) {
+		if dep.Type == "model" && !dep.Optional {
+			paths[fileKey(dep.Path)] = true
+		}
+	}
+	return paths
+}
+
+// customNodePacks returns the packs providing the workflow's active custom
+// nodes, as named by Node.Pack.
+func customNodePacks(w *Workflow, reg *nodeschema.Registry) map[string]bool {
+	packs := make(map[string]bool)
+	for _, node := range w.Nodes {
+		if !node.IsActive() {
+			continue
+		}
+		packs[node.Pack(reg)] = true
+	}
+	return packs
+}
+
+// Pack names the custom node pack the node comes from: the registry id newer
+// editors store in the node properties, else the module named by its
+// definition in reg, else the node type itself. It is "" for nodes that come
+// with ComfyUI.
+func (n *Node) Pack(reg *nodeschema.Registry) string {
+	if builtinNodes[n.Type] || frontendNodes[n.Type] {
+		return ""
+	}
+	for _, key := range []string{"cnr_id", "aux_id"} {
+		if id, ok := n.Properties[key].(string); ok && id != "" {
+			if id == "comfy-core" {
+				return ""
+			}
+			return id
+		}
+	}
+	if def, ok := reg.Lookup(n.Type); ok {
+		if strings.HasPrefix(def.PythonModule, "custom_nodes.") {
+			return strings.TrimPrefix(def.PythonModule, "custom_nodes.")
+		}
+		return ""
+	}
+	return n.Type
+}
+
+func diffSets(kind, prefix string, a, b map[string]bool) []Change {
+	var changes []Change
+	for _, key := range unionKeys(a, b) {
+		if key == "" || a[key] == b[key] {
+			continue
+		}
+		if a[key] {
+			changes = append(changes, Change{Op: OpRemove, Kind: kind, Path: prefix + pointerEscape(key), From: key})
+		} else {
+			changes = append(changes, Change{Op: OpAdd, Kind: kind, Path: prefix + pointerEscape(key), Value: key})
+		}
+	}
+	return changes
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pointerEscape escapes a JSON Pointer reference token.
+func pointerEscape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,47 @@
+package workflow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffModelFolders(t *testing.T) {
+	workflow := func(directory, name string) *Workflow {
+		return &Workflow{
+			Format: FormatUI,
+			Models: []Model{{Name: name, Directory: directory}},
+		}
+	}
+	tests := []struct {
+		name string
+		a, b *Workflow
+		want []Change
+	}{
+		{
+			name: "unet to diffusion_models",
+			a:    workflow("unet", "flux1-dev.safetensors"),
+			b:    workflow("diffusion_models", "flux1-dev.safetensors"),
+		},
+		{
+			name: "clip to text_encoders",
+			a:    workflow("clip", "t5xxl_fp16.safetensors"),
+			b:    workflow("text_encoders", "t5xxl_fp16.safetensors"),
+		},
+		{
+			name: "renamed",
+			a:    workflow("unet", "flux1-dev.safetensors"),
+			b:    workflow("diffusion_models", "flux1-schnell.safetensors"),
+			want: []Change{
+				{Op: OpRemove, Kind: ChangeModel, Path: "/models/diffusion_models~1flux1-dev.safetensors", From: "diffusion_models/flux1-dev.safetensors"},
+				{Op: OpAdd, Kind: ChangeModel, Path: "/models/diffusion_models~1flux1-schnell.safetensors", Value: "diffusion_models/flux1-schnell.safetensors"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.a, tt.b, nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,594 @@
+package workflow
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Embedded holds the workflow metadata ComfyUI writes into generated media.
+// Workflow is the UI graph and Prompt is the API prompt; either may be empty.
+type Embedded struct {
+	Workflow json.RawMessage
+	Prompt   json.RawMessage
+}
+
+// MediaType identifies a container that can carry an embedded workflow.
+type MediaType string
+
+const (
+	MediaPNG  MediaType = "png"
+	MediaWebP MediaType = "webp"
+	MediaMP4  MediaType = "mp4"
+	MediaWebM MediaType = "webm"
+)
+
+var errNoEmbeddedWorkflow = errors.New("no embedded workflow found")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// DetectMedia sniffs the container type from the first bytes of a file.
+func DetectMedia(header []byte) (MediaType, bool) {
+	switch {
+	case bytes.HasPrefix(header, pngSignature):
+		return MediaPNG, true
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return MediaWebP, true
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		return MediaMP4, true
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return MediaWebM, true
+	}
+	return "", false
+}
+
+// ExtractEmbedded reads the workflow and prompt stored in a PNG, WebP, MP4 or
+// WebM file produced by ComfyUI.
+func ExtractEmbedded(filePath string) (*Embedded, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	header := make([]byte, 16)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	media, ok := DetectMedia(header[:n])
+	if !ok {
+		return nil, fmt.Errorf("unsupported media file: %s", filePath)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var embedded *Embedded
+	switch media {
+	case MediaPNG:
+		embedded, err = extractPNG(f)
+	case MediaWebP:
+		embedded, err = extractWebP(f)
+	case MediaMP4:
+		embedded, err = extractMP4(f)
+	case MediaWebM:
+		embedded, err = extractWebM(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s metadata: %w", media, err)
+	}
+	if len(embedded.Workflow) == 0 && len(embedded.Prompt) == 0 {
+		return nil, fmt.Errorf("%s: %w", filePath, errNoEmbeddedWorkflow)
+	}
+	return embedded, nil
+}
+
+// ParseEmbedded extracts and parses the workflow embedded in a media file,
+// preferring the UI workflow and falling back to the API prompt.
+func ParseEmbedded(filePath string) (*Workflow, error) {
+	embedded, err := ExtractEmbedded(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var uiErr error
+	if len(embedded.Workflow) > 0 {
+		w, err := Parse(embedded.Workflow)
+		if err == nil {
+			return w, nil
+		}
+		uiErr = err
+	}
+	if len(embedded.Prompt) > 0 {
+		return Parse(embedded.Prompt)
+	}
+	return nil, fmt.Errorf("failed to parse embedded workflow: %w", uiErr)
+}
+
+func extractPNG(r io.ReadSeeker) (*Embedded, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(int64(len(pngSignature)), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	embedded := &Embedded{}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		chunkType := string(header[4:8])
+
+		switch chunkType {
+		case "tEXt", "iTXt", "zTXt":
+			if err := checkSize(r, length, end, chunkType); err != nil {
+				return nil, err
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, err
+			}
+			keyword, text, err := decodePNGText(chunkType, data)
+			if err != nil {
+				return nil, err
+			}
+			embedded.set(keyword, []byte(text))
+			if _, err := r.Seek(4, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		case "IEND":
+			return embedded, nil
+		default:
+			if _, err := r.Seek(length+4, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return embedded, nil
+}
+
+func decodePNGText(chunkType string, data []byte) (string, string, error) {
+	sep := bytes.IndexByte(data, 0)
+	if sep < 0 {
+		return "", "", fmt.Errorf("malformed %s chunk", chunkType)
+	}
+	keyword := string(data[:sep])
+	rest := data[sep+1:]
+
+	switch chunkType {
+	case "tEXt":
+		return keyword, string(rest), nil
+	case "zTXt":
+		if len(rest) < 1 {
+			return "", "", fmt.Errorf("malformed zTXt chunk")
+		}
+		text, err := inflate(rest[1:])
+		return keyword, text, err
+	default:
+		if len(rest) < 2 {
+			return "", "", fmt.Errorf("malformed iTXt chunk")
+		}
+		compressed := rest[0] == 1
+		rest = rest[2:]
+		for i := 0; i < 2; i++ {
+			sep := bytes.IndexByte(rest, 0)
+			if sep < 0 {
+				return "", "", fmt.Errorf("malformed iTXt chunk")
+			}
+			rest = rest[sep+1:]
+		}
+		if compressed {
+			text, err := inflate(rest)
+			return keyword, text, err
+		}
+		return keyword, string(rest), nil
+	}
+}
+
+func inflate(data []byte) (string, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(zr)
+	return string(out), err
+}
+
+func extractWebP(r io.ReadSeeker) (*Embedded, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	embedded := &Embedded{}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+		chunkType := string(header[0:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		padded := size + size%2
+
+		if chunkType != "EXIF" && chunkType != "XMP " {
+			if _, err := r.Seek(padded, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := checkSize(r, size, end, chunkType); err != nil {
+			return nil, err
+		}
+		data := make([]byte, padded)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		data = data[:size]
+
+		if chunkType == "EXIF" {
+			for _, value := range exifStrings(data) {
+				embedded.setPrefixed(value)
+			}
+		} else {
+			embedded.scanText(string(data))
+		}
+	}
+	return embedded, nil
+}
+
+// checkSize fails when a chunk of size bytes starting at the current
+// position would run past end, so that a corrupt length is not allocated.
+func checkSize(r io.Seeker, size, end int64, chunkType string) error {
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if size > end-pos {
+		return fmt.Errorf("malformed %q chunk", chunkType)
+	}
+	return nil
+}
+
+// exifStrings returns the ASCII and UNDEFINED values stored in IFD0. ComfyUI
+// stores "workflow:<json>" and "prompt:<json>" in the Make/Model tags.
+func exifStrings(data []byte) []string {
+	data = bytes.TrimPrefix(data, []byte("Exif\x00\x00"))
+	if len(data) < 8 {
+		return nil
+	}
+
+	var order binary.ByteOrder
+	switch string(data[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+
+	offset := int(order.Uint32(data[4:8]))
+	if offset+2 > len(data) {
+		return nil
+	}
+	count := int(order.Uint16(data[offset : offset+2]))
+
+	var values []string
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(data) {
+			break
+		}
+		kind := order.Uint16(data[entry+2 : entry+4])
+		n := int(order.Uint32(data[entry+4 : entry+8]))
+		if kind != 2 && kind != 7 {
+			continue
+		}
+
+		var raw []byte
+		if n <= 4 {
+			raw = data[entry+8 : entry+8+n]
+		} else {
+			start := int(order.Uint32(data[entry+8 : entry+12]))
+			if start < 0 || start+n > len(data) {
+				continue
+			}
+			raw = data[start : start+n]
+		}
+		values = append(values, strings.TrimRight(string(raw), "\x00"))
+	}
+	return values
+}
+
+var mp4Containers = map[string]bool{
+	"moov": true,
+	"udta": true,
+	"meta": true,
+	"ilst": true,
+}
+
+func extractMP4(r io.ReadSeeker) (*Embedded, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	embedded := &Embedded{}
+	err = walkMP4(r, 0, end, "", func(value string) {
+		embedded.scanComment(value)
+	})
+	return embedded, err
+}
+
+// walkMP4 visits the boxes between start and end, descending into the
+// metadata containers and reporting the payload of every "data" box.
+func walkMP4(r io.ReadSeeker, start, end int64, parent string, visit func(string)) error {
+	pos := start
+	header := make([]byte, 8)
+	for pos+8 <= end {
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := string(header[4:8])
+		headerLen := int64(8)
+
+		switch size {
+		case 0:
+			size = end - pos
+		case 1:
+			large := make([]byte, 8)
+			if _, err := io.ReadFull(r, large); err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(large))
+			headerLen = 16
+		}
+		if size < headerLen || pos+size > end {
+			return fmt.Errorf("malformed %q box", boxType)
+		}
+
+		body := pos + headerLen
+		switch {
+		case boxType == "meta":
+			// meta is a full box in ISO files but a plain container in
+			// QuickTime files; the version/flags word is zero in the former.
+			flags := make([]byte, 4)
+			if _, err := io.ReadFull(r, flags); err != nil {
+				return err
+			}
+			if binary.BigEndian.Uint32(flags) == 0 {
+				body += 4
+			}
+			if err := walkMP4(r, body, pos+size, boxType, visit); err != nil {
+				return err
+			}
+		case mp4Containers[boxType] || parent == "ilst":
+			if err := walkMP4(r, body, pos+size, boxType, visit); err != nil {
+				return err
+			}
+		case boxType == "data" && size-headerLen > 8:
+			payload := make([]byte, size-headerLen-8)
+			if _, err := r.Seek(body+8, io.SeekStart); err != nil {
+				return err
+			}
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return err
+			}
+			visit(string(payload))
+		}
+
+		pos += size
+	}
+	return nil
+}
+
+const (
+	ebmlSegment   = 0x18538067
+	ebmlTags      = 0x1254C367
+	ebmlTag       = 0x7373
+	ebmlSimpleTag = 0x67C8
+	ebmlTagString = 0x4487
+)
+
+var ebmlContainers = map[uint64]bool{
+	ebmlSegment:   true,
+	ebmlTags:      true,
+	ebmlTag:       true,
+	ebmlSimpleTag: true,
+}
+
+func extractWebM(r io.ReadSeeker) (*Embedded, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	embedded := &Embedded{}
+	err = walkEBML(r, end, func(value string) {
+		embedded.scanComment(value)
+	})
+	return embedded, err
+}
+
+// walkEBML visits the Matroska elements up to end, descending into the
+// segment and tag containers and reporting every TagString value.
+func walkEBML(r io.ReadSeeker, end int64, visit func(string)) error {
+	for {
+		pos, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		if pos >= end {
+			return nil
+		}
+
+		id, _, err := readVint(r, false)
+		if err != nil {
+			return nil
+		}
+		size, unknown, err := readVint(r, true)
+		if err != nil {
+			return nil
+		}
+		body, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+
+		limit := end
+		if !unknown && body+int64(size) < end {
+			limit = body + int64(size)
+		}
+
+		switch {
+		case ebmlContainers[id]:
+			if err := walkEBML(r, limit, visit); err != nil {
+				return err
+			}
+		case unknown:
+			// An unsized element other than a container (e.g. a live
+			// cluster) cannot be skipped, so nothing after it is reachable.
+			return nil
+		case id == ebmlTagString:
+			if size > uint64(limit-body) {
+				return fmt.Errorf("malformed TagString element")
+			}
+			value := make([]byte, size)
+			if _, err := io.ReadFull(r, value); err != nil {
+				return err
+			}
+			visit(string(value))
+		}
+
+		if _, err := r.Seek(limit, io.SeekStart); err != nil {
+			return err
+		}
+	}
+}
+
+// readVint reads an EBML variable-length integer. Element ids keep their
+// length marker; sizes drop it and report the reserved all-ones value as
+// unknown.
+func readVint(r io.Reader, isSize bool) (uint64, bool, error) {
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(r, first); err != nil {
+		return 0, false, err
+	}
+
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, false, fmt.Errorf("invalid EBML integer")
+	}
+
+	rest := make([]byte, length-1)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, false, err
+	}
+
+	value := uint64(first[0])
+	if isSize {
+		value &= uint64(0xFF >> length)
+	}
+	allOnes := value == uint64(0xFF>>length)
+	for _, b := range rest {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+	return value, isSize && allOnes, nil
+}
+
+func (e *Embedded) set(key string, data []byte) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return
+	}
+	switch key {
+	case "workflow":
+		if len(e.Workflow) == 0 {
+			e.Workflow = data
+		}
+	case "prompt":
+		if len(e.Prompt) == 0 {
+			e.Prompt = data
+		}
+	}
+}
+
+// setPrefixed handles values written as "workflow:<json>" or "prompt:<json>".
+func (e *Embedded) setPrefixed(value string) {
+	for _, key := range []string{"workflow", "prompt"} {
+		if strings.HasPrefix(value, key+":") {
+			e.set(key, []byte(strings.TrimPrefix(value, key+":")))
+		}
+	}
+}
+
+// scanText looks for prefixed payloads inside free-form text such as XMP.
+func (e *Embedded) scanText(text string) {
+	for _, key := range []string{"workflow", "prompt"} {
+		idx := strings.Index(text, key+":{")
+		if idx < 0 {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(text[idx+len(key)+1:]))
+		var raw json.RawMessage
+		if dec.Decode(&raw) == nil {
+			e.set(key, raw)
+		}
+	}
+}
+
+// scanComment decodes the JSON comment written by video combine nodes, where
+// "workflow" is an object and "prompt" is usually a JSON-encoded string.
+func (e *Embedded) scanComment(value string) {
+	var comment map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &comment); err != nil {
+		return
+	}
+	for _, key := range []string{"workflow", "prompt"} {
+		raw, ok := comment[key]
+		if !ok {
+			continue
+		}
+		var encoded string
+		if json.Unmarshal(raw, &encoded) == nil {
+			raw = json.RawMessage(encoded)
+		}
+		e.set(key, raw)
+	}
+}
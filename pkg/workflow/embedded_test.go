@@ -0,0 +1,34 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestExtractEmbeddedCorruptSizes feeds media whose metadata claims more
+// bytes than the file holds; reading them must fail rather than allocate the
+// claimed size.
+func TestExtractEmbeddedCorruptSizes(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"png", "\x89PNG\r\n\x1a\n" + "\xff\xff\xff\xf0tEXt" + "prompt\x00{}"},
+		{"webp", "RIFF\x00\x00\x00\x00WEBP" + "EXIF\xf0\xff\xff\xff" + "Exif\x00\x00"},
+		{"webm", "\x1a\x45\xdf\xa3\x80" + "\x18\x53\x80\x67\xff" + "\x44\x87\x01\xff\xff\xff\xff\xff\xff\xfe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "corrupt."+tt.name)
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ExtractEmbedded(path)
+			if err == nil || !strings.Contains(err.Error(), "malformed") {
+				t.Errorf("ExtractEmbedded = %v, want a malformed size error", err)
+			}
+		})
+	}
+}
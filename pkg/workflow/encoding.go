@@ -0,0 +1,335 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// NodeID identifies a node. ComfyUI writes UI node ids as numbers and API
+// prompt keys as strings, so both are accepted and numeric ids are written
+// back as numbers.
+type NodeID string
+
+func (id *NodeID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*id = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*id = NodeID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid node id %s", string(data))
+	}
+	*id = NodeID(n.String())
+	return nil
+}
+
+func (id NodeID) MarshalJSON() ([]byte, error) {
+	if _, ok := id.Int(); ok {
+		return []byte(id), nil
+	}
+	return json.Marshal(string(id))
+}
+
+// Int returns the numeric value of the id when it is a plain integer.
+func (id NodeID) Int() (int, bool) {
+	n, err := strconv.Atoi(string(id))
+	return n, err == nil
+}
+
+func (id NodeID) String() string {
+	return string(id)
+}
+
+// Less orders ids numerically when both are integers and lexically otherwise.
+func (id NodeID) Less(other NodeID) bool {
+	a, aok := id.Int()
+	b, bok := other.Int()
+	switch {
+	case aok && bok:
+		return a < b
+	case aok != bok:
+		return aok
+	default:
+		return id < other
+	}
+}
+
+// Version is the workflow schema version. Older exports store it as a
+// number (0.4, 1) while some tools write a string ("1.0").
+type Version string
+
+func (v *Version) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*v = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*v = Version(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid workflow version %s", string(data))
+	}
+	*v = Version(n.String())
+	return nil
+}
+
+func (v Version) MarshalJSON() ([]byte, error) {
+	if v == "" {
+		return []byte("0.4"), nil
+	}
+	if _, err := strconv.ParseFloat(string(v), 64); err == nil {
+		return []byte(v), nil
+	}
+	return json.Marshal(string(v))
+}
+
+// Major returns the integer part of the schema version.
+func (v Version) Major() int {
+	f, err := strconv.ParseFloat(string(v), 64)
+	if err != nil {
+		return 0
+	}
+	return int(f)
+}
+
+type linkObject struct {
+	ID         int             `json:"id"`
+	OriginID   NodeID          `json:"origin_id"`
+	OriginSlot int             `json:"origin_slot"`
+	TargetID   NodeID          `json:"target_id"`
+	TargetSlot int             `json:"target_slot"`
+	Type       json.RawMessage `json:"type,omitempty"`
+	ParentID   int             `json:"parentId,omitempty"`
+}
+
+func (l *Link) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var obj linkObject
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return fmt.Errorf("invalid link object: %w", err)
+		}
+		*l = Link{
+			ID:         obj.ID,
+			OriginID:   obj.OriginID,
+			OriginSlot: obj.OriginSlot,
+			TargetID:   obj.TargetID,
+			TargetSlot: obj.TargetSlot,
+			Type:       decodeLinkType(obj.Type),
+			ParentID:   obj.ParentID,
+			object:     true,
+		}
+		return nil
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("invalid link: %w", err)
+	}
+	if len(parts) < 5 {
+		return fmt.Errorf("invalid link: expected at least 5 elements, got %d", len(parts))
+	}
+
+	var link Link
+	if err := json.Unmarshal(parts[0], &link.ID); err != nil {
+		return fmt.Errorf("invalid link id: %w", err)
+	}
+	if err := json.Unmarshal(parts[1], &link.OriginID); err != nil {
+		return fmt.Errorf("invalid link %d origin: %w", link.ID, err)
+	}
+	if err := json.Unmarshal(parts[2], &link.OriginSlot); err != nil {
+		return fmt.Errorf("invalid link %d origin slot: %w", link.ID, err)
+	}
+	if err := json.Unmarshal(parts[3], &link.TargetID); err != nil {
+		return fmt.Errorf("invalid link %d target: %w", link.ID, err)
+	}
+	if err := json.Unmarshal(parts[4], &link.TargetSlot); err != nil {
+		return fmt.Errorf("invalid link %d target slot: %w", link.ID, err)
+	}
+	if len(parts) > 5 {
+		link.Type = decodeLinkType(parts[5])
+	}
+
+	*l = link
+	return nil
+}
+
+func (l Link) MarshalJSON() ([]byte, error) {
+	if l.object {
+		return json.Marshal(linkObject{
+			ID:         l.ID,
+			OriginID:   l.OriginID,
+			OriginSlot: l.OriginSlot,
+			TargetID:   l.TargetID,
+			TargetSlot: l.TargetSlot,
+			Type:       encodeLinkType(l.Type),
+			ParentID:   l.ParentID,
+		})
+	}
+	return json.Marshal([]interface{}{l.ID, l.OriginID, l.OriginSlot, l.TargetID, l.TargetSlot, l.Type})
+}
+
+func decodeLinkType(raw json.RawMessage) string {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func encodeLinkType(t string) json.RawMessage {
+	b, _ := json.Marshal(t)
+	return b
+}
+
+type nodeAlias Node
+
+type nodeJSON struct {
+	nodeAlias
+	Widgets json.RawMessage `json:"widgets_values,omitempty"`
+}
+
+func (n *Node) UnmarshalJSON(data []byte) error {
+	var raw nodeJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*n = Node(raw.nodeAlias)
+
+	widgets := bytes.TrimSpace(raw.Widgets)
+	switch {
+	case len(widgets) == 0 || bytes.Equal(widgets, []byte("null")):
+	case widgets[0] == '{':
+		if err := json.Unmarshal(widgets, &n.NamedWidgets); err != nil {
+			return fmt.Errorf("invalid widgets_values for node %s: %w", n.ID, err)
+		}
+	default:
+		if err := json.Unmarshal(widgets, &n.Widgets); err != nil {
+			return fmt.Errorf("invalid widgets_values for node %s: %w", n.ID, err)
+		}
+	}
+	return nil
+}
+
+func (n Node) MarshalJSON() ([]byte, error) {
+	out := nodeJSON{nodeAlias: nodeAlias(n)}
+
+	var widgets interface{}
+	switch {
+	case n.NamedWidgets != nil:
+		widgets = n.NamedWidgets
+	case n.Widgets != nil:
+		widgets = n.Widgets
+	}
+	if widgets != nil {
+		b, err := json.Marshal(widgets)
+		if err != nil {
+			return nil, err
+		}
+		out.Widgets = b
+	}
+	return json.Marshal(out)
+}
+
+type workflowAlias Workflow
+
+type workflowJSON struct {
+	workflowAlias
+	Nodes json.RawMessage   `json:"nodes"`
+	Links []json.RawMessage `json:"links"`
+}
+
+func (w *Workflow) UnmarshalJSON(data []byte) error {
+	var raw workflowJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*w = Workflow(raw.workflowAlias)
+
+	nodes, err := decodeNodes(raw.Nodes)
+	if err != nil {
+		return err
+	}
+	w.Nodes = nodes
+
+	w.Links = nil
+	for _, rawLink := range raw.Links {
+		if bytes.Equal(bytes.TrimSpace(rawLink), []byte("null")) {
+			continue
+		}
+		var link Link
+		if err := json.Unmarshal(rawLink, &link); err != nil {
+			return err
+		}
+		w.Links = append(w.Links, link)
+	}
+
+	return nil
+}
+
+func (w Workflow) MarshalJSON() ([]byte, error) {
+	out := workflowAlias(w)
+	if out.Nodes == nil {
+		out.Nodes = []Node{}
+	}
+	if out.Links == nil {
+		out.Links = []Link{}
+	}
+	return json.Marshal(out)
+}
+
+// decodeNodes accepts the node array written by the ComfyUI frontend as well
+// as an object keyed by node id.
+func decodeNodes(data json.RawMessage) ([]Node, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+
+	if data[0] == '[' {
+		var nodes []Node
+		if err := json.Unmarshal(data, &nodes); err != nil {
+			return nil, fmt.Errorf("invalid nodes: %w", err)
+		}
+		return nodes, nil
+	}
+
+	var byID map[string]Node
+	if err := json.Unmarshal(data, &byID); err != nil {
+		return nil, fmt.Errorf("invalid nodes: %w", err)
+	}
+
+	nodes := make([]Node, 0, len(byID))
+	for key, node := range byID {
+		if node.ID == "" {
+			node.ID = NodeID(key)
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID.Less(nodes[j].ID)
+	})
+	return nodes, nil
+}
@@ -0,0 +1,380 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SubgraphInputNodeID is the origin id of links leaving a subgraph's
+	// input slots.
+	SubgraphInputNodeID NodeID = "-10"
+	// SubgraphOutputNodeID is the target id of links entering a subgraph's
+	// output slots.
+	SubgraphOutputNodeID NodeID = "-20"
+
+	// maxExpandDepth bounds nested group node and subgraph expansion.
+	maxExpandDepth = 16
+)
+
+// GroupNodeDefinition is a group node stored under extra.groupNodes. Its
+// links are [origin_index, origin_slot, target_index, target_slot, ...,
+// type] with indices into Nodes.
+type GroupNodeDefinition struct {
+	Nodes    []Node          `json:"nodes"`
+	Links    [][]interface{} `json:"links"`
+	External [][]interface{} `json:"external,omitempty"`
+}
+
+type slotRef struct {
+	node NodeID
+	slot int
+}
+
+// expansion is the inner graph that replaces a single outer node.
+type expansion struct {
+	nodes   []Node
+	links   []Link
+	inputs  [][]slotRef
+	outputs []slotRef
+}
+
+// GroupNodes returns the group node definitions stored in extra.groupNodes.
+func (w *Workflow) GroupNodes() map[string]GroupNodeDefinition {
+	raw, ok := w.Extra["groupNodes"]
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var defs map[string]GroupNodeDefinition
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil
+	}
+	return defs
+}
+
+// Subgraph returns the subgraph definition with the given id.
+func (w *Workflow) Subgraph(id string) (*Subgraph, bool) {
+	if w.Definitions == nil {
+		return nil, false
+	}
+	for i := range w.Definitions.Subgraphs {
+		if w.Definitions.Subgraphs[i].ID == id {
+			return &w.Definitions.Subgraphs[i], true
+		}
+	}
+	return nil, false
+}
+
+// GroupNodeName returns the definition name of a group node type such as
+// "workflow/My Group" or "workflow>My Group".
+func GroupNodeName(nodeType string) (string, bool) {
+	for _, prefix := range []string{"workflow/", "workflow>"} {
+		if strings.HasPrefix(nodeType, prefix) {
+			return strings.TrimPrefix(nodeType, prefix), true
+		}
+	}
+	return "", false
+}
+
+// Expand returns a copy of the workflow in which group nodes and subgraph
+// instances are replaced by their inner nodes. Inner nodes get ids of the
+// form "<outer id>:<inner id>" and links crossing the boundary are rewired to
+// the inner slots. Nodes whose definition is missing are kept as they are.
+// The receiver is not modified.
+func (w *Workflow) Expand() *Workflow {
+	out := *w
+	out.Nodes = make([]Node, len(w.Nodes))
+	for i, node := range w.Nodes {
+		out.Nodes[i] = cloneNode(node)
+	}
+	out.Links = append([]Link(nil), w.Links...)
+
+	groupNodes := w.GroupNodes()
+	for depth := 0; depth < maxExpandDepth; depth++ {
+		if !out.expandOnce(groupNodes) {
+			break
+		}
+	}
+
+	relinkSlots(&out)
+	return &out
+}
+
+func (w *Workflow) expandOnce(groupNodes map[string]GroupNodeDefinition) bool {
+	nextLinkID := 0
+	for _, link := range w.Links {
+		if link.ID > nextLinkID {
+			nextLinkID = link.ID
+		}
+	}
+	if w.LastLinkID > nextLinkID {
+		nextLinkID = w.LastLinkID
+	}
+	allocate := func() int {
+		nextLinkID++
+		return nextLinkID
+	}
+
+	expansions := make(map[NodeID]*expansion)
+	var nodes []Node
+	for _, node := range w.Nodes {
+		var exp *expansion
+		if name, ok := GroupNodeName(node.Type); ok {
+			if def, ok := groupNodes[name]; ok {
+				exp = expandGroupNode(node, def, allocate)
+			}
+		} else if sg, ok := w.Subgraph(node.Type); ok {
+			exp = expandSubgraph(node, sg, allocate)
+		}
+
+		if exp == nil {
+			nodes = append(nodes, node)
+			continue
+		}
+		expansions[node.ID] = exp
+		nodes = append(nodes, exp.nodes...)
+	}
+	if len(expansions) == 0 {
+		return false
+	}
+
+	var links []Link
+	for _, link := range w.Links {
+		origins := []slotRef{{link.OriginID, link.OriginSlot}}
+		if exp, ok := expansions[link.OriginID]; ok {
+			if link.OriginSlot >= len(exp.outputs) {
+				continue
+			}
+			origins = []slotRef{exp.outputs[link.OriginSlot]}
+			if origins[0].node == "" {
+				continue
+			}
+		}
+
+		targets := []slotRef{{link.TargetID, link.TargetSlot}}
+		if exp, ok := expansions[link.TargetID]; ok {
+			if link.TargetSlot >= len(exp.inputs) {
+				continue
+			}
+			targets = exp.inputs[link.TargetSlot]
+		}
+
+		for i, target := range targets {
+			rewired := link
+			if i > 0 {
+				rewired.ID = allocate()
+			}
+			rewired.OriginID, rewired.OriginSlot = origins[0].node, origins[0].slot
+			rewired.TargetID, rewired.TargetSlot = target.node, target.slot
+			links = append(links, rewired)
+		}
+	}
+	for _, node := range w.Nodes {
+		if exp, ok := expansions[node.ID]; ok {
+			links = append(links, exp.links...)
+		}
+	}
+
+	w.Nodes = nodes
+	w.Links = links
+	w.LastLinkID = nextLinkID
+	return true
+}
+
+func expandGroupNode(outer Node, def GroupNodeDefinition, allocate func() int) *expansion {
+	exp := &expansion{}
+	ids := make([]NodeID, len(def.Nodes))
+	for i, inner := range def.Nodes {
+		inner = cloneNode(inner)
+		ids[i] = NodeID(fmt.Sprintf("%s:%d", outer.ID, i))
+		inner.ID = ids[i]
+		inheritMode(&inner, outer)
+		exp.nodes = append(exp.nodes, inner)
+	}
+	distributeWidgets(exp.nodes, outer.Widgets)
+
+	linkedInputs := make(map[slotRef]bool)
+	linkedOutputs := make(map[slotRef]bool)
+	for _, raw := range def.Links {
+		if len(raw) < 4 {
+			continue
+		}
+		originIdx, ok1 := asInt(raw[0])
+		originSlot, ok2 := asInt(raw[1])
+		targetIdx, ok3 := asInt(raw[2])
+		targetSlot, ok4 := asInt(raw[3])
+		if !ok1 || !ok2 || !ok3 || !ok4 || originIdx >= len(ids) || targetIdx >= len(ids) || originIdx < 0 || targetIdx < 0 {
+			continue
+		}
+		link := Link{
+			ID:         allocate(),
+			OriginID:   ids[originIdx],
+			OriginSlot: originSlot,
+			TargetID:   ids[targetIdx],
+			TargetSlot: targetSlot,
+		}
+		if len(raw) > 5 {
+			link.Type, _ = raw[5].(string)
+		}
+		exp.links = append(exp.links, link)
+		linkedInputs[slotRef{link.TargetID, targetSlot}] = true
+		linkedOutputs[slotRef{link.OriginID, originSlot}] = true
+	}
+
+	external := make(map[slotRef]bool)
+	for _, raw := range def.External {
+		if len(raw) < 2 {
+			continue
+		}
+		idx, ok1 := asInt(raw[0])
+		slot, ok2 := asInt(raw[1])
+		if ok1 && ok2 && idx >= 0 && idx < len(ids) {
+			external[slotRef{ids[idx], slot}] = true
+		}
+	}
+
+	// The outer node exposes, in inner node order, every input not fed
+	// internally and every output that is not only consumed internally.
+	for _, inner := range exp.nodes {
+		for slot := range inner.Inputs {
+			ref := slotRef{inner.ID, slot}
+			if !linkedInputs[ref] {
+				exp.inputs = append(exp.inputs, []slotRef{ref})
+			}
+		}
+		for slot := range inner.Outputs {
+			ref := slotRef{inner.ID, slot}
+			if !linkedOutputs[ref] || external[ref] {
+				exp.outputs = append(exp.outputs, ref)
+			}
+		}
+	}
+
+	return exp
+}
+
+// distributeWidgets copies the outer group node's widget values onto the
+// inner nodes when the counts line up with the inner nodes' own values.
+func distributeWidgets(nodes []Node, values []interface{}) {
+	total := 0
+	for _, node := range nodes {
+		total += len(node.Widgets)
+	}
+	if total == 0 || total != len(values) {
+		return
+	}
+
+	offset := 0
+	for i := range nodes {
+		n := len(nodes[i].Widgets)
+		nodes[i].Widgets = append([]interface{}(nil), values[offset:offset+n]...)
+		offset += n
+	}
+}
+
+func expandSubgraph(outer Node, sg *Subgraph, allocate func() int) *expansion {
+	exp := &expansion{
+		inputs:  make([][]slotRef, len(sg.Inputs)),
+		outputs: make([]slotRef, len(sg.Outputs)),
+	}
+
+	prefix := func(id NodeID) NodeID {
+		return NodeID(fmt.Sprintf("%s:%s", outer.ID, id))
+	}
+	for _, inner := range sg.Nodes {
+		inner = cloneNode(inner)
+		inner.ID = prefix(inner.ID)
+		inheritMode(&inner, outer)
+		exp.nodes = append(exp.nodes, inner)
+	}
+
+	for _, link := range sg.Links {
+		switch {
+		case link.OriginID == SubgraphInputNodeID && link.TargetID == SubgraphOutputNodeID:
+			// A subgraph input wired straight to an output carries no
+			// nodes of its own and is dropped.
+		case link.OriginID == SubgraphInputNodeID:
+			if link.OriginSlot >= 0 && link.OriginSlot < len(exp.inputs) {
+				exp.inputs[link.OriginSlot] = append(exp.inputs[link.OriginSlot], slotRef{prefix(link.TargetID), link.TargetSlot})
+			}
+		case link.TargetID == SubgraphOutputNodeID:
+			if link.TargetSlot >= 0 && link.TargetSlot < len(exp.outputs) {
+				exp.outputs[link.TargetSlot] = slotRef{prefix(link.OriginID), link.OriginSlot}
+			}
+		default:
+			inner := link
+			inner.ID = allocate()
+			inner.OriginID = prefix(link.OriginID)
+			inner.TargetID = prefix(link.TargetID)
+			exp.links = append(exp.links, inner)
+		}
+	}
+
+	return exp
+}
+
+// inheritMode mutes or bypasses inner nodes along with their container.
+func inheritMode(inner *Node, outer Node) {
+	if !outer.IsActive() && inner.IsActive() {
+		inner.Mode = outer.Mode
+	}
+}
+
+// relinkSlots rebuilds the link references held by node inputs and outputs
+// from the workflow's link list.
+func relinkSlots(w *Workflow) {
+	index := make(map[NodeID]int, len(w.Nodes))
+	for i := range w.Nodes {
+		index[w.Nodes[i].ID] = i
+		for j := range w.Nodes[i].Inputs {
+			w.Nodes[i].Inputs[j].Link = nil
+		}
+		for j := range w.Nodes[i].Outputs {
+			w.Nodes[i].Outputs[j].Links = nil
+		}
+	}
+
+	for _, link := range w.Links {
+		if i, ok := index[link.TargetID]; ok && link.TargetSlot >= 0 && link.TargetSlot < len(w.Nodes[i].Inputs) {
+			id := link.ID
+			w.Nodes[i].Inputs[link.TargetSlot].Link = &id
+		}
+		if i, ok := index[link.OriginID]; ok && link.OriginSlot >= 0 && link.OriginSlot < len(w.Nodes[i].Outputs) {
+			w.Nodes[i].Outputs[link.OriginSlot].Links = append(w.Nodes[i].Outputs[link.OriginSlot].Links, link.ID)
+		}
+	}
+}
+
+// cloneNode copies a node deeply enough that its slots and widget values can
+// be changed without affecting the original.
+func cloneNode(n Node) Node {
+	n.Inputs = append([]Input(nil), n.Inputs...)
+	n.Outputs = append([]Output(nil), n.Outputs...)
+	for i := range n.Outputs {
+		n.Outputs[i].Links = append([]int(nil), n.Outputs[i].Links...)
+	}
+	if n.Widgets != nil {
+		n.Widgets = append([]interface{}(nil), n.Widgets...)
+	}
+	if n.NamedWidgets != nil {
+		named := make(map[string]interface{}, len(n.NamedWidgets))
+		for k, v := range n.NamedWidgets {
+			named[k] = v
+		}
+		n.NamedWidgets = named
+	}
+	return n
+}
+
+func asInt(v interface{}) (int, bool) {
+	f, ok := v.(float64)
+	if !ok || f != float64(int(f)) {
+		return 0, false
+	}
+	return int(f), true
+}
@@ -0,0 +1,341 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Edge is a resolved link between an output slot and an input slot.
+type Edge struct {
+	LinkID   int
+	From     NodeID
+	FromSlot int
+	FromName string
+	To       NodeID
+	ToSlot   int
+	ToName   string
+	Type     string
+}
+
+// DanglingLink is a link whose endpoints do not resolve to existing nodes
+// or slots.
+type DanglingLink struct {
+	Link   Link
+	Reason string
+}
+
+// Graph is the typed view of a workflow's nodes and links.
+type Graph struct {
+	workflow *Workflow
+	nodes    map[NodeID]*Node
+	ids      []NodeID
+	incoming map[NodeID][]Edge
+	outgoing map[NodeID][]Edge
+	edges    []Edge
+	dangling []DanglingLink
+}
+
+// CycleError reports the node cycles that prevent a topological order.
+type CycleError struct {
+	Cycles [][]NodeID
+}
+
+func (e *CycleError) Error() string {
+	parts := make([]string, 0, len(e.Cycles))
+	for _, cycle := range e.Cycles {
+		ids := make([]string, 0, len(cycle))
+		for _, id := range cycle {
+			ids = append(ids, id.String())
+		}
+		parts = append(parts, "["+strings.Join(ids, ", ")+"]")
+	}
+	return fmt.Sprintf("workflow contains %d cycle(s): %s", len(e.Cycles), strings.Join(parts, ", "))
+}
+
+// NewGraph resolves the workflow's links into edges. Links that cannot be
+// resolved are kept aside and reported by Dangling.
+func NewGraph(w *Workflow) *Graph {
+	g := &Graph{
+		workflow: w,
+		nodes:    make(map[NodeID]*Node, len(w.Nodes)),
+		incoming: make(map[NodeID][]Edge),
+		outgoing: make(map[NodeID][]Edge),
+	}
+
+	for i := range w.Nodes {
+		node := &w.Nodes[i]
+		if _, exists := g.nodes[node.ID]; !exists {
+			g.ids = append(g.ids, node.ID)
+		}
+		g.nodes[node.ID] = node
+	}
+	sort.Slice(g.ids, func(i, j int) bool { return g.ids[i].Less(g.ids[j]) })
+
+	for _, link := range w.Links {
+		edge, reason := g.resolve(link)
+		if reason != "" {
+			g.dangling = append(g.dangling, DanglingLink{Link: link, Reason: reason})
+			continue
+		}
+		g.edges = append(g.edges, edge)
+		g.incoming[edge.To] = append(g.incoming[edge.To], edge)
+		g.outgoing[edge.From] = append(g.outgoing[edge.From], edge)
+	}
+
+	return g
+}
+
+func (g *Graph) resolve(link Link) (Edge, string) {
+	origin, ok := g.nodes[link.OriginID]
+	if !ok {
+		return Edge{}, fmt.Sprintf("origin node %s does not exist", link.OriginID)
+	}
+	target, ok := g.nodes[link.TargetID]
+	if !ok {
+		return Edge{}, fmt.Sprintf("target node %s does not exist", link.TargetID)
+	}
+	if link.OriginSlot < 0 || (len(origin.Outputs) > 0 && link.OriginSlot >= len(origin.Outputs)) {
+		return Edge{}, fmt.Sprintf("origin node %s has no output slot %d", link.OriginID, link.OriginSlot)
+	}
+	if link.TargetSlot < 0 || link.TargetSlot >= len(target.Inputs) {
+		return Edge{}, fmt.Sprintf("target node %s has no input slot %d", link.TargetID, link.TargetSlot)
+	}
+
+	edge := Edge{
+		LinkID:   link.ID,
+		From:     link.OriginID,
+		FromSlot: link.OriginSlot,
+		To:       link.TargetID,
+		ToSlot:   link.TargetSlot,
+		ToName:   target.Inputs[link.TargetSlot].Name,
+		Type:     link.Type,
+	}
+	if link.OriginSlot < len(origin.Outputs) {
+		output := origin.Outputs[link.OriginSlot]
+		edge.FromName = output.Name
+		if edge.Type == "" {
+			edge.Type = output.Type
+		}
+	}
+	if edge.Type == "" {
+		edge.Type = target.Inputs[link.TargetSlot].Type
+	}
+	return edge, ""
+}
+
+// Workflow returns the workflow the graph was built from.
+func (g *Graph) Workflow() *Workflow {
+	return g.workflow
+}
+
+// Node returns the node with the given id.
+func (g *Graph) Node(id NodeID) (*Node, bool) {
+	node, ok := g.nodes[id]
+	return node, ok
+}
+
+// NodeIDs returns every node id in ascending order.
+func (g *Graph) NodeIDs() []NodeID {
+	return append([]NodeID(nil), g.ids...)
+}
+
+// Edges returns every resolved edge in link order.
+func (g *Graph) Edges() []Edge {
+	return append([]Edge(nil), g.edges...)
+}
+
+// Incoming returns the edges feeding the node's inputs.
+func (g *Graph) Incoming(id NodeID) []Edge {
+	return g.incoming[id]
+}
+
+// Outgoing returns the edges leaving the node's outputs.
+func (g *Graph) Outgoing(id NodeID) []Edge {
+	return g.outgoing[id]
+}
+
+// InputEdge returns the edge connected to the named input of a node.
+func (g *Graph) InputEdge(id NodeID, input string) (Edge, bool) {
+	for _, edge := range g.incoming[id] {
+		if edge.ToName == input {
+			return edge, true
+		}
+	}
+	return Edge{}, false
+}
+
+// Dangling returns the links that could not be resolved.
+func (g *Graph) Dangling() []DanglingLink {
+	return g.dangling
+}
+
+// Upstream returns every node the given nodes transitively depend on,
+// excluding the starting nodes themselves.
+func (g *Graph) Upstream(ids ...NodeID) []NodeID {
+	return g.traverse(ids, func(id NodeID) []NodeID {
+		var next []NodeID
+		for _, edge := range g.incoming[id] {
+			next = append(next, edge.From)
+		}
+		return next
+	})
+}
+
+// Downstream returns every node that transitively consumes the given nodes,
+// excluding the starting nodes themselves.
+func (g *Graph) Downstream(ids ...NodeID) []NodeID {
+	return g.traverse(ids, func(id NodeID) []NodeID {
+		var next []NodeID
+		for _, edge := range g.outgoing[id] {
+			next = append(next, edge.To)
+		}
+		return next
+	})
+}
+
+func (g *Graph) traverse(start []NodeID, neighbours func(NodeID) []NodeID) []NodeID {
+	visited := make(map[NodeID]bool)
+	for _, id := range start {
+		visited[id] = true
+	}
+
+	var result []NodeID
+	queue := append([]NodeID(nil), start...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range neighbours(id) {
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			result = append(result, next)
+			queue = append(queue, next)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Less(result[j]) })
+	return result
+}
+
+// TopologicalOrder returns the node ids ordered so that every node comes
+// after the nodes feeding it. Ties are broken by node id. A *CycleError is
+// returned when the graph is not acyclic.
+func (g *Graph) TopologicalOrder() ([]NodeID, error) {
+	inDegree := make(map[NodeID]int, len(g.ids))
+	for _, id := range g.ids {
+		inDegree[id] = 0
+	}
+	for _, edge := range g.edges {
+		inDegree[edge.To]++
+	}
+
+	var ready []NodeID
+	for _, id := range g.ids {
+		if inDegree[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	order := make([]NodeID, 0, len(g.ids))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return ready[i].Less(ready[j]) })
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+
+		for _, edge := range g.outgoing[id] {
+			inDegree[edge.To]--
+			if inDegree[edge.To] == 0 {
+				ready = append(ready, edge.To)
+			}
+		}
+	}
+
+	if len(order) != len(g.ids) {
+		return order, &CycleError{Cycles: g.Cycles()}
+	}
+	return order, nil
+}
+
+// Depths returns, for every node, the length of the longest path reaching
+// it from a node without inputs. Nodes on cycles, and those they feed, are
+// omitted.
+func (g *Graph) Depths() map[NodeID]int {
+	order, _ := g.TopologicalOrder()
+	depths := make(map[NodeID]int, len(order))
+	for _, id := range order {
+		depth := 0
+		for _, edge := range g.incoming[id] {
+			if d, ok := depths[edge.From]; ok && d+1 > depth {
+				depth = d + 1
+			}
+		}
+		depths[id] = depth
+	}
+	return depths
+}
+
+// Cycles returns each strongly connected group of nodes that forms a cycle,
+// including nodes linked to themselves.
+func (g *Graph) Cycles() [][]NodeID {
+	index := 0
+	indices := make(map[NodeID]int)
+	lowlink := make(map[NodeID]int)
+	onStack := make(map[NodeID]bool)
+	var stack []NodeID
+	var cycles [][]NodeID
+
+	var connect func(id NodeID)
+	connect = func(id NodeID) {
+		indices[id] = index
+		lowlink[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		selfLoop := false
+		for _, edge := range g.outgoing[id] {
+			next := edge.To
+			if next == id {
+				selfLoop = true
+			}
+			if _, seen := indices[next]; !seen {
+				connect(next)
+				if lowlink[next] < lowlink[id] {
+					lowlink[id] = lowlink[next]
+				}
+			} else if onStack[next] && indices[next] < lowlink[id] {
+				lowlink[id] = indices[next]
+			}
+		}
+
+		if lowlink[id] != indices[id] {
+			return
+		}
+		var component []NodeID
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == id {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoop {
+			sort.Slice(component, func(i, j int) bool { return component[i].Less(component[j]) })
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, id := range g.ids {
+		if _, seen := indices[id]; !seen {
+			connect(id)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0].Less(cycles[j][0]) })
+	return cycles
+}
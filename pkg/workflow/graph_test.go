@@ -0,0 +1,223 @@
+package workflow
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGraphFixtures(t *testing.T) {
+	tests := []struct {
+		file     string
+		edges    int
+		order    []NodeID
+		depths   map[NodeID]int
+		dangling []string
+	}{
+		{
+			file:   "sdxl_inpaint_api.json",
+			edges:  13,
+			order:  []NodeID{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"},
+			depths: map[NodeID]int{"1": 0, "4": 0, "5": 0, "2": 1, "3": 1, "6": 1, "7": 2, "8": 3, "9": 4, "10": 5},
+		},
+		{
+			// PrimitiveNode 15 → Reroute 16 → CheckpointLoaderSimple 4.
+			file:  "sdxl_primitive_reroute_v04.json",
+			edges: 15,
+			order: []NodeID{"5", "15", "16", "4", "17", "10", "6", "7", "18", "3", "8", "9"},
+			depths: map[NodeID]int{
+				"5": 0, "15": 0, "17": 0, "18": 0, "16": 1, "4": 2, "10": 3,
+				"6": 4, "7": 4, "3": 5, "8": 6, "9": 7,
+			},
+		},
+		{
+			// Subgraph instance 40 sits between the sampler and VAEDecode.
+			file:  "flux_subgraph_v1.json",
+			edges: 11,
+			order: []NodeID{"10", "11", "6", "12", "26", "27", "33", "31", "8", "40", "9"},
+			depths: map[NodeID]int{
+				"10": 0, "11": 0, "12": 0, "27": 0, "6": 1, "33": 1, "26": 2,
+				"31": 3, "8": 4, "40": 5, "9": 6,
+			},
+		},
+		{
+			file:     "sdxl_invalid_v04.json",
+			edges:    6,
+			order:    []NodeID{"4", "6", "7", "3", "8"},
+			depths:   map[NodeID]int{"4": 0, "6": 1, "7": 1, "3": 2, "8": 3},
+			dangling: []string{"origin node 12 does not exist"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			w, err := ParseWorkflow(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			g := NewGraph(w)
+			if len(g.Edges()) != tt.edges {
+				t.Errorf("edges = %d, want %d", len(g.Edges()), tt.edges)
+			}
+
+			order, err := g.TopologicalOrder()
+			if err != nil {
+				t.Fatalf("TopologicalOrder: %v", err)
+			}
+			if !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("order = %v, want %v", order, tt.order)
+			}
+			position := make(map[NodeID]int, len(order))
+			for i, id := range order {
+				position[id] = i
+			}
+			for _, edge := range g.Edges() {
+				if position[edge.From] >= position[edge.To] {
+					t.Errorf("edge %s → %s goes against the order", edge.From, edge.To)
+				}
+			}
+
+			if depths := g.Depths(); !reflect.DeepEqual(depths, tt.depths) {
+				t.Errorf("depths = %v, want %v", depths, tt.depths)
+			}
+			if cycles := g.Cycles(); len(cycles) != 0 {
+				t.Errorf("cycles = %v, want none", cycles)
+			}
+
+			var dangling []string
+			for _, link := range g.Dangling() {
+				dangling = append(dangling, link.Reason)
+			}
+			if !reflect.DeepEqual(dangling, tt.dangling) {
+				t.Errorf("dangling = %q, want %q", dangling, tt.dangling)
+			}
+		})
+	}
+}
+
+// linkedWorkflow builds a workflow whose nodes have one output and an input
+// per incoming link, connected as listed by [from, to] pairs.
+func linkedWorkflow(ids []NodeID, links [][2]NodeID) *Workflow {
+	w := &Workflow{Format: FormatUI}
+	index := make(map[NodeID]int)
+	for _, id := range ids {
+		index[id] = len(w.Nodes)
+		w.Nodes = append(w.Nodes, Node{ID: id, Type: "Test", Outputs: []Output{{Name: "OUT", Type: "TEST"}}})
+	}
+	for i, l := range links {
+		target := &w.Nodes[index[l[1]]]
+		target.Inputs = append(target.Inputs, Input{Name: "in" + l[0].String(), Type: "TEST"})
+		w.Links = append(w.Links, Link{
+			ID:         i + 1,
+			OriginID:   l[0],
+			TargetID:   l[1],
+			TargetSlot: len(target.Inputs) - 1,
+			Type:       "TEST",
+		})
+	}
+	return w
+}
+
+func TestGraphCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		ids    []NodeID
+		links  [][2]NodeID
+		order  []NodeID
+		cycles [][]NodeID
+		depths map[NodeID]int
+	}{
+		{
+			name:   "acyclic",
+			ids:    []NodeID{"1", "2", "3"},
+			links:  [][2]NodeID{{"1", "2"}, {"2", "3"}, {"1", "3"}},
+			order:  []NodeID{"1", "2", "3"},
+			depths: map[NodeID]int{"1": 0, "2": 1, "3": 2},
+		},
+		{
+			// 1 → 2 ⇄ 3 → 4, with 5 apart.
+			name:   "two nodes",
+			ids:    []NodeID{"1", "2", "3", "4", "5"},
+			links:  [][2]NodeID{{"1", "2"}, {"2", "3"}, {"3", "2"}, {"3", "4"}},
+			order:  []NodeID{"1", "5"},
+			cycles: [][]NodeID{{"2", "3"}},
+			depths: map[NodeID]int{"1": 0, "5": 0},
+		},
+		{
+			name:   "self link",
+			ids:    []NodeID{"1", "2"},
+			links:  [][2]NodeID{{"1", "2"}, {"2", "2"}},
+			order:  []NodeID{"1"},
+			cycles: [][]NodeID{{"2"}},
+			depths: map[NodeID]int{"1": 0},
+		},
+		{
+			// 1 → 2 → 3 → 1 and 10 → 9 → 10, ordered by first node.
+			name:   "separate cycles",
+			ids:    []NodeID{"10", "9", "3", "2", "1", "4"},
+			links:  [][2]NodeID{{"10", "9"}, {"9", "10"}, {"1", "2"}, {"2", "3"}, {"3", "1"}, {"4", "1"}},
+			order:  []NodeID{"4"},
+			cycles: [][]NodeID{{"1", "2", "3"}, {"9", "10"}},
+			depths: map[NodeID]int{"4": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGraph(linkedWorkflow(tt.ids, tt.links))
+			if dangling := g.Dangling(); len(dangling) != 0 {
+				t.Fatalf("dangling = %v", dangling)
+			}
+
+			order, err := g.TopologicalOrder()
+			if !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("order = %v, want %v", order, tt.order)
+			}
+			var cycleErr *CycleError
+			switch {
+			case tt.cycles == nil && err != nil:
+				t.Errorf("TopologicalOrder: %v", err)
+			case tt.cycles != nil && !errors.As(err, &cycleErr):
+				t.Errorf("TopologicalOrder error = %v, want a *CycleError", err)
+			case tt.cycles != nil && !reflect.DeepEqual(cycleErr.Cycles, tt.cycles):
+				t.Errorf("CycleError.Cycles = %v, want %v", cycleErr.Cycles, tt.cycles)
+			}
+
+			if cycles := g.Cycles(); !reflect.DeepEqual(cycles, tt.cycles) {
+				t.Errorf("cycles = %v, want %v", cycles, tt.cycles)
+			}
+			if depths := g.Depths(); !reflect.DeepEqual(depths, tt.depths) {
+				t.Errorf("depths = %v, want %v", depths, tt.depths)
+			}
+		})
+	}
+}
+
+func TestGraphDangling(t *testing.T) {
+	w := linkedWorkflow([]NodeID{"1", "2"}, [][2]NodeID{{"1", "2"}})
+	w.Links = append(w.Links,
+		Link{ID: 10, OriginID: "7", TargetID: "2"},
+		Link{ID: 11, OriginID: "1", TargetID: "8"},
+		Link{ID: 12, OriginID: "1", OriginSlot: 3, TargetID: "2"},
+		Link{ID: 13, OriginID: "1", OriginSlot: -1, TargetID: "2"},
+		Link{ID: 14, OriginID: "1", TargetID: "2", TargetSlot: 1},
+	)
+
+	g := NewGraph(w)
+	want := map[int]string{
+		10: "origin node 7 does not exist",
+		11: "target node 8 does not exist",
+		12: "origin node 1 has no output slot 3",
+		13: "origin node 1 has no output slot -1",
+		14: "target node 2 has no input slot 1",
+	}
+	got := make(map[int]string)
+	for _, link := range g.Dangling() {
+		got[link.Link.ID] = link.Reason
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dangling = %v, want %v", got, want)
+	}
+	if len(g.Edges()) != 1 || len(g.Incoming("2")) != 1 || len(g.Outgoing("1")) != 1 {
+		t.Errorf("edges = %v, want only the valid link", g.Edges())
+	}
+}
@@ -0,0 +1,312 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+
+	"runcomfy/pkg/nodeschema"
+)
+
+// Layout metrics, in canvas units, approximating the editor's own node
+// rendering closely enough for generated workflows to look tidy.
+const (
+	layoutMargin          = 50
+	layoutColumnGap       = 100
+	layoutRowGap          = 50
+	layoutNodeWidth       = 315
+	layoutWideNodeWidth   = 400
+	layoutTitleHeight     = 30
+	layoutSlotHeight      = 20
+	layoutWidgetHeight    = 24
+	layoutMultilineHeight = 100
+)
+
+// ToWorkflow rebuilds an editable UI workflow from the prompt. Unlike
+// Workflow, which only normalizes the prompt for analysis, it uses the node
+// definitions in reg to restore every input and output slot, typed links and
+// positional widgets_values, and places nodes left to right by topological
+// depth. Nodes missing from the registry keep their literal inputs as named
+// widget values. Non-numeric node ids, such as those of flattened group
+// nodes, are renumbered. A nil registry means the bundled definitions.
+func (p Prompt) ToWorkflow(reg *nodeschema.Registry) (*Workflow, error) {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+
+	keys := make([]NodeID, 0, len(p))
+	for key := range p {
+		keys = append(keys, NodeID(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Less(keys[j]) })
+
+	ids := make(map[NodeID]NodeID, len(keys))
+	lastID := 0
+	for _, key := range keys {
+		if n, ok := key.Int(); ok && n > lastID {
+			lastID = n
+		}
+	}
+	for _, key := range keys {
+		if _, ok := key.Int(); ok {
+			ids[key] = key
+			continue
+		}
+		lastID++
+		ids[key] = NodeID(fmt.Sprint(lastID))
+	}
+
+	w := &Workflow{
+		LastNodeID: lastID,
+		Version:    "0.4",
+		Config:     Config{},
+		Extra:      map[string]interface{}{},
+		Format:     FormatUI,
+	}
+	index := make(map[NodeID]int, len(keys))
+	defs := make([]*nodeschema.NodeDef, len(keys))
+	for i, key := range keys {
+		pn := p[string(key)]
+		def, _ := reg.Lookup(pn.ClassType)
+		defs[i] = def
+		index[ids[key]] = i
+		w.Nodes = append(w.Nodes, uiNode(ids[key], pn, def))
+	}
+
+	// Links are created in node and input order so their ids read naturally.
+	for i, key := range keys {
+		pn := p[string(key)]
+		target := &w.Nodes[i]
+		for slot := range target.Inputs {
+			input := &target.Inputs[slot]
+			originKey, originSlot, ok, err := promptLink(pn.Inputs[input.Name])
+			if err != nil {
+				return nil, fmt.Errorf("node %s input %q: %w", key, input.Name, err)
+			}
+			if !ok {
+				continue
+			}
+			originID, ok := ids[originKey]
+			if !ok {
+				return nil, fmt.Errorf("node %s input %q links to missing node %s", key, input.Name, originKey)
+			}
+			origin := &w.Nodes[index[originID]]
+			if defs[index[originID]] != nil && originSlot >= len(origin.Outputs) {
+				return nil, fmt.Errorf("node %s input %q links to missing output %d of node %s", key, input.Name, originSlot, originKey)
+			}
+			for len(origin.Outputs) <= originSlot {
+				// Outputs of unknown nodes are only known through the
+				// links that use them.
+				outputType := input.Type
+				origin.Outputs = append(origin.Outputs, Output{Name: outputType, Type: outputType, SlotIndex: len(origin.Outputs)})
+			}
+
+			linkType := origin.Outputs[originSlot].Type
+			if linkType == "" || linkType == "*" {
+				linkType = input.Type
+			}
+			w.LastLinkID++
+			linkID := w.LastLinkID
+			w.Links = append(w.Links, Link{
+				ID:         linkID,
+				OriginID:   originID,
+				OriginSlot: originSlot,
+				TargetID:   target.ID,
+				TargetSlot: slot,
+				Type:       linkType,
+			})
+			input.Link = &linkID
+			origin.Outputs[originSlot].Links = append(origin.Outputs[originSlot].Links, linkID)
+		}
+	}
+
+	layout(w, defs)
+	return w, nil
+}
+
+// uiNode builds the editor node for a prompt entry, without links.
+func uiNode(id NodeID, pn PromptNode, def *nodeschema.NodeDef) Node {
+	node := Node{
+		ID:         id,
+		Type:       pn.ClassType,
+		Flags:      map[string]interface{}{},
+		Properties: map[string]interface{}{"Node name for S&R": pn.ClassType},
+	}
+	if pn.Meta != nil && pn.Meta.Title != "" && (def == nil || pn.Meta.Title != def.DisplayName) {
+		node.Title = pn.Meta.Title
+	}
+
+	if def == nil {
+		names := make([]string, 0, len(pn.Inputs))
+		for name := range pn.Inputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, _, ok, _ := promptLink(pn.Inputs[name]); ok {
+				node.Inputs = append(node.Inputs, Input{Name: name, Type: "*"})
+				continue
+			}
+			if node.NamedWidgets == nil {
+				node.NamedWidgets = make(map[string]interface{})
+			}
+			node.NamedWidgets[name] = pn.Inputs[name]
+		}
+		return node
+	}
+
+	for i := range def.Inputs {
+		in := &def.Inputs[i]
+		value, present := pn.Inputs[in.Name]
+		_, _, linked, _ := promptLink(value)
+		switch {
+		case !in.IsWidget():
+			node.Inputs = append(node.Inputs, Input{Name: in.Name, Type: in.Type})
+		case present && linked:
+			node.Inputs = append(node.Inputs, Input{Name: in.Name, Type: in.Type, Widget: &InputWidget{Name: in.Name}})
+		}
+	}
+
+	node.Widgets = []interface{}{}
+	var last *nodeschema.InputDef
+	for _, slot := range def.WidgetSlots() {
+		switch {
+		case slot.Input != nil:
+			last = slot.Input
+			value, present := pn.Inputs[slot.Name]
+			if _, _, linked, _ := promptLink(value); !present || linked {
+				value = defaultValue(slot.Input)
+			}
+			node.Widgets = append(node.Widgets, value)
+		case last != nil && last.Upload != "":
+			node.Widgets = append(node.Widgets, last.Upload)
+		default:
+			node.Widgets = append(node.Widgets, "fixed")
+		}
+	}
+
+	for i, out := range def.Outputs {
+		node.Outputs = append(node.Outputs, Output{Name: out.Name, Type: out.Type, Links: []int{}, SlotIndex: i})
+	}
+	return node
+}
+
+// defaultValue is the value the editor gives a fresh widget.
+func defaultValue(in *nodeschema.InputDef) interface{} {
+	if in.Default != nil {
+		return in.Default
+	}
+	switch in.Type {
+	case nodeschema.TypeInt, nodeschema.TypeFloat:
+		if in.Min != nil && *in.Min > 0 {
+			return *in.Min
+		}
+		return 0
+	case nodeschema.TypeBoolean:
+		return false
+	case nodeschema.TypeCombo:
+		if len(in.Choices) > 0 {
+			return in.Choices[0]
+		}
+	}
+	return ""
+}
+
+// Arrange places the nodes left to right by topological depth, as
+// ToWorkflow does, sized after their definitions in reg. It is meant for
+// workflows whose nodes have no position, such as API prompts. A nil
+// registry means the bundled definitions.
+func (w *Workflow) Arrange(reg *nodeschema.Registry) {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	defs := make([]*nodeschema.NodeDef, len(w.Nodes))
+	for i := range w.Nodes {
+		defs[i], _ = reg.Lookup(w.Nodes[i].Type)
+	}
+	layout(w, defs)
+}
+
+// Bounds returns the node's position and size on the canvas. The position
+// is the top left corner below the title bar. ok is false when the node has
+// no position; a missing size is reported as zero.
+func (n *Node) Bounds() (x, y, width, height float64, ok bool) {
+	pos, ok := vector(n.Pos)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	size, _ := vector(n.Size)
+	return pos[0], pos[1], size[0], size[1], true
+}
+
+// layout places nodes in columns by topological depth, stacking each column
+// top to bottom in execution order, and sets each node's size and order.
+func layout(w *Workflow, defs []*nodeschema.NodeDef) {
+	graph := NewGraph(w)
+	order, _ := graph.TopologicalOrder()
+	depths := graph.Depths()
+
+	index := make(map[NodeID]int, len(w.Nodes))
+	for i := range w.Nodes {
+		index[w.Nodes[i].ID] = i
+	}
+	// Nodes on cycles have no depth; append them to the first column.
+	seen := make(map[NodeID]bool, len(order))
+	for _, id := range order {
+		seen[id] = true
+	}
+	for _, id := range graph.NodeIDs() {
+		if !seen[id] {
+			order = append(order, id)
+		}
+	}
+
+	var columns [][]int
+	for position, id := range order {
+		i := index[id]
+		w.Nodes[i].Order = position
+		depth := depths[id]
+		for len(columns) <= depth {
+			columns = append(columns, nil)
+		}
+		columns[depth] = append(columns[depth], i)
+	}
+
+	x := float64(layoutMargin)
+	for _, column := range columns {
+		y := float64(layoutMargin)
+		columnWidth := 0.0
+		for _, i := range column {
+			width, height := nodeSize(&w.Nodes[i], defs[i])
+			w.Nodes[i].Pos = []float64{x, y}
+			w.Nodes[i].Size = []float64{width, height}
+			y += height + layoutRowGap
+			if width > columnWidth {
+				columnWidth = width
+			}
+		}
+		x += columnWidth + layoutColumnGap
+	}
+}
+
+func nodeSize(node *Node, def *nodeschema.NodeDef) (float64, float64) {
+	width := float64(layoutNodeWidth)
+	slots := len(node.Inputs)
+	if len(node.Outputs) > slots {
+		slots = len(node.Outputs)
+	}
+	height := float64(layoutTitleHeight + slots*layoutSlotHeight)
+
+	if def == nil {
+		height += float64(len(node.NamedWidgets) * layoutWidgetHeight)
+		return width, height
+	}
+	for _, slot := range def.WidgetSlots() {
+		if slot.Input != nil && slot.Input.Multiline {
+			width = layoutWideNodeWidth
+			height += layoutMultilineHeight
+			continue
+		}
+		height += layoutWidgetHeight
+	}
+	return width, height
+}
@@ -0,0 +1,126 @@
+package workflow
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestToWorkflowRoundTrip rebuilds a UI workflow from every committed API
+// prompt, parses the written file again and converts it back to a prompt.
+func TestToWorkflowRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*_api.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no API prompts in testdata")
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			prompt, err := ParsePrompt(data)
+			if err != nil {
+				t.Fatalf("ParsePrompt: %v", err)
+			}
+			built, err := prompt.ToWorkflow(nil)
+			if err != nil {
+				t.Fatalf("ToWorkflow: %v", err)
+			}
+			out, err := json.MarshalIndent(built, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "workflow.json")
+			if err := os.WriteFile(path, out, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			w, err := ParseWorkflow(path)
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			if w.Format != FormatUI {
+				t.Errorf("format = %s, want %s", w.Format, FormatUI)
+			}
+			if len(w.Nodes) != len(prompt) {
+				t.Errorf("nodes = %d, want %d", len(w.Nodes), len(prompt))
+			}
+
+			back, err := w.ToPrompt(nil)
+			if err != nil {
+				t.Fatalf("ToPrompt: %v", err)
+			}
+			if !renumbered(prompt) {
+				comparePrompts(t, back, prompt)
+			} else if got, want := classTypes(back), classTypes(prompt); !reflect.DeepEqual(got, want) {
+				t.Errorf("class types = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// comparePrompts checks that got keeps every node and input of want. Inputs
+// left out of want take their default value in got.
+func comparePrompts(t *testing.T, got, want Prompt) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("prompt has %d nodes, want %d", len(got), len(want))
+	}
+	for id, wantNode := range want {
+		gotNode, ok := got[id]
+		if !ok {
+			t.Errorf("node %s missing", id)
+			continue
+		}
+		if gotNode.ClassType != wantNode.ClassType {
+			t.Errorf("node %s class_type = %s, want %s", id, gotNode.ClassType, wantNode.ClassType)
+		}
+		for name, value := range wantNode.Inputs {
+			if !reflect.DeepEqual(normalize(t, gotNode.Inputs[name]), normalize(t, value)) {
+				t.Errorf("node %s input %s = %v, want %v", id, name, gotNode.Inputs[name], value)
+			}
+		}
+	}
+}
+
+// normalize gives values the types encoding/json decodes them to, so that
+// links built as []interface{}{"4", 0} compare equal to parsed ones.
+func normalize(t *testing.T, value interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// renumbered reports whether ToWorkflow assigns new ids to the prompt's
+// nodes, which it does for non-numeric ids.
+func renumbered(p Prompt) bool {
+	for id := range p {
+		if _, ok := NodeID(id).Int(); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+func classTypes(p Prompt) []string {
+	var types []string
+	for _, node := range p {
+		types = append(types, node.ClassType)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,509 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"runcomfy/pkg/nodeschema"
+)
+
+// Override replaces the value of one widget. Node selects the node by id or
+// by title; when it is empty the input name alone must identify a single
+// node.
+type Override struct {
+	Node  string
+	Input string
+	Value interface{}
+
+	// text is set when Value is command-line text still to be converted
+	// to the input's type.
+	text bool
+}
+
+func (o Override) String() string {
+	if o.Node == "" {
+		return o.Input
+	}
+	return o.Node + "." + o.Input
+}
+
+// ParseOverride parses an override of the form
+// "[node=]<id|title>.<input>=<value>" or "<input>=<value>". The value is
+// converted to the type of the input when the override is applied.
+func ParseOverride(s string) (Override, error) {
+	if rest := strings.TrimPrefix(s, "node="); rest != s && strings.Contains(rest, "=") {
+		s = rest
+	}
+	selector, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return Override{}, fmt.Errorf("invalid override %q: expected <node>.<input>=<value>", s)
+	}
+
+	o := Override{Input: selector, Value: value, text: true}
+	if i := strings.LastIndex(selector, "."); i >= 0 {
+		o.Node, o.Input = selector[:i], selector[i+1:]
+		if o.Node == "" {
+			return Override{}, fmt.Errorf("invalid override %q: empty node selector", s)
+		}
+	}
+	if o.Input == "" {
+		return Override{}, fmt.Errorf("invalid override %q: empty input name", s)
+	}
+	return o, nil
+}
+
+// LoadOverrides reads overrides from a YAML file. Top-level keys are either
+// node selectors mapping input names to values, or "<node>.<input>" and
+// "<input>" selectors mapping directly to a value:
+//
+//	KSampler:
+//	  seed: 42
+//	  steps: 30
+//	"6.text": a lighthouse at dusk
+//	cfg: 6.5
+//
+// Values keep their YAML types, so quoted numbers are rejected for numeric
+// inputs.
+func LoadOverrides(path string) ([]Override, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read overrides file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse overrides file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: overrides must be a mapping of selectors to values", path)
+	}
+
+	var overrides []Override
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i].Value, root.Content[i+1]
+		if value.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				v, err := yamlScalar(value.Content[j+1])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s.%s: %w", path, key, value.Content[j].Value, err)
+				}
+				overrides = append(overrides, Override{Node: key, Input: value.Content[j].Value, Value: v})
+			}
+			continue
+		}
+
+		v, err := yamlScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+		o := Override{Input: key, Value: v}
+		if i := strings.LastIndex(key, "."); i > 0 {
+			o.Node, o.Input = key[:i], key[i+1:]
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
+}
+
+func yamlScalar(node *yaml.Node) (interface{}, error) {
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("expected a single value")
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// widgetPatch is a resolved override: the widget value to store in the
+// serialized node, by position in widgets_values or by name.
+type widgetPatch struct {
+	node  NodeID
+	index int
+	name  string
+	value interface{}
+}
+
+// ApplyOverrides returns data, a UI workflow or API prompt, with the given
+// widget values replaced. Values are converted to and checked against the
+// input types, ranges and choices of the node definitions in reg; a nil
+// registry means the bundled definitions. Overriding an input fed by a
+// primitive node updates the primitive as well. Only top-level nodes can be
+// selected, not the nodes inside group nodes or subgraphs. Everything else in
+// data is preserved, and the result is written in canonical form.
+func ApplyOverrides(data []byte, overrides []Override, reg *nodeschema.Registry) ([]byte, error) {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	w, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	graph := NewGraph(w)
+
+	var patches []widgetPatch
+	for _, o := range overrides {
+		node, err := selectNode(w, o, reg)
+		if err != nil {
+			return nil, err
+		}
+		resolved, err := resolveOverride(graph, w.Format, node, o, reg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot set %s: %w", o, err)
+		}
+		patches = append(patches, resolved...)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow JSON: %w", err)
+	}
+	for _, patch := range patches {
+		if err := applyPatch(doc, w.Format, patch); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, doc, 0); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// selectNode finds the one node an override refers to.
+func selectNode(w *Workflow, o Override, reg *nodeschema.Registry) (*Node, error) {
+	var matches []*Node
+	if o.Node != "" {
+		for i := range w.Nodes {
+			if w.Nodes[i].ID == NodeID(o.Node) {
+				return &w.Nodes[i], nil
+			}
+		}
+		for i := range w.Nodes {
+			if displayTitle(&w.Nodes[i], reg) == o.Node {
+				matches = append(matches, &w.Nodes[i])
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("cannot set %s: no node has id or title %q", o, o.Node)
+		}
+	} else {
+		for i := range w.Nodes {
+			node := &w.Nodes[i]
+			if !frontendNodes[node.Type] && hasWidget(node, o.Input, reg) {
+				matches = append(matches, node)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("cannot set %s: no node has a %q widget", o, o.Input)
+		}
+	}
+
+	if len(matches) > 1 {
+		candidates := make([]string, len(matches))
+		for i, node := range matches {
+			candidates[i] = fmt.Sprintf("%s (%s)", node.ID, displayTitle(node, reg))
+		}
+		return nil, fmt.Errorf("selector %q is ambiguous: it matches nodes %s; select one by id, e.g. %q",
+			o.String(), strings.Join(candidates, ", "), string(matches[0].ID)+"."+o.Input)
+	}
+	return matches[0], nil
+}
+
+func hasWidget(node *Node, name string, reg *nodeschema.Registry) bool {
+	if def, ok := reg.Lookup(node.Type); ok {
+		in, ok := def.Input(name)
+		return ok && in.IsWidget()
+	}
+	if _, ok := node.NamedWidgets[name]; ok {
+		return true
+	}
+	for _, input := range node.Inputs {
+		if input.Widget != nil && input.Widget.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveOverride converts the override value and locates the widget values
+// to change: the node's own and, when the input is fed by a primitive node,
+// the primitive's.
+func resolveOverride(g *Graph, format Format, node *Node, o Override, reg *nodeschema.Registry) ([]widgetPatch, error) {
+	def, _ := reg.Lookup(node.Type)
+	var in *nodeschema.InputDef
+	if def != nil {
+		var ok bool
+		if in, ok = def.Input(o.Input); !ok || !in.IsWidget() {
+			return nil, fmt.Errorf("%s has no %q widget", def.Name, o.Input)
+		}
+	} else if !hasWidget(node, o.Input, reg) {
+		return nil, fmt.Errorf("node %s (%s) has no %q widget", node.ID, node.Type, o.Input)
+	}
+
+	var current interface{}
+	if named, ok := namedWidgetValues(node, def); ok {
+		current = named[o.Input]
+	}
+	value, err := overrideValue(in, current, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var patches []widgetPatch
+	if edge, linked := g.InputEdge(node.ID, o.Input); linked {
+		source, ok := g.Source(edge)
+		if !ok || source.Type != "PrimitiveNode" || format == FormatAPI {
+			return nil, fmt.Errorf("input is connected to node %s; it has no widget value to set", edge.From)
+		}
+		patches = append(patches, widgetPatch{node: source.ID, index: 0, name: "value", value: value})
+	}
+
+	patch := widgetPatch{node: node.ID, index: -1, name: o.Input, value: value}
+	if len(node.NamedWidgets) == 0 && len(node.Widgets) > 0 {
+		index, err := widgetIndex(node, def, o.Input)
+		if err != nil {
+			return nil, err
+		}
+		patch.index = index
+	}
+	return append(patches, patch), nil
+}
+
+func namedWidgetValues(node *Node, def *nodeschema.NodeDef) (map[string]interface{}, bool) {
+	if def != nil {
+		return node.WidgetsByName(def)
+	}
+	return node.inputWidgetNames()
+}
+
+// widgetIndex finds the position of a widget in the node's widgets_values.
+func widgetIndex(node *Node, def *nodeschema.NodeDef, name string) (int, error) {
+	if def == nil {
+		index := 0
+		for _, input := range node.Inputs {
+			if input.Widget == nil {
+				continue
+			}
+			if input.Widget.Name == name {
+				if _, ok := node.inputWidgetNames(); ok {
+					return index, nil
+				}
+				break
+			}
+			index++
+		}
+		return 0, fmt.Errorf("the widget values of %s cannot be named without its node definition; pass --object-info", node.Type)
+	}
+
+	slots, ok := def.WidgetLayout(len(node.Widgets))
+	if !ok {
+		return 0, fmt.Errorf("%d widget values do not match the %d widgets of %s", len(node.Widgets), len(def.WidgetSlots()), def.Name)
+	}
+	for i, slot := range slots {
+		if slot.Input != nil && slot.Name == name {
+			if i >= len(node.Widgets) {
+				return 0, fmt.Errorf("node %s was saved without a %q value", node.ID, name)
+			}
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%s has no %q widget", def.Name, name)
+}
+
+// overrideValue converts an override value to the input's type and checks it
+// against the input definition. Without a definition the type of the current
+// value is kept.
+func overrideValue(in *nodeschema.InputDef, current interface{}, o Override) (interface{}, error) {
+	kind := ""
+	if in != nil {
+		kind = in.Type
+	} else {
+		switch current.(type) {
+		case float64:
+			kind = nodeschema.TypeFloat
+		case bool:
+			kind = nodeschema.TypeBoolean
+		case string:
+			kind = nodeschema.TypeString
+		}
+	}
+
+	var value interface{}
+	var number float64
+	switch kind {
+	case nodeschema.TypeInt:
+		s, ok := integerText(o)
+		if !ok {
+			return nil, fmt.Errorf("expected an integer, got %#v", o.Value)
+		}
+		number, _ = strconv.ParseFloat(s, 64)
+		value = json.Number(s)
+	case nodeschema.TypeFloat:
+		f, ok := floatValue(o)
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %#v", o.Value)
+		}
+		number = f
+		value = json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+	case nodeschema.TypeBoolean:
+		b, ok := o.Value.(bool)
+		if o.text {
+			var err error
+			b, err = strconv.ParseBool(o.Value.(string))
+			ok = err == nil
+		}
+		if !ok {
+			return nil, fmt.Errorf("expected a boolean, got %#v", o.Value)
+		}
+		value = b
+	case nodeschema.TypeString, nodeschema.TypeCombo:
+		s, ok := o.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %#v", o.Value)
+		}
+		value = s
+	default:
+		if o.text {
+			value = o.Value
+		} else {
+			value = jsonValue(o.Value)
+		}
+	}
+
+	if in != nil {
+		checked := value
+		if kind == nodeschema.TypeInt || kind == nodeschema.TypeFloat {
+			checked = number
+		}
+		if issue, bad := checkValue(in, checked); bad {
+			return nil, fmt.Errorf("%s", issue.Message)
+		}
+	}
+	return value, nil
+}
+
+// integerText returns the decimal text of an integer override, which may
+// exceed the precision of a float64.
+func integerText(o Override) (string, bool) {
+	switch v := o.Value.(type) {
+	case string:
+		if !o.text {
+			return "", false
+		}
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return v, true
+		}
+		if _, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return v, true
+		}
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return strconv.FormatInt(int64(v), 10), true
+		}
+	}
+	return "", false
+}
+
+func floatValue(o Override) (float64, bool) {
+	switch v := o.Value.(type) {
+	case string:
+		if !o.text {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// jsonValue converts a decoded YAML scalar to a value writeCanonical accepts.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return json.Number(strconv.Itoa(v))
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return v
+}
+
+// applyPatch stores a resolved widget value in the decoded workflow.
+func applyPatch(doc map[string]interface{}, format Format, patch widgetPatch) error {
+	if format == FormatAPI {
+		node, ok := doc[string(patch.node)].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("node %s not found", patch.node)
+		}
+		inputs, ok := node["inputs"].(map[string]interface{})
+		if !ok {
+			inputs = make(map[string]interface{})
+			node["inputs"] = inputs
+		}
+		inputs[patch.name] = patch.value
+		return nil
+	}
+
+	var node map[string]interface{}
+	switch nodes := doc["nodes"].(type) {
+	case []interface{}:
+		for _, item := range nodes {
+			if n, ok := item.(map[string]interface{}); ok && fmt.Sprint(n["id"]) == string(patch.node) {
+				node = n
+				break
+			}
+		}
+	case map[string]interface{}:
+		node, _ = nodes[string(patch.node)].(map[string]interface{})
+	}
+	if node == nil {
+		return fmt.Errorf("node %s not found", patch.node)
+	}
+
+	switch widgets := node["widgets_values"].(type) {
+	case map[string]interface{}:
+		widgets[patch.name] = patch.value
+	case []interface{}:
+		if patch.index < 0 || patch.index >= len(widgets) {
+			return fmt.Errorf("node %s has no widget value at position %d", patch.node, patch.index)
+		}
+		widgets[patch.index] = patch.value
+	default:
+		return fmt.Errorf("node %s has no widget values", patch.node)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"runcomfy/pkg/nodeschema"
+)
+
+// Param is a widget value a user can tune without touching the graph, such
+// as a prompt, seed, image size, model choice or input image. Its Name is the
+// "<node id>.<input>" selector accepted by ApplyOverrides.
+type Param struct {
+	Name      string
+	Title     string
+	NodeID    NodeID
+	NodeType  string
+	NodeTitle string
+	Input     string
+	// Type is the widget type from the node definition, or inferred from
+	// the current value for nodes without one.
+	Type    string
+	Value   interface{}
+	Choices []string
+	Min     *float64
+	Max     *float64
+	Step    *float64
+	// Multiline marks long text such as prompts.
+	Multiline bool
+	// Upload is the upload widget kind of file inputs read from ComfyUI's
+	// input directory.
+	Upload string
+	// Folder is the model folder a model choice selects from.
+	Folder string
+	// Primitive is the primitive node holding the value, if any.
+	Primitive NodeID
+}
+
+// ParamOptions restricts which nodes Params considers.
+type ParamOptions struct {
+	// Group keeps the nodes inside the groups with this title.
+	Group string
+	// TitlePrefix keeps the nodes, or the primitive nodes feeding them,
+	// whose title starts with this prefix.
+	TitlePrefix string
+}
+
+// Params lists the tunable widget values of the workflow's active top-level
+// nodes in node id order, using reg to name and type them; a nil registry
+// means the bundled definitions. Widgets fed by another node's output are not
+// tunable and are left out, except for those fed by a primitive node, which
+// are listed once under their first consumer.
+func (w *Workflow) Params(reg *nodeschema.Registry, opts ParamOptions) ([]Param, error) {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	graph := NewGraph(w)
+
+	var groups []Group
+	if opts.Group != "" {
+		for _, group := range w.Groups {
+			if group.Title == opts.Group {
+				groups = append(groups, group)
+			}
+		}
+		if len(groups) == 0 {
+			return nil, fmt.Errorf("workflow has no group named %q", opts.Group)
+		}
+	}
+
+	var params []Param
+	primitives := make(map[NodeID]bool)
+	for _, id := range graph.NodeIDs() {
+		node, _ := graph.Node(id)
+		if !node.IsActive() || frontendNodes[node.Type] {
+			continue
+		}
+		def, _ := reg.Lookup(node.Type)
+		for _, p := range nodeParams(graph, node, def, reg) {
+			var primitive *Node
+			if p.Primitive != "" {
+				if primitives[p.Primitive] {
+					continue
+				}
+				primitive, _ = graph.Node(p.Primitive)
+			}
+			if len(groups) > 0 && !inGroups(node, groups) && (primitive == nil || !inGroups(primitive, groups)) {
+				continue
+			}
+			if opts.TitlePrefix != "" && !strings.HasPrefix(p.NodeTitle, opts.TitlePrefix) &&
+				(primitive == nil || !strings.HasPrefix(primitive.Title, opts.TitlePrefix)) {
+				continue
+			}
+			if primitive != nil {
+				primitives[primitive.ID] = true
+				if primitive.Title != "" {
+					p.Title = primitive.Title
+				}
+			}
+			params = append(params, p)
+		}
+	}
+	return params, nil
+}
+
+// nodeParams lists the tunable widgets of one node in widget order.
+func nodeParams(g *Graph, node *Node, def *nodeschema.NodeDef, reg *nodeschema.Registry) []Param {
+	title := displayTitle(node, reg)
+	param := func(name string, value interface{}) Param {
+		return Param{
+			Name:      node.ID.String() + "." + name,
+			Title:     title + " " + name,
+			NodeID:    node.ID,
+			NodeType:  node.Type,
+			NodeTitle: title,
+			Input:     name,
+			Type:      valueType(value),
+			Value:     value,
+		}
+	}
+
+	if def == nil {
+		values, ok := node.inputWidgetNames()
+		if !ok {
+			return nil
+		}
+		var params []Param
+		for _, name := range sortedKeys(values) {
+			// Objects and arrays are editor state, such as video previews.
+			switch values[name].(type) {
+			case map[string]interface{}, []interface{}:
+				continue
+			}
+			if _, linked := g.InputEdge(node.ID, name); !linked {
+				params = append(params, param(name, values[name]))
+			}
+		}
+		return params
+	}
+
+	values, mapped := node.WidgetsByName(def)
+	var params []Param
+	for i := range def.Inputs {
+		in := &def.Inputs[i]
+		if !in.IsWidget() {
+			continue
+		}
+
+		value, present := values[in.Name]
+		var primitive NodeID
+		if edge, linked := g.InputEdge(node.ID, in.Name); linked {
+			source, ok := g.Source(edge)
+			if !ok || source.Type != "PrimitiveNode" {
+				continue
+			}
+			if value, present = primitiveValue(source); !present {
+				continue
+			}
+			primitive = source.ID
+		} else if !mapped {
+			continue
+		}
+		if !present {
+			value = in.Default
+		}
+
+		p := param(in.Name, value)
+		p.Type = in.Type
+		p.Choices = in.Choices
+		p.Min, p.Max, p.Step = in.Min, in.Max, in.Step
+		p.Multiline = in.Multiline
+		p.Upload = in.Upload
+		p.Folder = in.Folder
+		p.Primitive = primitive
+		params = append(params, p)
+	}
+	return params
+}
+
+func valueType(value interface{}) string {
+	switch value.(type) {
+	case float64:
+		return nodeschema.TypeFloat
+	case bool:
+		return nodeschema.TypeBoolean
+	case string:
+		return nodeschema.TypeString
+	}
+	return ""
+}
+
+// inGroups reports whether the centre of the node lies inside one of the
+// groups, which is how the editor decides group membership.
+func inGroups(node *Node, groups []Group) bool {
+	x, y, width, height, ok := node.Bounds()
+	if !ok {
+		return false
+	}
+	x += width / 2
+	y += height / 2
+	for _, group := range groups {
+		b := group.Bounding
+		if len(b) == 4 && x >= b[0] && x <= b[0]+b[2] && y >= b[1] && y <= b[1]+b[3] {
+			return true
+		}
+	}
+	return false
+}
+
+// vector reads a position or size, which the editor stores either as an
+// array or as an object with numeric keys.
+func vector(v interface{}) ([2]float64, bool) {
+	switch v := v.(type) {
+	case []float64:
+		if len(v) >= 2 {
+			return [2]float64{v[0], v[1]}, true
+		}
+	case []interface{}:
+		if len(v) >= 2 {
+			x, xok := v[0].(float64)
+			y, yok := v[1].(float64)
+			return [2]float64{x, y}, xok && yok
+		}
+	case map[string]interface{}:
+		x, xok := v["0"].(float64)
+		y, yok := v["1"].(float64)
+		return [2]float64{x, y}, xok && yok
+	}
+	return [2]float64{}, false
+}
+
+// ParamSchema describes the parameters as a JSON Schema object whose
+// property names are the parameter selectors. Current values become
+// defaults, and each property carries the node it belongs to under
+// "x-comfyui". Properties keep the order of params.
+func ParamSchema(title string, params []Param) map[string]interface{} {
+	properties := make(orderedObject, 0, len(params))
+	for _, p := range params {
+		properties = append(properties, objectField{p.Name, paramProperty(p)})
+	}
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                title,
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+func paramProperty(p Param) map[string]interface{} {
+	property := map[string]interface{}{"title": p.Title}
+	switch p.Type {
+	case nodeschema.TypeInt:
+		property["type"] = "integer"
+		if p.Step != nil && *p.Step > 1 {
+			property["multipleOf"] = *p.Step
+		}
+	case nodeschema.TypeFloat:
+		property["type"] = "number"
+	case nodeschema.TypeBoolean:
+		property["type"] = "boolean"
+	case nodeschema.TypeString, nodeschema.TypeCombo:
+		property["type"] = "string"
+	}
+	if len(p.Choices) > 0 {
+		property["enum"] = p.Choices
+	}
+	if p.Min != nil {
+		property["minimum"] = *p.Min
+	}
+	if p.Max != nil {
+		property["maximum"] = *p.Max
+	}
+	if p.Value != nil {
+		property["default"] = p.Value
+	}
+
+	meta := map[string]interface{}{
+		"node":     p.NodeID,
+		"nodeType": p.NodeType,
+		"input":    p.Input,
+	}
+	if p.Multiline {
+		meta["multiline"] = true
+	}
+	if p.Upload != "" {
+		meta["upload"] = p.Upload
+	}
+	if p.Folder != "" {
+		meta["folder"] = p.Folder
+	}
+	if p.Primitive != "" {
+		meta["primitive"] = p.Primitive
+	}
+	property["x-comfyui"] = meta
+	return property
+}
+
+// orderedObject is a JSON object that keeps the order of its fields.
+type orderedObject []objectField
+
+type objectField struct {
+	key   string
+	value interface{}
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -3,103 +3,416 @@ package workflow
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"runcomfy/pkg/nodeschema"
 )
 
+// ParseWorkflow loads a workflow from a JSON file, or from the metadata
+// ComfyUI embeds in PNG, WebP, MP4 and WebM outputs.
 func ParseWorkflow(filePath string) (*Workflow, error) {
-	data, err := ioutil.ReadFile(filePath)
+	isMedia, err := isMediaFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow file: %w", err)
+	}
+	if isMedia {
+		return ParseEmbedded(filePath)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow file: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// LoadPrompt reads an API prompt from a JSON file, or the prompt embedded in
+// a PNG, WebP, MP4 or WebM output.
+func LoadPrompt(filePath string) (Prompt, error) {
+	isMedia, err := isMediaFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read workflow file: %w", err)
 	}
+	if isMedia {
+		embedded, err := ExtractEmbedded(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if len(embedded.Prompt) == 0 {
+			return nil, fmt.Errorf("%s does not embed an API prompt", filePath)
+		}
+		return ParsePrompt(embedded.Prompt)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow file: %w", err)
+	}
+	return ParsePrompt(data)
+}
+
+// ReadWorkflow returns the workflow JSON stored in a file: the file itself,
+// or for PNG, WebP, MP4 and WebM outputs the embedded UI workflow, falling
+// back to the embedded API prompt.
+func ReadWorkflow(filePath string) ([]byte, error) {
+	isMedia, err := isMediaFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow file: %w", err)
+	}
+	if !isMedia {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read workflow file: %w", err)
+		}
+		return data, nil
+	}
+
+	embedded, err := ExtractEmbedded(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded.Workflow) > 0 {
+		if _, err := DetectFormat(embedded.Workflow); err == nil {
+			return embedded.Workflow, nil
+		}
+	}
+	if len(embedded.Prompt) == 0 {
+		return nil, fmt.Errorf("%s: %w", filePath, errNoEmbeddedWorkflow)
+	}
+	return embedded.Prompt, nil
+}
+
+func isMediaFile(filePath string) (bool, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 16)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	_, ok := DetectMedia(header[:n])
+	return ok, nil
+}
+
+// Parse decodes workflow JSON, detecting whether it is a UI workflow or an
+// API prompt. UI nodes may be stored as an array or keyed by id, and links
+// may use either the array or the object encoding.
+func Parse(data []byte) (*Workflow, error) {
+	format, err := DetectFormat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == FormatAPI {
+		prompt, err := ParsePrompt(data)
+		if err != nil {
+			return nil, err
+		}
+		return prompt.Workflow()
+	}
 
 	var workflow Workflow
 	if err := json.Unmarshal(data, &workflow); err != nil {
 		return nil, fmt.Errorf("failed to parse workflow JSON: %w", err)
 	}
+	workflow.Format = FormatUI
 
 	return &workflow, nil
 }
 
+// ExtractDependencies lists the node types, models and input files the
+// workflow refers to, looking inside group nodes and subgraphs. Dependencies
+// coming only from muted or bypassed nodes are marked optional. Widget values are named using
+// the bundled core node definitions.
 func (w *Workflow) ExtractDependencies() []Dependency {
+	return w.ExtractDependenciesUsing(nodeschema.Bundled())
+}
+
+// ExtractDependenciesUsing is like ExtractDependencies but names widget
+// values using the given node definitions. Nodes missing from the registry,
+// or whose widgets do not fit their definition, fall back to recognising
+// model files by extension. Each file is listed once, under the path it is
+// first referred to by; paths in folders ComfyUI searches together, such as
+// "unet" and "diffusion_models", refer to the same file. A nil registry
+// means the bundled definitions.
+func (w *Workflow) ExtractDependenciesUsing(reg *nodeschema.Registry) []Dependency {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	w = w.Expand()
 	var deps []Dependency
-	nodeTypes := make(map[string]bool)
+	nodeTypes := make(map[string]int)
+	files := make(map[string]int)
+	graph := NewGraph(w)
 
-	for _, node := range w.Nodes {
-		if !nodeTypes[node.Type] {
-			nodeTypes[node.Type] = true
+	addFile := func(dep Dependency) {
+		key := dep.Type + ":" + fileKey(dep.Path)
+		if idx, seen := files[key]; !seen {
+			files[key] = len(deps)
+			deps = append(deps, dep)
+		} else if !dep.Optional {
+			deps[idx].Optional = false
+		}
+	}
+
+	for i := range w.Nodes {
+		node := &w.Nodes[i]
+		optional := !node.IsActive()
+
+		if idx, seen := nodeTypes[node.Type]; !seen {
+			nodeTypes[node.Type] = len(deps)
 			deps = append(deps, Dependency{
-				Type: "node",
-				Name: node.Type,
-				Path: "",
+				Type:     "node",
+				Name:     node.Type,
+				Path:     "",
+				Optional: optional,
 			})
+		} else if !optional {
+			deps[idx].Optional = false
 		}
 
-		if node.Properties != nil {
-			if model, ok := node.Properties["model_name"].(string); ok && model != "" {
-				deps = append(deps, Dependency{
-					Type: "model",
-					Name: model,
-					Path: inferModelPath(node.Type, model),
-				})
-			}
-			if ckpt, ok := node.Properties["ckpt_name"].(string); ok && ckpt != "" {
-				deps = append(deps, Dependency{
-					Type: "model",
-					Name: ckpt,
-					Path: "checkpoints/" + ckpt,
-				})
-			}
-			if lora, ok := node.Properties["lora_name"].(string); ok && lora != "" {
-				deps = append(deps, Dependency{
-					Type: "model",
-					Name: lora,
-					Path: "loras/" + lora,
-				})
-			}
+		// Values held by primitives and reroutes are attributed to the
+		// nodes consuming them.
+		if primitiveNodes[node.Type] || passThroughNodes[node.Type] {
+			continue
 		}
 
-		if len(node.Widgets) > 0 {
-			for _, widget := range node.Widgets {
-				if str, ok := widget.(string); ok {
-					if isModelFile(str) {
-						deps = append(deps, Dependency{
-							Type: "model",
-							Name: str,
-							Path: inferModelPath(node.Type, str),
-						})
-					}
-				}
-			}
+		nodeDeps := graph.linkedModelDependencies(node)
+		if schemaDeps, ok := node.schemaDependencies(reg); ok {
+			nodeDeps = append(nodeDeps, schemaDeps...)
+		} else {
+			nodeDeps = append(nodeDeps, node.modelDependencies()...)
+		}
+		for _, dep := range nodeDeps {
+			dep.Optional = optional
+			addFile(dep)
 		}
 	}
 
 	for _, model := range w.Models {
+		addFile(Dependency{
+			Type: "model",
+			Name: normalizeFileName(model.Name),
+			Path: modelPath(model.Directory, model.Name),
+		})
+	}
+
+	return deps
+}
+
+// ModelHints returns the download hints the workflow carries for its models,
+// from its model list and the "models" property newer frontends store on
+// loader nodes, keyed by the Path of the model's dependency. Hints with a URL
+// win over those without.
+func (w *Workflow) ModelHints() map[string]Model {
+	hints := make(map[string]Model)
+	add := func(model Model) {
+		key := modelPath(model.Directory, model.Name)
+		if old, ok := hints[key]; !ok || old.URL == "" {
+			hints[key] = model
+		}
+	}
+	for _, node := range w.Expand().Nodes {
+		for _, model := range propertyModels(node.Properties) {
+			add(model)
+		}
+	}
+	for _, model := range w.Models {
+		add(model)
+	}
+	return hints
+}
+
+// WidgetsByName returns the node's widget values keyed by input name, using
+// def to name positional values. It returns false when the values do not fit
+// the definition.
+func (n *Node) WidgetsByName(def *nodeschema.NodeDef) (map[string]interface{}, bool) {
+	if len(n.NamedWidgets) > 0 {
+		return n.NamedWidgets, true
+	}
+	if len(n.Widgets) == 0 {
+		return map[string]interface{}{}, true
+	}
+	return def.MapWidgets(n.Widgets)
+}
+
+// schemaDependencies reads the models selected by the node's combo widgets,
+// taking the folder from the node definition.
+func (n *Node) schemaDependencies(reg *nodeschema.Registry) ([]Dependency, bool) {
+	def, ok := reg.Lookup(n.Type)
+	if !ok {
+		return nil, false
+	}
+	values, ok := n.WidgetsByName(def)
+	if !ok {
+		return nil, false
+	}
+
+	var deps []Dependency
+	for _, input := range def.Inputs {
+		if input.Type != nodeschema.TypeCombo || n.IsLinked(input.Name) {
+			continue
+		}
+		str, ok := values[input.Name].(string)
+		if !ok || str == "" {
+			continue
+		}
+		switch {
+		case input.Upload != "" || nodeschema.IsAssetInput(n.Type, input.Name):
+			if dep, ok := inputAssetDependency(str); ok {
+				deps = append(deps, dep)
+			}
+		case input.Folder != "":
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(str),
+				Path: modelPath(input.Folder, str),
+			})
+		case isModelFile(str):
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(str),
+				Path: inferModelPath(n.Type, str),
+			})
+		}
+	}
+
+	for _, model := range propertyModels(n.Properties) {
 		deps = append(deps, Dependency{
 			Type: "model",
-			Name: model.Name,
-			Path: model.Directory + "/" + model.Name,
+			Name: normalizeFileName(model.Name),
+			Path: modelPath(model.Directory, model.Name),
 		})
 	}
+	return deps, true
+}
+
+func (n *Node) modelDependencies() []Dependency {
+	var deps []Dependency
+
+	if n.Properties != nil {
+		if model, ok := n.Properties["model_name"].(string); ok && model != "" {
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(model),
+				Path: inferModelPath(n.Type, model),
+			})
+		}
+		if ckpt, ok := n.Properties["ckpt_name"].(string); ok && ckpt != "" {
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(ckpt),
+				Path: modelPath("checkpoints", ckpt),
+			})
+		}
+		if lora, ok := n.Properties["lora_name"].(string); ok && lora != "" {
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(lora),
+				Path: modelPath("loras", lora),
+			})
+		}
+		for _, model := range propertyModels(n.Properties) {
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(model.Name),
+				Path: modelPath(model.Directory, model.Name),
+			})
+		}
+	}
+
+	for _, name := range sortedKeys(n.NamedWidgets) {
+		str, ok := n.NamedWidgets[name].(string)
+		if !ok || str == "" || n.IsLinked(name) {
+			continue
+		}
+		if nodeschema.IsAssetInput(n.Type, name) {
+			if dep, ok := inputAssetDependency(str); ok {
+				deps = append(deps, dep)
+			}
+		} else if folder := nodeschema.InputFolder(n.Type, name); folder != "" {
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(str),
+				Path: modelPath(folder, str),
+			})
+		} else if isModelFile(str) {
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(str),
+				Path: inferModelPath(n.Type, str),
+			})
+		}
+	}
+
+	for _, widget := range n.Widgets {
+		if str, ok := widget.(string); ok {
+			if isModelFile(str) {
+				deps = append(deps, Dependency{
+					Type: "model",
+					Name: normalizeFileName(str),
+					Path: inferModelPath(n.Type, str),
+				})
+			}
+		}
+	}
 
 	return deps
 }
 
+var builtinNodes = map[string]bool{
+	"CheckpointLoaderSimple": true,
+	"CLIPTextEncode":         true,
+	"KSampler":               true,
+	"VAEDecode":              true,
+	"SaveImage":              true,
+	"LoadImage":              true,
+	"EmptyLatentImage":       true,
+
+	// Frontend-only nodes that never reach the server.
+	"PrimitiveNode": true,
+	"Reroute":       true,
+	"Note":          true,
+	"MarkdownNote":  true,
+}
+
+// GetCustomNodes returns the non-builtin node types used by active nodes,
+// looking inside group nodes and subgraphs.
 func (w *Workflow) GetCustomNodes() []string {
+	w = w.Expand()
 	var customNodes []string
-	builtinNodes := map[string]bool{
-		"CheckpointLoaderSimple": true,
-		"CLIPTextEncode":         true,
-		"KSampler":               true,
-		"VAEDecode":              true,
-		"SaveImage":              true,
-		"LoadImage":              true,
-		"EmptyLatentImage":       true,
+
+	for _, node := range w.Nodes {
+		if node.IsActive() && !builtinNodes[node.Type] {
+			customNodes = append(customNodes, node.Type)
+		}
+	}
+
+	return removeDuplicates(customNodes)
+}
+
+// GetInactiveCustomNodes returns the non-builtin node types that are only
+// used by muted or bypassed nodes.
+func (w *Workflow) GetInactiveCustomNodes() []string {
+	w = w.Expand()
+	active := make(map[string]bool)
+	for _, nodeType := range w.GetCustomNodes() {
+		active[nodeType] = true
 	}
 
+	var customNodes []string
 	for _, node := range w.Nodes {
-		if !builtinNodes[node.Type] {
+		if !node.IsActive() && !builtinNodes[node.Type] && !active[node.Type] {
 			customNodes = append(customNodes, node.Type)
 		}
 	}
@@ -107,21 +420,156 @@ func (w *Workflow) GetCustomNodes() []string {
 	return removeDuplicates(customNodes)
 }
 
+// IsActive reports whether the node runs, i.e. it is neither muted nor
+// bypassed.
+func (n *Node) IsActive() bool {
+	return n.Mode != ModeNever && n.Mode != ModeBypass
+}
+
+// propertyModels reads the model download hints that newer frontends store
+// under a node's "models" property.
+func propertyModels(properties map[string]interface{}) []Model {
+	list, ok := properties["models"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var models []Model
+	for _, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := entry["name"].(string)
+		if name == "" {
+			continue
+		}
+		url, _ := entry["url"].(string)
+		directory, _ := entry["directory"].(string)
+		models = append(models, Model{Name: name, URL: url, Directory: directory})
+	}
+	return models
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WidgetValues returns the node's widget values regardless of whether they
+// were stored positionally or by name.
+func (n *Node) WidgetValues() []interface{} {
+	if len(n.NamedWidgets) == 0 {
+		return n.Widgets
+	}
+
+	names := sortedKeys(n.NamedWidgets)
+	values := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		values = append(values, n.NamedWidgets[name])
+	}
+	return values
+}
+
+// inputAssetDependency turns the value of an upload widget into a
+// dependency on a file in ComfyUI's input directory. The editor annotates
+// files picked from other directories with " [output]" or " [temp]"; those
+// are produced by earlier runs rather than supplied, so they are skipped.
+func inputAssetDependency(value string) (Dependency, bool) {
+	if strings.HasSuffix(value, " [output]") || strings.HasSuffix(value, " [temp]") {
+		return Dependency{}, false
+	}
+	name := strings.TrimSuffix(value, " [input]")
+	return Dependency{
+		Type: "input",
+		Name: normalizeFileName(name),
+		Path: "input/" + normalizeFileName(name),
+	}, true
+}
+
 func inferModelPath(nodeType, modelName string) string {
 	switch {
 	case strings.Contains(strings.ToLower(nodeType), "checkpoint"):
-		return "checkpoints/" + modelName
+		return modelPath("checkpoints", modelName)
 	case strings.Contains(strings.ToLower(nodeType), "lora"):
-		return "loras/" + modelName
+		return modelPath("loras", modelName)
 	case strings.Contains(strings.ToLower(nodeType), "vae"):
-		return "vae/" + modelName
+		return modelPath("vae", modelName)
 	case strings.Contains(strings.ToLower(nodeType), "controlnet"):
-		return "controlnet/" + modelName
+		return modelPath("controlnet", modelName)
 	case strings.Contains(strings.ToLower(nodeType), "upscale"):
-		return "upscale_models/" + modelName
+		return modelPath("upscale_models", modelName)
 	default:
-		return "models/" + modelName
+		return modelPath("models", modelName)
+	}
+}
+
+// modelPath joins a model folder and a model name as selected in a widget,
+// which may include subfolders, into a dependency path such as
+// "checkpoints/SDXL/juggernaut.safetensors".
+func modelPath(folder, name string) string {
+	name = relativeFileName(folder, name)
+	if folder == "" {
+		return name
+	}
+	return folder + "/" + name
+}
+
+// fileKey identifies a dependency path regardless of which of the folders
+// ComfyUI searches together it names, so "unet/x.safetensors" and
+// "diffusion_models/x.safetensors" share a key.
+func fileKey(depPath string) string {
+	folder, name, ok := strings.Cut(depPath, "/")
+	if !ok {
+		return depPath
+	}
+	return nodeschema.CanonicalFolder(folder) + "/" + name
+}
+
+// normalizeFileName writes a file name relative to a ComfyUI folder with
+// forward slashes. Workflows saved on Windows separate subfolders with
+// backslashes, as in "SDXL\\juggernaut.safetensors".
+func normalizeFileName(name string) string {
+	return relativeFileName("", name)
+}
+
+// relativeFileName is normalizeFileName for a file in folder. Some loaders
+// store absolute paths, such as "C:\\Users\\alice\\loras\\x.safetensors";
+// these are cut down to the part below the last directory named after
+// folder, or below a "models/<folder>" directory, and to the file name
+// when there is neither.
+func relativeFileName(folder, name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if !isAbsFileName(name) {
+		return strings.TrimPrefix(path.Clean(name), "/")
+	}
+
+	parts := strings.Split(path.Clean(name), "/")
+	for i := len(parts) - 2; i >= 0; i-- {
+		if folder != "" && nodeschema.CanonicalFolder(strings.ToLower(parts[i])) == nodeschema.CanonicalFolder(folder) {
+			return strings.Join(parts[i+1:], "/")
+		}
+	}
+	for i := len(parts) - 3; i >= 0; i-- {
+		if strings.EqualFold(parts[i], "models") {
+			return strings.Join(parts[i+2:], "/")
+		}
+	}
+	return parts[len(parts)-1]
+}
+
+// isAbsFileName reports whether a file name with forward slashes is an
+// absolute path, on Windows ("C:/...", "//server/...") or elsewhere.
+func isAbsFileName(name string) bool {
+	if strings.HasPrefix(name, "/") {
+		return true
 	}
+	return len(name) >= 2 && name[1] == ':' &&
+		(name[0] >= 'A' && name[0] <= 'Z' || name[0] >= 'a' && name[0] <= 'z')
 }
 
 func isModelFile(filename string) bool {
@@ -132,6 +580,8 @@ func isModelFile(filename string) bool {
 		".pt":          true,
 		".pth":         true,
 		".bin":         true,
+		".gguf":        true,
+		".sft":         true,
 	}
 	return modelExts[ext]
 }
@@ -0,0 +1,247 @@
+package workflow
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fixtureTests describes every workflow in testdata: exports of the
+// ComfyUI editor in schema 0.4 and 1.0, API prompts and outputs with the
+// workflow embedded.
+var fixtureTests = []struct {
+	file    string
+	format  Format
+	version int
+	nodes   int
+	links   int
+	models  []string
+
+	// dangling marks fixtures whose links may refer to deleted nodes.
+	dangling bool
+}{
+	{
+		file: "animatediff_vhs_v04.json", format: FormatUI, version: 0, nodes: 8, links: 10,
+		models: []string{"checkpoints/dreamshaper_8.safetensors", "models/mm_sd_v15_v2.ckpt"},
+	},
+	{
+		file: "animatediff_output.mp4", format: FormatUI, version: 0, nodes: 8, links: 10,
+		models: []string{"checkpoints/dreamshaper_8.safetensors", "models/mm_sd_v15_v2.ckpt"},
+	},
+	{
+		file: "animatediff_output.webm", format: FormatUI, version: 0, nodes: 8, links: 10,
+		models: []string{"checkpoints/dreamshaper_8.safetensors", "models/mm_sd_v15_v2.ckpt"},
+	},
+	{
+		file: "flux_dev_v1.json", format: FormatUI, version: 1, nodes: 10, links: 10,
+		models: []string{
+			"clip/clip_l.safetensors", "clip/t5xxl_fp8_e4m3fn.safetensors",
+			"unet/flux1-dev.safetensors", "vae/ae.safetensors",
+		},
+	},
+	{
+		file: "flux_subgraph_v1.json", format: FormatUI, version: 1, nodes: 11, links: 11,
+		models: []string{
+			"clip/clip_l.safetensors", "clip/t5xxl_fp8_e4m3fn.safetensors",
+			"unet/flux1-dev.safetensors",
+			"upscale_models/4x_NMKD-Siax_200k.pth", "vae/ae.safetensors",
+		},
+	},
+	{
+		file: "flux_gguf_primitive_api.json", format: FormatAPI, nodes: 9, links: 9,
+		models: []string{
+			"clip/clip_l.safetensors", "clip/t5-v1_1-xxl-encoder-Q8_0.gguf",
+			"unet/flux1-schnell-Q8_0.gguf", "vae/ae.safetensors",
+		},
+	},
+	{
+		file: "flux_subfolders_api.json", format: FormatAPI, nodes: 9, links: 9,
+		models: []string{
+			"clip/clip_l.safetensors", "clip/t5/t5xxl_fp16.safetensors", "loras/flux/styles/ink_wash.safetensors",
+			"unet/flux/flux1-dev.safetensors", "vae/ae.safetensors",
+		},
+	},
+	{
+		// The bypassed LoraLoader's model is optional.
+		file: "sdxl_bypass_v04.json", format: FormatUI, version: 0, nodes: 10, links: 13,
+		models: []string{"checkpoints/sd_xl_base_1.0.safetensors"},
+	},
+	{
+		file: "sdxl_bypass_v04_api.json", format: FormatAPI, nodes: 7, links: 9,
+		models: []string{"checkpoints/sd_xl_base_1.0.safetensors"},
+	},
+	{
+		file: "sdxl_group_node_v04.json", format: FormatUI, version: 0, nodes: 7, links: 9,
+		models: []string{"checkpoints/juggernautXL_v9Rdphoto2Lightning.safetensors", "loras/add-detail-xl.safetensors"},
+	},
+	{
+		file: "sdxl_group_node_v04_api.json", format: FormatAPI, nodes: 8, links: 11,
+		models: []string{"checkpoints/juggernautXL_v9Rdphoto2Lightning.safetensors", "loras/add-detail-xl.safetensors"},
+	},
+	{
+		file: "sdxl_inpaint_v04.json", format: FormatUI, version: 0, nodes: 10, links: 13,
+		models: []string{"checkpoints/sd_xl_base_1.0.safetensors"},
+	},
+	{
+		file: "sdxl_inpaint_api.json", format: FormatAPI, nodes: 10, links: 13,
+		models: []string{"checkpoints/sd_xl_base_1.0.safetensors"},
+	},
+	{
+		file: "sdxl_invalid_v04.json", format: FormatUI, version: 0, nodes: 5, links: 7, dangling: true,
+		models: []string{"checkpoints/sd_xl_base_1.0.safetensors"},
+	},
+	{
+		file: "sdxl_lora_v04.json", format: FormatUI, version: 0, nodes: 10, links: 17,
+		models: []string{
+			"checkpoints/sd_xl_base_1.0.safetensors", "loras/detail_tweaker_xl.safetensors", "upscale_models/4x-UltraSharp.pth",
+		},
+	},
+	{
+		file: "sdxl_lora_output.png", format: FormatUI, version: 0, nodes: 10, links: 17,
+		models: []string{
+			"checkpoints/sd_xl_base_1.0.safetensors", "loras/detail_tweaker_xl.safetensors", "upscale_models/4x-UltraSharp.pth",
+		},
+	},
+	{
+		file: "sdxl_lora_api.json", format: FormatAPI, nodes: 9, links: 11,
+		models: []string{
+			"checkpoints/sd_xl_base_1.0.safetensors", "loras/detail_tweaker_xl.safetensors", "unet/flux1-schnell-Q4_K_S.gguf",
+		},
+	},
+	{
+		file: "sdxl_lora_prompt_only.webp", format: FormatAPI, nodes: 9, links: 11,
+		models: []string{
+			"checkpoints/sd_xl_base_1.0.safetensors", "loras/detail_tweaker_xl.safetensors", "unet/flux1-schnell-Q4_K_S.gguf",
+		},
+	},
+	{
+		file: "sdxl_primitive_reroute_v04.json", format: FormatUI, version: 0, nodes: 12, links: 15,
+		models: []string{"checkpoints/realvisxlV40_v40Bakedvae.safetensors", "loras/xl_more_art-full_v1.safetensors"},
+	},
+	{
+		file: "sdxl_primitive_reroute_v04_api.json", format: FormatAPI, nodes: 8, links: 11,
+		models: []string{"checkpoints/realvisxlV40_v40Bakedvae.safetensors", "loras/xl_more_art-full_v1.safetensors"},
+	},
+	{
+		file: "sdxl_private_v04.json", format: FormatUI, version: 0, nodes: 12, links: 17,
+		models: []string{
+			"checkpoints/alice/sdxl/sd_xl_base_1.0.safetensors", "loras/add_detail.safetensors", "upscale_models/4x-UltraSharp.pth",
+		},
+	},
+}
+
+func TestParseWorkflowFixtures(t *testing.T) {
+	for _, tt := range fixtureTests {
+		t.Run(tt.file, func(t *testing.T) {
+			w, err := ParseWorkflow(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			if w.Format != tt.format {
+				t.Errorf("format = %s, want %s", w.Format, tt.format)
+			}
+			if tt.format == FormatUI && w.Version.Major() != tt.version {
+				t.Errorf("version = %s, want %d.x", w.Version, tt.version)
+			}
+			if len(w.Nodes) != tt.nodes {
+				t.Errorf("nodes = %d, want %d", len(w.Nodes), tt.nodes)
+			}
+			if len(w.Links) != tt.links {
+				t.Errorf("links = %d, want %d", len(w.Links), tt.links)
+			}
+
+			nodes := make(map[NodeID]bool, len(w.Nodes))
+			for _, node := range w.Nodes {
+				if node.Type == "" {
+					t.Errorf("node %s has no type", node.ID)
+				}
+				nodes[node.ID] = true
+			}
+			for _, link := range w.Links {
+				if !tt.dangling && (!nodes[link.OriginID] || !nodes[link.TargetID]) {
+					t.Errorf("link %d connects unknown nodes %s → %s", link.ID, link.OriginID, link.TargetID)
+				}
+			}
+
+			if got := sortedModels(w); !reflect.DeepEqual(got, tt.models) {
+				t.Errorf("models = %q, want %q", got, tt.models)
+			}
+		})
+	}
+}
+
+// TestParseWorkflowFixturesCovered keeps fixtureTests in step with the
+// files in testdata.
+func TestParseWorkflowFixturesCovered(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, tt := range fixtureTests {
+		covered[tt.file] = true
+	}
+	files, err := filepath.Glob(filepath.Join("testdata", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		name := filepath.Base(file)
+		if !strings.HasPrefix(name, "object_info_") && !covered[name] {
+			t.Errorf("%s is not covered by fixtureTests", name)
+		}
+	}
+}
+
+func TestParseLinkEncodings(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Link
+	}{
+		{
+			name: "array",
+			data: `{"nodes": [{"id": 1, "type": "A"}, {"id": 2, "type": "B"}], "links": [[7, 1, 0, 2, 1, "MODEL"]], "version": 0.4}`,
+			want: Link{ID: 7, OriginID: "1", OriginSlot: 0, TargetID: "2", TargetSlot: 1, Type: "MODEL"},
+		},
+		{
+			name: "object",
+			data: `{"nodes": [{"id": 1, "type": "A"}, {"id": 2, "type": "B"}], "links": [{"id": 7, "origin_id": 1, "origin_slot": 0, "target_id": 2, "target_slot": 1, "type": "MODEL"}], "version": 1}`,
+			want: Link{ID: 7, OriginID: "1", OriginSlot: 0, TargetID: "2", TargetSlot: 1, Type: "MODEL"},
+		},
+		{
+			name: "nodes keyed by id",
+			data: `{"nodes": {"1": {"id": 1, "type": "A"}, "2": {"id": 2, "type": "B"}}, "links": [[7, 1, 0, 2, 1, "MODEL"]]}`,
+			want: Link{ID: 7, OriginID: "1", OriginSlot: 0, TargetID: "2", TargetSlot: 1, Type: "MODEL"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(w.Nodes) != 2 {
+				t.Fatalf("nodes = %d, want 2", len(w.Nodes))
+			}
+			if len(w.Links) != 1 {
+				t.Fatalf("links = %d, want 1", len(w.Links))
+			}
+			got := w.Links[0]
+			got.object = false
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("link = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sortedModels returns the sorted paths of the workflow's required models.
+func sortedModels(w *Workflow) []string {
+	var paths []string
+	for _, dep := range w.ExtractDependencies() {
+		if dep.Type == "model" && !dep.Optional {
+			paths = append(paths, dep.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,252 @@
+package workflow
+
+import (
+	"sort"
+	"strings"
+
+	"runcomfy/pkg/nodeschema"
+)
+
+// noteNodes only hold documentation. Prune keeps them, since they belong to
+// no branch.
+var noteNodes = map[string]bool{
+	"Note":         true,
+	"MarkdownNote": true,
+}
+
+// PruneOptions select the nodes Prune keeps.
+type PruneOptions struct {
+	// Outputs lists the node types whose branches are kept. When empty,
+	// output nodes are recognized by their definitions in Registry, and
+	// nodes of unknown type whose outputs nothing consumes are taken for
+	// output nodes too.
+	Outputs []string
+
+	// Registry supplies the node definitions. The bundled core definitions
+	// are used when it is nil.
+	Registry *nodeschema.Registry
+}
+
+// PrunedNode is a node removed by Prune.
+type PrunedNode struct {
+	ID    NodeID `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+}
+
+// PruneResult reports what Prune removed.
+type PruneResult struct {
+	Nodes  []PrunedNode `json:"nodes"`
+	Links  int          `json:"links"`
+	Groups []string     `json:"groups,omitempty"`
+
+	// CustomNodes lists the custom node types and Dependencies the model
+	// and input files that the pruned workflow no longer needs.
+	CustomNodes  []string     `json:"customNodes,omitempty"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+// Prune returns a copy of the workflow without the nodes that are not
+// upstream of an output node, along with the links to them and the groups
+// left empty. Output nodes count whether or not they are muted or bypassed,
+// so only branches nothing consumes are dropped. Links are followed through
+// subgraphs and group nodes, which are kept whole when any node inside them
+// is reachable or is an output node; note nodes are always kept. The
+// receiver is not modified.
+func (w *Workflow) Prune(opts PruneOptions) (*Workflow, *PruneResult) {
+	reg := opts.Registry
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	outputs := make(map[string]bool, len(opts.Outputs))
+	for _, nodeType := range opts.Outputs {
+		outputs[nodeType] = true
+	}
+
+	expanded := w.Expand()
+	graph := NewGraph(expanded)
+	var roots []NodeID
+	for i := range expanded.Nodes {
+		node := &expanded.Nodes[i]
+		var output bool
+		switch {
+		case noteNodes[node.Type]:
+			// Notes inside subgraphs do not keep their subgraph.
+			output = !strings.Contains(node.ID.String(), ":")
+		case len(outputs) > 0:
+			output = outputs[node.Type]
+		case !frontendNodes[node.Type]:
+			def, _ := reg.Lookup(node.Type)
+			output = isOutputNode(graph, node, def)
+		}
+		if output {
+			roots = append(roots, node.ID)
+		}
+	}
+
+	// Inner nodes carry "<outer id>:<inner id>" ids; reaching one keeps the
+	// top-level node that contains it.
+	reachable := make(map[NodeID]bool)
+	for _, id := range append(roots, graph.Upstream(roots...)...) {
+		outer, _, _ := strings.Cut(id.String(), ":")
+		reachable[NodeID(outer)] = true
+	}
+
+	out := *w
+	out.Nodes = nil
+	result := &PruneResult{}
+	for _, node := range w.Nodes {
+		if reachable[node.ID] {
+			out.Nodes = append(out.Nodes, node)
+			continue
+		}
+		result.Nodes = append(result.Nodes, PrunedNode{ID: node.ID, Type: node.Type, Title: node.Title})
+	}
+
+	removed := make(map[int]bool)
+	out.Links = nil
+	for _, link := range w.Links {
+		if reachable[link.OriginID] && reachable[link.TargetID] {
+			out.Links = append(out.Links, link)
+			continue
+		}
+		removed[link.ID] = true
+		result.Links++
+	}
+	// Kept nodes share their slots with the receiver; only those touching a
+	// removed link are copied and changed.
+	for i := range out.Nodes {
+		node := &out.Nodes[i]
+		for j, input := range node.Inputs {
+			if input.Link != nil && removed[*input.Link] {
+				node.Inputs = append([]Input(nil), node.Inputs...)
+				node.Inputs[j].Link = nil
+			}
+		}
+		for j, output := range node.Outputs {
+			links := make([]int, 0, len(output.Links))
+			for _, id := range output.Links {
+				if !removed[id] {
+					links = append(links, id)
+				}
+			}
+			if len(links) < len(output.Links) {
+				node.Outputs = append([]Output(nil), node.Outputs...)
+				node.Outputs[j].Links = links
+			}
+		}
+	}
+
+	out.Groups = nil
+	for _, group := range w.Groups {
+		if groupHasNodes(group, w.Nodes) && !groupHasNodes(group, out.Nodes) {
+			result.Groups = append(result.Groups, group.Title)
+			continue
+		}
+		out.Groups = append(out.Groups, group)
+	}
+
+	out.Definitions = pruneSubgraphs(w, out.Nodes)
+
+	sort.Slice(result.Nodes, func(i, j int) bool { return result.Nodes[i].ID.Less(result.Nodes[j].ID) })
+
+	result.CustomNodes = missingFrom(customNodeTypes(w, reg), customNodeTypes(&out, reg))
+	// Files are compared regardless of whether they are optional; node
+	// types are covered by CustomNodes.
+	seen := make(map[Dependency]bool)
+	for _, dep := range out.ExtractDependenciesUsing(reg) {
+		dep.Optional = false
+		seen[dep] = true
+	}
+	for _, dep := range w.ExtractDependenciesUsing(reg) {
+		key := dep
+		key.Optional = false
+		if dep.Type == "node" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result.Dependencies = append(result.Dependencies, dep)
+	}
+
+	return &out, result
+}
+
+// groupHasNodes reports whether any of the nodes lies in the group.
+func groupHasNodes(group Group, nodes []Node) bool {
+	for i := range nodes {
+		if inGroups(&nodes[i], []Group{group}) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneSubgraphs drops the subgraph definitions that the removed nodes were
+// the last to use. Definitions that were unused to begin with are kept.
+func pruneSubgraphs(w *Workflow, nodes []Node) *Definitions {
+	if w.Definitions == nil || len(w.Definitions.Subgraphs) == 0 {
+		return w.Definitions
+	}
+	before := w.usedSubgraphs(w.Nodes)
+	after := w.usedSubgraphs(nodes)
+
+	defs := *w.Definitions
+	defs.Subgraphs = nil
+	for _, sg := range w.Definitions.Subgraphs {
+		if before[sg.ID] && !after[sg.ID] {
+			continue
+		}
+		defs.Subgraphs = append(defs.Subgraphs, sg)
+	}
+	if len(defs.Subgraphs) == 0 {
+		return nil
+	}
+	return &defs
+}
+
+// usedSubgraphs returns the ids of the subgraphs instantiated by the nodes,
+// directly or through other subgraphs.
+func (w *Workflow) usedSubgraphs(nodes []Node) map[string]bool {
+	used := make(map[string]bool)
+	var visit func(nodes []Node)
+	visit = func(nodes []Node) {
+		for _, node := range nodes {
+			sg, ok := w.Subgraph(node.Type)
+			if !ok || used[sg.ID] {
+				continue
+			}
+			used[sg.ID] = true
+			visit(sg.Nodes)
+		}
+	}
+	visit(nodes)
+	return used
+}
+
+// customNodeTypes returns the node types used by active and inactive nodes
+// alike that do not come with ComfyUI, according to reg.
+func customNodeTypes(w *Workflow, reg *nodeschema.Registry) []string {
+	var result []string
+	for _, nodeType := range append(w.GetCustomNodes(), w.GetInactiveCustomNodes()...) {
+		def, ok := reg.Lookup(nodeType)
+		if !ok || strings.HasPrefix(def.PythonModule, "custom_nodes.") {
+			result = append(result, nodeType)
+		}
+	}
+	return result
+}
+
+// missingFrom returns the values of before that are not in after.
+func missingFrom(before, after []string) []string {
+	kept := make(map[string]bool, len(after))
+	for _, value := range after {
+		kept[value] = true
+	}
+	var result []string
+	for _, value := range before {
+		if !kept[value] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
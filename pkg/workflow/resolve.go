@@ -0,0 +1,123 @@
+package workflow
+
+import "runcomfy/pkg/nodeschema"
+
+// primitiveNodes supply a literal value through their first output. The
+// frontend-only PrimitiveNode keeps the value in widgets_values, the core
+// Primitive* nodes in their "value" widget.
+var primitiveNodes = map[string]bool{
+	"PrimitiveNode":            true,
+	"PrimitiveString":          true,
+	"PrimitiveStringMultiline": true,
+	"PrimitiveInt":             true,
+	"PrimitiveFloat":           true,
+	"PrimitiveBoolean":         true,
+}
+
+// passThroughNodes forward their single input unchanged.
+var passThroughNodes = map[string]bool{
+	"Reroute": true,
+}
+
+// IsPrimitive reports whether the node type only supplies a literal value.
+func IsPrimitive(nodeType string) bool {
+	return primitiveNodes[nodeType]
+}
+
+// IsPassThrough reports whether the node type forwards its input unchanged.
+func IsPassThrough(nodeType string) bool {
+	return passThroughNodes[nodeType]
+}
+
+// Source follows an edge upstream through pass-through nodes and returns
+// the node that actually produces the value.
+func (g *Graph) Source(edge Edge) (*Node, bool) {
+	visited := make(map[NodeID]bool)
+	for {
+		node, ok := g.nodes[edge.From]
+		if !ok || visited[edge.From] {
+			return nil, false
+		}
+		visited[edge.From] = true
+
+		if !passThroughNodes[node.Type] {
+			return node, true
+		}
+		incoming := g.incoming[node.ID]
+		if len(incoming) == 0 {
+			return nil, false
+		}
+		edge = incoming[0]
+	}
+}
+
+// ResolveInput returns the literal value fed into a linked input when it
+// comes from a primitive, possibly through reroutes.
+func (g *Graph) ResolveInput(id NodeID, input string) (interface{}, bool) {
+	edge, ok := g.InputEdge(id, input)
+	if !ok {
+		return nil, false
+	}
+	source, ok := g.Source(edge)
+	if !ok || !primitiveNodes[source.Type] {
+		return nil, false
+	}
+	return primitiveValue(source)
+}
+
+// IsLinked reports whether the named input is fed by a link. The value the
+// frontend keeps in widgets_values for such an input is stale; the linked
+// value is the one that runs.
+func (n *Node) IsLinked(input string) bool {
+	for _, in := range n.Inputs {
+		if in.Name == input {
+			return in.Link != nil
+		}
+	}
+	return false
+}
+
+func primitiveValue(node *Node) (interface{}, bool) {
+	if value, ok := node.NamedWidgets["value"]; ok {
+		return value, true
+	}
+	if len(node.Widgets) > 0 {
+		return node.Widgets[0], true
+	}
+	return nil, false
+}
+
+// linkedModelDependencies attributes models supplied through linked inputs
+// to the consuming node, so the model folder follows the loader rather than
+// the primitive that holds the value.
+func (g *Graph) linkedModelDependencies(node *Node) []Dependency {
+	var deps []Dependency
+	for _, input := range node.Inputs {
+		if input.Link == nil {
+			continue
+		}
+		value, ok := g.ResolveInput(node.ID, input.Name)
+		if !ok {
+			continue
+		}
+		str, ok := value.(string)
+		if !ok || str == "" {
+			continue
+		}
+
+		if folder := nodeschema.InputFolder(node.Type, input.Name); folder != "" {
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(str),
+				Path: modelPath(folder, str),
+			})
+		} else if isModelFile(str) {
+			deps = append(deps, Dependency{
+				Type: "model",
+				Name: normalizeFileName(str),
+				Path: inferModelPath(node.Type, str),
+			})
+		}
+	}
+	return deps
+}
@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveInputThroughPrimitives(t *testing.T) {
+	tests := []struct {
+		file  string
+		node  NodeID
+		input string
+		want  interface{}
+	}{
+		// PrimitiveNode 15 → Reroute 16 → CheckpointLoaderSimple 4
+		{"sdxl_primitive_reroute_v04.json", "4", "ckpt_name", "realvisxlV40_v40Bakedvae.safetensors"},
+		// PrimitiveNode 17 → LoraLoader 10
+		{"sdxl_primitive_reroute_v04.json", "10", "lora_name", "xl_more_art-full_v1.safetensors"},
+		// PrimitiveString 20 → UnetLoaderGGUF 12
+		{"flux_gguf_primitive_api.json", "12", "unet_name", "flux1-schnell-Q8_0.gguf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file+"/"+string(tt.node)+"/"+tt.input, func(t *testing.T) {
+			w, err := ParseWorkflow(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			got, ok := NewGraph(w).ResolveInput(tt.node, tt.input)
+			if !ok {
+				t.Fatalf("ResolveInput(%s, %s) did not resolve", tt.node, tt.input)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveInput(%s, %s) = %v, want %v", tt.node, tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveInputUnlinked(t *testing.T) {
+	w, err := ParseWorkflow(filepath.Join("testdata", "sdxl_primitive_reroute_v04.json"))
+	if err != nil {
+		t.Fatalf("ParseWorkflow: %v", err)
+	}
+	// The KSampler's model comes from a loader, not a primitive.
+	if value, ok := NewGraph(w).ResolveInput("3", "model"); ok {
+		t.Errorf("ResolveInput(3, model) = %v, want no value", value)
+	}
+}
+
+// TestModelsFollowPrimitiveValue changes the value held by the primitive and
+// expects the model attributed to the consuming loader to follow it.
+func TestModelsFollowPrimitiveValue(t *testing.T) {
+	tests := []struct {
+		file      string
+		primitive NodeID
+		value     string
+		want      []string
+	}{
+		{
+			file: "sdxl_primitive_reroute_v04.json", primitive: "15", value: "juggernautXL.safetensors",
+			want: []string{"checkpoints/juggernautXL.safetensors", "loras/xl_more_art-full_v1.safetensors"},
+		},
+		{
+			file: "sdxl_primitive_reroute_v04.json", primitive: "17", value: "styles/ink.safetensors",
+			want: []string{"checkpoints/realvisxlV40_v40Bakedvae.safetensors", "loras/styles/ink.safetensors"},
+		},
+		{
+			file: "flux_gguf_primitive_api.json", primitive: "20", value: "flux1-dev-Q4_0.gguf",
+			want: []string{
+				"clip/clip_l.safetensors", "clip/t5-v1_1-xxl-encoder-Q8_0.gguf",
+				"unet/flux1-dev-Q4_0.gguf", "vae/ae.safetensors",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file+"/"+string(tt.primitive), func(t *testing.T) {
+			w, err := ParseWorkflow(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("ParseWorkflow: %v", err)
+			}
+			node := findNode(w, tt.primitive)
+			if node == nil {
+				t.Fatalf("node %s not found", tt.primitive)
+			}
+			if _, ok := node.NamedWidgets["value"]; ok {
+				node.NamedWidgets["value"] = tt.value
+			} else {
+				node.Widgets[0] = tt.value
+			}
+
+			if got := sortedModels(w); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("models = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func findNode(w *Workflow, id NodeID) *Node {
+	for i := range w.Nodes {
+		if w.Nodes[i].ID == id {
+			return &w.Nodes[i]
+		}
+	}
+	return nil
+}
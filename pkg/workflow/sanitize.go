@@ -0,0 +1,415 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"runcomfy/pkg/nodeschema"
+)
+
+// Sanitize rules, each removing one kind of private information.
+const (
+	// RulePrompts clears multiline text widgets such as prompts.
+	RulePrompts = "prompts"
+	// RulePaths reduces absolute file paths to their file name.
+	RulePaths = "paths"
+	// RuleSubfolders drops the subfolders, which can carry user names, from
+	// selected model files. Since the subfolder is part of the file ComfyUI
+	// loads, the workflow then refers to another file; the rule is off by
+	// default.
+	RuleSubfolders = "subfolders"
+	// RuleNotes clears the text of Note and MarkdownNote nodes.
+	RuleNotes = "notes"
+	// RuleSecrets clears values that look like credentials.
+	RuleSecrets = "secrets"
+)
+
+// SanitizeRules lists every sanitize rule in the order they are applied.
+var SanitizeRules = []string{RuleSecrets, RuleNotes, RulePrompts, RuleSubfolders, RulePaths}
+
+// DefaultSanitizeRules lists the rules applied unless others are chosen:
+// all but RuleSubfolders, which changes the models a workflow loads.
+var DefaultSanitizeRules = []string{RuleSecrets, RuleNotes, RulePrompts, RulePaths}
+
+// Redaction records one value changed by Sanitize. Field is "widgets.<name>"
+// (or "widgets[<index>]" for unnamed widgets), "properties.<key>",
+// "models[<index>]" or a dotted path under "extra". From is masked for
+// secrets.
+type Redaction struct {
+	Rule     string `json:"rule"`
+	NodeID   NodeID `json:"nodeId,omitempty"`
+	NodeType string `json:"nodeType,omitempty"`
+	Field    string `json:"field"`
+	Reason   string `json:"reason,omitempty"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+var (
+	unixAbsPath    = regexp.MustCompile(`^(/|~/)[^/\s]+/`)
+	windowsAbsPath = regexp.MustCompile(`^([A-Za-z]:[\\/]|\\\\)`)
+)
+
+// Sanitize removes private information from the workflow in place, applying
+// the given rules to the widget values and properties of every node,
+// including those inside group node and subgraph definitions, to the
+// workflow's model list and, for secrets, to extra. Values are replaced
+// rather than removed so the workflow stays loadable. Widget values are
+// named using reg; a nil registry means the bundled definitions.
+func (w *Workflow) Sanitize(rules []string, reg *nodeschema.Registry) ([]Redaction, error) {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	s := &sanitizer{rules: make(map[string]bool), reg: reg}
+	for _, rule := range rules {
+		known := false
+		for _, name := range SanitizeRules {
+			known = known || name == rule
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown sanitize rule %q (available: %s)", rule, strings.Join(SanitizeRules, ", "))
+		}
+		s.rules[rule] = true
+	}
+
+	s.nodes(w.Nodes, w.Links)
+	if w.Definitions != nil {
+		for i := range w.Definitions.Subgraphs {
+			sg := &w.Definitions.Subgraphs[i]
+			s.nodes(sg.Nodes, sg.Links)
+		}
+	}
+	if err := s.groupNodes(w.Extra); err != nil {
+		return nil, err
+	}
+
+	for i := range w.Models {
+		model := &w.Models[i]
+		field := fmt.Sprintf("models[%d]", i)
+		if s.rules[RuleSecrets] {
+			model.URL = s.secrets(nil, field+".url", "url", model.URL).(string)
+		}
+		if s.rules[RuleSubfolders] {
+			if name := baseName(model.Name); name != model.Name {
+				s.record(Redaction{Rule: RuleSubfolders, Field: field + ".name", From: model.Name, To: name})
+				model.Name = name
+			}
+		}
+	}
+
+	if s.rules[RuleSecrets] {
+		for _, key := range sortedKeys(w.Extra) {
+			if key == "groupNodes" {
+				continue
+			}
+			w.Extra[key] = s.secrets(nil, "extra."+key, key, w.Extra[key])
+		}
+	}
+	return s.redactions, nil
+}
+
+type sanitizer struct {
+	rules      map[string]bool
+	reg        *nodeschema.Registry
+	redactions []Redaction
+}
+
+func (s *sanitizer) record(r Redaction) {
+	s.redactions = append(s.redactions, r)
+}
+
+// nodes sanitizes the nodes of one graph. Links are needed to find the
+// primitive nodes that feed prompts.
+func (s *sanitizer) nodes(nodes []Node, links []Link) {
+	graph := NewGraph(&Workflow{Nodes: nodes, Links: links})
+
+	// Primitive nodes take the role of the widget they feed.
+	feeds := make(map[NodeID]*nodeschema.InputDef)
+	for _, edge := range graph.Edges() {
+		source, ok := graph.Source(edge)
+		if !ok || source.Type != "PrimitiveNode" {
+			continue
+		}
+		target, _ := graph.Node(edge.To)
+		if def, ok := s.reg.Lookup(target.Type); ok {
+			if in, ok := def.Input(edge.ToName); ok {
+				feeds[source.ID] = in
+			}
+		}
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+		def, _ := s.reg.Lookup(node.Type)
+		if node.Type == "PrimitiveNode" {
+			if in, ok := feeds[node.ID]; ok {
+				def = &nodeschema.NodeDef{Name: node.Type, Inputs: []nodeschema.InputDef{{Name: "value", Type: in.Type, Multiline: in.Multiline, Folder: in.Folder}}}
+			}
+		}
+		names := widgetNames(node, def)
+
+		for index := range node.Widgets {
+			name := names[index]
+			field := "widgets." + name
+			if name == "" {
+				field = fmt.Sprintf("widgets[%d]", index)
+			}
+			node.Widgets[index] = s.widget(node, def, name, field, node.Widgets[index])
+		}
+		for _, name := range sortedKeys(node.NamedWidgets) {
+			node.NamedWidgets[name] = s.widget(node, def, name, "widgets."+name, node.NamedWidgets[name])
+		}
+
+		for _, key := range sortedKeys(node.Properties) {
+			value := node.Properties[key]
+			field := "properties." + key
+			if key == "models" {
+				value = s.propertyModels(node, field, value)
+			} else if s.rules[RulePaths] {
+				if str, ok := value.(string); ok {
+					value = s.path(node, field, str)
+				}
+			}
+			if s.rules[RuleSecrets] {
+				value = s.secrets(node, field, key, value)
+			}
+			node.Properties[key] = value
+		}
+	}
+}
+
+// widgetNames names the node's positional widget values where possible.
+func widgetNames(node *Node, def *nodeschema.NodeDef) []string {
+	names := make([]string, len(node.Widgets))
+	if frontendNodes[node.Type] && len(names) > 0 && def == nil {
+		names[0] = "text"
+		if node.Type == "PrimitiveNode" {
+			names[0] = "value"
+		}
+		return names
+	}
+	if def != nil {
+		if slots, ok := def.WidgetLayout(len(node.Widgets)); ok {
+			for i := range names {
+				if slots[i].Input != nil {
+					names[i] = slots[i].Name
+				}
+			}
+			return names
+		}
+		if node.Type == "PrimitiveNode" && len(names) > 0 {
+			names[0] = "value"
+			return names
+		}
+	}
+	var widgets []string
+	for _, input := range node.Inputs {
+		if input.Widget != nil {
+			widgets = append(widgets, input.Widget.Name)
+		}
+	}
+	if len(widgets) == len(names) {
+		copy(names, widgets)
+	}
+	return names
+}
+
+// widget applies the rules to one widget value.
+func (s *sanitizer) widget(node *Node, def *nodeschema.NodeDef, name, field string, value interface{}) interface{} {
+	if s.rules[RuleSecrets] {
+		value = s.secrets(node, field, name, value)
+	}
+	str, ok := value.(string)
+	if !ok || str == "" {
+		return value
+	}
+
+	var in *nodeschema.InputDef
+	if def != nil {
+		in, _ = def.Input(name)
+	}
+
+	switch {
+	case s.rules[RuleNotes] && (node.Type == "Note" || node.Type == "MarkdownNote"):
+		s.record(Redaction{Rule: RuleNotes, NodeID: node.ID, NodeType: node.Type, Field: field, From: excerpt(str)})
+		return ""
+	case s.rules[RulePrompts] && in != nil && in.Type == nodeschema.TypeString && in.Multiline:
+		s.record(Redaction{Rule: RulePrompts, NodeID: node.ID, NodeType: node.Type, Field: field, From: excerpt(str)})
+		return ""
+	}
+
+	if s.rules[RuleSubfolders] && (in != nil && in.Folder != "" || isModelFile(str)) {
+		if name := baseName(str); name != str {
+			s.record(Redaction{Rule: RuleSubfolders, NodeID: node.ID, NodeType: node.Type, Field: field, From: str, To: name})
+			str = name
+		}
+	}
+	if s.rules[RulePaths] {
+		str = s.path(node, field, str)
+	}
+	return str
+}
+
+// IsAbsolutePath reports whether a widget or property value is an absolute
+// file path, such as "/home/alice/out", "~/renders/out" or "C:\Users\alice\out".
+func IsAbsolutePath(value string) bool {
+	return unixAbsPath.MatchString(value) || windowsAbsPath.MatchString(value)
+}
+
+// path reduces an absolute path to its file name.
+func (s *sanitizer) path(node *Node, field, value string) string {
+	if !IsAbsolutePath(value) {
+		return value
+	}
+	name := baseName(value)
+	s.record(Redaction{Rule: RulePaths, NodeID: node.ID, NodeType: node.Type, Field: field, From: value, To: name})
+	return name
+}
+
+// propertyModels sanitizes the model download hints of a node.
+func (s *sanitizer) propertyModels(node *Node, field string, value interface{}) interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+	for i, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, ok := entry["name"].(string); ok && s.rules[RuleSubfolders] {
+			if base := baseName(name); base != name {
+				s.record(Redaction{Rule: RuleSubfolders, NodeID: node.ID, NodeType: node.Type, Field: fmt.Sprintf("%s[%d].name", field, i), From: name, To: base})
+				entry["name"] = base
+			}
+		}
+	}
+	return list
+}
+
+// secrets clears credentials anywhere inside value, which may be a nested
+// object or array.
+func (s *sanitizer) secrets(node *Node, field, key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		reason, ok := DetectSecret(key, v)
+		if !ok {
+			return value
+		}
+		// Keep URLs usable, only dropping the credentials they carry.
+		to := ""
+		if scrubbed, ok := scrubURL(v); ok {
+			if _, secret := DetectSecret(key, scrubbed); !secret {
+				to = scrubbed
+			}
+		}
+		r := Redaction{Rule: RuleSecrets, Field: field, Reason: reason, From: mask(v), To: to}
+		if node != nil {
+			r.NodeID, r.NodeType = node.ID, node.Type
+		}
+		s.record(r)
+		return to
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			v[k] = s.secrets(node, field+"."+k, k, v[k])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = s.secrets(node, fmt.Sprintf("%s[%d]", field, i), key, v[i])
+		}
+	}
+	return value
+}
+
+// groupNodes sanitizes the node templates of group node definitions kept in
+// extra.groupNodes.
+func (s *sanitizer) groupNodes(extra map[string]interface{}) error {
+	defs, ok := extra["groupNodes"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for _, name := range sortedKeys(defs) {
+		def, ok := defs[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(def["nodes"])
+		if err != nil {
+			return err
+		}
+		var nodes []Node
+		if err := json.Unmarshal(data, &nodes); err != nil {
+			return fmt.Errorf("invalid nodes in group node %q: %w", name, err)
+		}
+		// Template nodes are identified by their index.
+		for i := range nodes {
+			if nodes[i].ID == "" {
+				nodes[i].ID = NodeID(fmt.Sprint(i))
+			}
+			nodes[i].ID = NodeID(name + ":" + string(nodes[i].ID))
+		}
+		before := len(s.redactions)
+		s.nodes(nodes, nil)
+		if len(s.redactions) == before {
+			continue
+		}
+
+		// Write the changed values back into the templates, keeping the rest.
+		templates, _ := def["nodes"].([]interface{})
+		for i := range nodes {
+			template, ok := templates[i].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch {
+			case nodes[i].NamedWidgets != nil:
+				template["widgets_values"] = nodes[i].NamedWidgets
+			case nodes[i].Widgets != nil:
+				template["widgets_values"] = nodes[i].Widgets
+			}
+			if nodes[i].Properties != nil {
+				template["properties"] = nodes[i].Properties
+			}
+		}
+	}
+	return nil
+}
+
+// baseName strips the directories, with either separator, from a path.
+func baseName(p string) string {
+	return path.Base(strings.ReplaceAll(p, "\\", "/"))
+}
+
+// excerpt shortens text for a report.
+func excerpt(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > 40 {
+		return string(runes[:39]) + "…"
+	}
+	return s
+}
+
+// mask hides all but the first characters of a secret.
+func mask(s string) string {
+	runes := []rune(s)
+	if len(runes) <= 8 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:4]) + "…"
+}
+
+// SortRedactions orders redactions by node id, with workflow-level changes
+// first.
+func SortRedactions(redactions []Redaction) {
+	sort.SliceStable(redactions, func(i, j int) bool {
+		a, b := redactions[i].NodeID, redactions[j].NodeID
+		if (a == "") != (b == "") {
+			return a == ""
+		}
+		return a.Less(b)
+	})
+}
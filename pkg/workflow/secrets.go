@@ -0,0 +1,156 @@
+package workflow
+
+import (
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// secretPatterns match credentials with a recognisable shape.
+var secretPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"OpenAI or Anthropic API key", regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	{"Hugging Face token", regexp.MustCompile(`\bhf_[A-Za-z0-9]{30,}`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})`)},
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Replicate token", regexp.MustCompile(`\br8_[A-Za-z0-9]{30,}`)},
+	{"JSON Web Token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"bearer token", regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}`)},
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"credential in URL", regexp.MustCompile(`(?i)[?&](token|key|api_?key|access_token|auth|sig|signature)=[^&\s]{8,}`)},
+	{"password in URL", regexp.MustCompile(`://[^/\s:@]+:[^/\s@]+@`)},
+}
+
+// secretNames are words of widget, property and extra keys whose values are
+// credentials whatever they look like.
+var secretNames = map[string]bool{
+	"apikey": true, "token": true, "secret": true, "password": true,
+	"passwd": true, "credential": true, "credentials": true, "auth": true,
+	"sig": true, "signature": true,
+}
+
+// secretKey reports whether a key such as "api_key" or "hfToken" names a
+// credential.
+func secretKey(key string) bool {
+	// Split on punctuation and camel case; "api" followed by "key" counts
+	// as one word.
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, strings.ToLower(word.String()))
+			word.Reset()
+		}
+	}
+	var prev rune
+	for _, r := range key {
+		switch {
+		case !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'):
+			flush()
+			prev = r
+			continue
+		case r >= 'A' && r <= 'Z' && prev >= 'a' && prev <= 'z':
+			flush()
+		}
+		word.WriteRune(r)
+		prev = r
+	}
+	flush()
+
+	for i, w := range words {
+		if secretNames[w] || (w == "api" && i+1 < len(words) && words[i+1] == "key") {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectSecret reports whether a value stored under key looks like a
+// credential, and which kind. Besides known token formats it flags non-empty
+// values of keys such as "api_key" or "token", and long random-looking
+// strings.
+func DetectSecret(key, value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", false
+	}
+	for _, p := range secretPatterns {
+		if p.pattern.MatchString(value) {
+			return p.kind, true
+		}
+	}
+
+	if secretKey(key) {
+		return "value of " + key, true
+	}
+
+	if randomToken(value) {
+		return "high-entropy string", true
+	}
+	return "", false
+}
+
+// randomToken reports whether s is a single long token, mixing letters and
+// digits, with the character entropy of generated keys rather than words or
+// file names.
+func randomToken(s string) bool {
+	if len(s) < 32 || strings.ContainsAny(s, " \t\n./\\") {
+		return false
+	}
+	var letters, digits bool
+	counts := make(map[rune]int)
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			letters = true
+		case r >= '0' && r <= '9':
+			digits = true
+		case r == '-' || r == '_' || r == '+' || r == '=':
+		default:
+			return false
+		}
+		counts[r]++
+	}
+	if !letters || !digits {
+		return false
+	}
+
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= 3.5
+}
+
+// scrubURL removes the credentials from a URL: the user information and any
+// query parameter holding a secret. It reports whether anything was removed.
+func scrubURL(value string) (string, bool) {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value, false
+	}
+
+	changed := u.User != nil
+	u.User = nil
+	query := u.Query()
+	for key, values := range query {
+		for _, v := range values {
+			if _, ok := DetectSecret(key, v); ok {
+				query.Del(key)
+				changed = true
+				break
+			}
+		}
+	}
+	if !changed {
+		return value, false
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), true
+}
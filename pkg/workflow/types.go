@@ -1,71 +1,156 @@
 package workflow
 
+// Workflow is the normalized in-memory form of a ComfyUI workflow. Nodes are
+// always held as a slice and links as structured values, whatever shape the
+// source file used.
 type Workflow struct {
-	Version string                 `json:"version"`
-	Config  Config                 `json:"config,omitempty"`
-	State   State                  `json:"state,omitempty"`
-	Groups  []Group                `json:"groups,omitempty"`
-	Nodes   map[string]Node        `json:"nodes"`
-	Links   []Link                 `json:"links,omitempty"`
-	Models  []Model                `json:"models,omitempty"`
-	Extra   map[string]interface{} `json:"extra,omitempty"`
+	ID          string                 `json:"id,omitempty"`
+	Revision    int                    `json:"revision,omitempty"`
+	LastNodeID  int                    `json:"last_node_id,omitempty"`
+	LastLinkID  int                    `json:"last_link_id,omitempty"`
+	Nodes       []Node                 `json:"nodes"`
+	Links       []Link                 `json:"links"`
+	Groups      []Group                `json:"groups,omitempty"`
+	Config      Config                 `json:"config,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Version     Version                `json:"version"`
+	State       *State                 `json:"state,omitempty"`
+	Models      []Model                `json:"models,omitempty"`
+	Definitions *Definitions           `json:"definitions,omitempty"`
+
+	// Format records which serialization the workflow was parsed from.
+	Format Format `json:"-"`
+}
+
+// Definitions holds reusable graph definitions referenced by node type.
+type Definitions struct {
+	Subgraphs []Subgraph `json:"subgraphs,omitempty"`
+}
+
+// Subgraph is a nested graph instantiated by nodes whose type is the
+// subgraph id. Its links use the reserved ids SubgraphInputNodeID and
+// SubgraphOutputNodeID for the subgraph's own inputs and outputs.
+type Subgraph struct {
+	ID         string                 `json:"id"`
+	Version    Version                `json:"version"`
+	State      *State                 `json:"state,omitempty"`
+	Revision   int                    `json:"revision"`
+	Config     Config                 `json:"config,omitempty"`
+	Name       string                 `json:"name"`
+	InputNode  map[string]interface{} `json:"inputNode,omitempty"`
+	OutputNode map[string]interface{} `json:"outputNode,omitempty"`
+	Inputs     []SubgraphSlot         `json:"inputs"`
+	Outputs    []SubgraphSlot         `json:"outputs"`
+	Widgets    []interface{}          `json:"widgets"`
+	Nodes      []Node                 `json:"nodes"`
+	Groups     []Group                `json:"groups,omitempty"`
+	Links      []Link                 `json:"links"`
+	Extra      map[string]interface{} `json:"extra,omitempty"`
+}
+
+type SubgraphSlot struct {
+	ID            string      `json:"id"`
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	LinkIDs       []int       `json:"linkIds,omitempty"`
+	Label         string      `json:"label,omitempty"`
+	LocalizedName string      `json:"localized_name,omitempty"`
+	Pos           interface{} `json:"pos,omitempty"`
 }
 
 type Config struct {
-	LinksOnTop   bool `json:"links_ontop,omitempty"`
-	AlignToGrid  bool `json:"align_to_grid,omitempty"`
+	LinksOnTop  bool `json:"links_ontop,omitempty"`
+	AlignToGrid bool `json:"align_to_grid,omitempty"`
 }
 
+// State carries the id counters used by the 1.0 workflow schema.
 type State struct {
-	GroupCount   int `json:"groups,omitempty"`
-	NodeCount    int `json:"nodes,omitempty"`
-	LinkCount    int `json:"links,omitempty"`
-	RerouteCount int `json:"reroutes,omitempty"`
+	LastGroupID   int `json:"lastGroupId"`
+	LastNodeID    int `json:"lastNodeId"`
+	LastLinkID    int `json:"lastLinkId"`
+	LastRerouteID int `json:"lastRerouteId"`
 }
 
 type Group struct {
-	Title     string    `json:"title"`
-	Bounding  []float64 `json:"bounding"`
-	Color     string    `json:"color,omitempty"`
-	FontSize  int       `json:"font_size,omitempty"`
-	Locked    bool      `json:"locked,omitempty"`
+	ID       int                    `json:"id,omitempty"`
+	Title    string                 `json:"title"`
+	Bounding []float64              `json:"bounding"`
+	Color    string                 `json:"color,omitempty"`
+	FontSize int                    `json:"font_size,omitempty"`
+	Locked   bool                   `json:"locked,omitempty"`
+	Flags    map[string]interface{} `json:"flags,omitempty"`
 }
 
+// Node execution modes as stored in Node.Mode.
+const (
+	ModeAlways    = 0
+	ModeOnEvent   = 1
+	ModeNever     = 2
+	ModeOnTrigger = 3
+	ModeBypass    = 4
+)
+
 type Node struct {
-	ID         interface{}            `json:"id"`
+	ID         NodeID                 `json:"id"`
 	Type       string                 `json:"type"`
+	Title      string                 `json:"title,omitempty"`
 	Pos        interface{}            `json:"pos"`
 	Size       interface{}            `json:"size,omitempty"`
 	Flags      map[string]interface{} `json:"flags,omitempty"`
-	Order      int                    `json:"order,omitempty"`
-	Mode       int                    `json:"mode,omitempty"`
+	Order      int                    `json:"order"`
+	Mode       int                    `json:"mode"`
+	Color      string                 `json:"color,omitempty"`
+	BgColor    string                 `json:"bgcolor,omitempty"`
 	Properties map[string]interface{} `json:"properties,omitempty"`
 	Inputs     []Input                `json:"inputs,omitempty"`
 	Outputs    []Output               `json:"outputs,omitempty"`
-	Widgets    []interface{}          `json:"widgets_values,omitempty"`
+	Widgets    []interface{}          `json:"-"`
+
+	// NamedWidgets holds widget values keyed by input name. Some custom
+	// nodes serialize widgets_values as an object instead of an array.
+	NamedWidgets map[string]interface{} `json:"-"`
 }
 
 type Input struct {
-	Name string      `json:"name"`
-	Type string      `json:"type"`
-	Link interface{} `json:"link,omitempty"`
+	Name      string       `json:"name"`
+	Type      string       `json:"type"`
+	Link      *int         `json:"link"`
+	Widget    *InputWidget `json:"widget,omitempty"`
+	Label     string       `json:"label,omitempty"`
+	Shape     int          `json:"shape,omitempty"`
+	SlotIndex int          `json:"slot_index,omitempty"`
 }
 
-type Output struct {
-	Name  string   `json:"name"`
-	Type  string   `json:"type"`
-	Links []int    `json:"links,omitempty"`
+// InputWidget marks an input that was converted from a widget, or the output
+// of a primitive node feeding such an input.
+type InputWidget struct {
+	Name string `json:"name"`
 }
 
+type Output struct {
+	Name      string       `json:"name"`
+	Type      string       `json:"type"`
+	Links     []int        `json:"links"`
+	Label     string       `json:"label,omitempty"`
+	Shape     int          `json:"shape,omitempty"`
+	SlotIndex int          `json:"slot_index,omitempty"`
+	Widget    *InputWidget `json:"widget,omitempty"`
+}
 
+// Link connects an output slot of one node to an input slot of another.
+// It is decoded from either the array encoding
+// [id, origin_id, origin_slot, target_id, target_slot, type] or the object
+// encoding used by the 1.0 schema.
 type Link struct {
-	ID         int         `json:"id"`
-	OutputNode int         `json:"output_node"`
-	OutputSlot int         `json:"output_slot"`
-	InputNode  int         `json:"input_node"`
-	InputSlot  int         `json:"input_slot"`
-	Type       string      `json:"type,omitempty"`
-	Extra      interface{} `json:"extra,omitempty"`
+	ID         int
+	OriginID   NodeID
+	OriginSlot int
+	TargetID   NodeID
+	TargetSlot int
+	Type       string
+	ParentID   int
+
+	object bool
 }
 
 type Model struct {
@@ -76,16 +161,25 @@ type Model struct {
 	HashType  string `json:"hash_type,omitempty"`
 }
 
+// Dependency is something a workflow needs in order to run. Type is "node"
+// for node types, "model" for model files, with Path relative to the models
+// directory, and "input" for files the workflow loads from the input
+// directory, with Path relative to the ComfyUI root. Names and paths of files
+// use forward slashes, whatever the workflow was saved with.
 type Dependency struct {
-	Type string
-	Name string
-	Path string
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+
+	// Optional is set when the dependency only comes from muted or
+	// bypassed nodes.
+	Optional bool `json:"optional,omitempty"`
 }
 
 type Analysis struct {
-	CustomNodes    []string
-	Models         []Model
-	Dependencies   []Dependency
-	MissingNodes   []string
-	MissingModels  []Model
-}
\ No newline at end of file
+	CustomNodes   []string
+	Models        []Model
+	Dependencies  []Dependency
+	MissingNodes  []string
+	MissingModels []Model
+}
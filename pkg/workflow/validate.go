@@ -0,0 +1,325 @@
+package workflow
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"runcomfy/pkg/nodeschema"
+)
+
+// Severity ranks validation issues.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue codes reported by Validate.
+const (
+	IssueDanglingLink   = "dangling-link"
+	IssueTypeMismatch   = "type-mismatch"
+	IssueMissingInput   = "missing-input"
+	IssueInvalidValue   = "invalid-value"
+	IssueOutOfRange     = "out-of-range"
+	IssueInvalidChoice  = "invalid-choice"
+	IssueWidgetMismatch = "widget-mismatch"
+	IssueCycle          = "cycle"
+	IssueNoOutput       = "no-output"
+)
+
+// Issue is a single validation finding. NodeID is empty for issues that
+// concern the workflow as a whole.
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	NodeID   NodeID   `json:"nodeId,omitempty"`
+	NodeType string   `json:"nodeType,omitempty"`
+	Input    string   `json:"input,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// frontendNodes only exist in the editor and are never sent to the server.
+var frontendNodes = map[string]bool{
+	"PrimitiveNode": true,
+	"Reroute":       true,
+	"Note":          true,
+	"MarkdownNote":  true,
+}
+
+// IsFrontendNode reports whether nodes of the given type only exist in the
+// editor, such as notes, reroutes and primitive nodes.
+func IsFrontendNode(nodeType string) bool {
+	return frontendNodes[nodeType]
+}
+
+// Validate checks the workflow's structure and, for nodes described by reg,
+// its connections and widget values. Group nodes and subgraphs are expanded
+// first, so issues inside them carry "<outer id>:<inner id>" node ids. A nil
+// registry means the bundled definitions.
+func (w *Workflow) Validate(reg *nodeschema.Registry) []Issue {
+	if reg == nil {
+		reg = nodeschema.Bundled()
+	}
+	w = w.Expand()
+	graph := NewGraph(w)
+	v := &validator{graph: graph, reg: reg}
+
+	for _, dangling := range graph.Dangling() {
+		v.add(Issue{
+			Severity: SeverityError,
+			Code:     IssueDanglingLink,
+			NodeID:   dangling.Link.TargetID,
+			Message:  fmt.Sprintf("link %d: %s", dangling.Link.ID, dangling.Reason),
+		})
+	}
+
+	for _, cycle := range graph.Cycles() {
+		ids := make([]string, 0, len(cycle))
+		for _, id := range cycle {
+			ids = append(ids, id.String())
+		}
+		v.add(Issue{
+			Severity: SeverityError,
+			Code:     IssueCycle,
+			NodeID:   cycle[0],
+			Message:  fmt.Sprintf("nodes %s form a cycle", strings.Join(ids, ", ")),
+		})
+	}
+
+	for _, edge := range graph.Edges() {
+		v.checkEdge(edge)
+	}
+
+	hasOutput := false
+	for i := range w.Nodes {
+		node := &w.Nodes[i]
+		if !node.IsActive() || frontendNodes[node.Type] {
+			continue
+		}
+		def, known := reg.Lookup(node.Type)
+		if isOutputNode(graph, node, def) {
+			hasOutput = true
+		}
+		if known {
+			v.checkNode(node, def)
+		}
+	}
+
+	if !hasOutput {
+		v.add(Issue{
+			Severity: SeverityError,
+			Code:     IssueNoOutput,
+			Message:  "workflow has no active output node, so ComfyUI will refuse to queue it",
+		})
+	}
+
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		a, b := v.issues[i].NodeID, v.issues[j].NodeID
+		if a == "" || b == "" {
+			return a == "" && b != ""
+		}
+		return a.Less(b)
+	})
+	return v.issues
+}
+
+// HasErrors reports whether any issue has error severity.
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+type validator struct {
+	graph  *Graph
+	reg    *nodeschema.Registry
+	issues []Issue
+}
+
+func (v *validator) add(issue Issue) {
+	if issue.NodeID != "" && issue.NodeType == "" {
+		if node, ok := v.graph.Node(issue.NodeID); ok {
+			issue.NodeType = node.Type
+		}
+	}
+	v.issues = append(v.issues, issue)
+}
+
+func (v *validator) checkEdge(edge Edge) {
+	origin, _ := v.graph.Node(edge.From)
+	target, _ := v.graph.Node(edge.To)
+
+	from := ""
+	if edge.FromSlot < len(origin.Outputs) {
+		from = origin.Outputs[edge.FromSlot].Type
+	}
+	if from == "" {
+		if def, ok := v.reg.Lookup(origin.Type); ok && edge.FromSlot < len(def.Outputs) {
+			from = def.Outputs[edge.FromSlot].Type
+		}
+	}
+	to := target.Inputs[edge.ToSlot].Type
+	if to == "" {
+		if def, ok := v.reg.Lookup(target.Type); ok {
+			if in, ok := def.Input(edge.ToName); ok {
+				to = in.Type
+			}
+		}
+	}
+
+	if !typesCompatible(from, to) {
+		v.add(Issue{
+			Severity: SeverityError,
+			Code:     IssueTypeMismatch,
+			NodeID:   edge.To,
+			Input:    edge.ToName,
+			Message:  fmt.Sprintf("input %q expects %s but node %s output %d provides %s", edge.ToName, to, edge.From, edge.FromSlot, from),
+		})
+	}
+}
+
+// typesCompatible mirrors the frontend's connection rule: wildcards and
+// unknown types match anything, and either side may list alternatives
+// separated by commas.
+func typesCompatible(from, to string) bool {
+	if from == "" || to == "" || from == "*" || to == "*" {
+		return true
+	}
+	if strings.EqualFold(from, to) {
+		return true
+	}
+	for _, a := range strings.Split(from, ",") {
+		for _, b := range strings.Split(to, ",") {
+			if strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *validator) checkNode(node *Node, def *nodeschema.NodeDef) {
+	values, mapped := node.WidgetsByName(def)
+	if !mapped {
+		v.add(Issue{
+			Severity: SeverityWarning,
+			Code:     IssueWidgetMismatch,
+			NodeID:   node.ID,
+			Message:  fmt.Sprintf("%d widget values do not match the %d widgets of %s; values were not checked", len(node.Widgets), len(def.WidgetSlots()), def.Name),
+		})
+	}
+
+	for i := range def.Inputs {
+		in := &def.Inputs[i]
+		_, linked := v.graph.InputEdge(node.ID, in.Name)
+
+		if !in.IsWidget() {
+			if in.Required && !linked {
+				v.add(Issue{
+					Severity: SeverityError,
+					Code:     IssueMissingInput,
+					NodeID:   node.ID,
+					Input:    in.Name,
+					Message:  fmt.Sprintf("required input %q (%s) is not connected", in.Name, in.Type),
+				})
+			}
+			continue
+		}
+
+		var value interface{}
+		var ok bool
+		if linked {
+			value, ok = v.graph.ResolveInput(node.ID, in.Name)
+		} else if mapped {
+			value, ok = values[in.Name]
+		}
+		if !ok {
+			continue
+		}
+		if issue, bad := checkValue(in, value); bad {
+			issue.NodeID = node.ID
+			issue.Input = in.Name
+			v.add(issue)
+		}
+	}
+}
+
+// checkValue tests a widget value against its input definition.
+func checkValue(in *nodeschema.InputDef, value interface{}) (Issue, bool) {
+	invalid := func(format string, args ...interface{}) (Issue, bool) {
+		return Issue{
+			Severity: SeverityError,
+			Code:     IssueInvalidValue,
+			Message:  fmt.Sprintf("%q: ", in.Name) + fmt.Sprintf(format, args...),
+		}, true
+	}
+
+	switch in.Type {
+	case nodeschema.TypeInt, nodeschema.TypeFloat:
+		f, ok := value.(float64)
+		if !ok {
+			return invalid("expected a number, got %v", value)
+		}
+		if in.Type == nodeschema.TypeInt && f != math.Trunc(f) {
+			return invalid("expected an integer, got %v", f)
+		}
+		if (in.Min != nil && f < *in.Min) || (in.Max != nil && f > *in.Max) {
+			return Issue{
+				Severity: SeverityError,
+				Code:     IssueOutOfRange,
+				Message:  fmt.Sprintf("%q: %v is outside %s", in.Name, f, formatRange(in)),
+			}, true
+		}
+	case nodeschema.TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return invalid("expected a boolean, got %v", value)
+		}
+	case nodeschema.TypeString:
+		if _, ok := value.(string); !ok {
+			return invalid("expected a string, got %v", value)
+		}
+	case nodeschema.TypeCombo:
+		if len(in.Choices) == 0 {
+			return Issue{}, false
+		}
+		str := fmt.Sprint(value)
+		for _, choice := range in.Choices {
+			if choice == str {
+				return Issue{}, false
+			}
+		}
+		return Issue{
+			Severity: SeverityError,
+			Code:     IssueInvalidChoice,
+			Message:  fmt.Sprintf("%q: %q is not one of the %d available choices", in.Name, str, len(in.Choices)),
+		}, true
+	}
+	return Issue{}, false
+}
+
+func formatRange(in *nodeschema.InputDef) string {
+	lower, upper := "-inf", "+inf"
+	if in.Min != nil {
+		lower = fmt.Sprint(*in.Min)
+	}
+	if in.Max != nil {
+		upper = fmt.Sprint(*in.Max)
+	}
+	return "[" + lower + ", " + upper + "]"
+}
+
+// isOutputNode reports whether the node produces workflow output. Without a
+// definition, nodes whose outputs nothing consumes are given the benefit of
+// the doubt.
+func isOutputNode(g *Graph, node *Node, def *nodeschema.NodeDef) bool {
+	if def != nil {
+		return def.OutputNode
+	}
+	return len(g.Outgoing(node.ID)) == 0
+}